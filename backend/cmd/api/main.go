@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/eureka-cycling/committee-apps/backend/internal/auth"
 	"github.com/eureka-cycling/committee-apps/backend/internal/endpoints"
 	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
 )
@@ -21,40 +24,102 @@ func init() {
 	if signingSecret == "" {
 		signingSecret = "default-development-secret"
 	}
-	bucketName := os.Getenv("DOCUMENTS_BUCKET_NAME")
-	prov, err := storage.NewS3StorageProvider(context.Background(), bucketName)
+	prov, err := newStorageProvider("documents", os.Getenv("DOCUMENTS_BUCKET_NAME"))
 	if err != nil {
 		panic(err)
 	}
 	storageProv = prov
 
-	dataBucketName := os.Getenv("DATA_BUCKET_NAME")
-	dprov, err := storage.NewS3StorageProvider(context.Background(), dataBucketName)
+	dprov, err := newStorageProvider("data", os.Getenv("DATA_BUCKET_NAME"))
 	if err != nil {
 		panic(err)
 	}
 	dataProv = dprov
 }
 
+// newStorageProvider uses S3 unless LOCAL_STORAGE_DIR is set, in which case
+// the bucket is emulated by a subdirectory of that folder
+func newStorageProvider(name, bucketName string) (storage.StorageProvider, error) {
+	if localDir := os.Getenv("LOCAL_STORAGE_DIR"); localDir != "" {
+		return storage.NewLocalStorageProvider(filepath.Join(localDir, name))
+	}
+	return storage.NewS3StorageProvider(context.Background(), bucketName)
+}
+
 type route struct {
 	handler endpoints.HandlerFunc
+	// roles lists the Cognito roles allowed to call the route
+	roles []auth.Role
+	// public routes skip the role check and authorise themselves (e.g. signed document tokens)
+	public bool
 }
 
+var (
+	anyRole        = auth.AllRoles
+	committeeRoles = []auth.Role{auth.RoleCommittee, auth.RoleTreasurer}
+	treasurerRoles = []auth.Role{auth.RoleTreasurer}
+)
+
 var routes = map[string]route{
-	"GET:/hello":               {handler: endpoints.Hello},
-	"GET:/documents/list":      {handler: endpoints.DocumentsList},
-	"GET:/documents/raw":       {handler: endpoints.DocumentsRaw},
-	"GET:/documents/view":      {handler: endpoints.DocumentsView},
-	"POST:/documents/save":     {handler: endpoints.DocumentsSave},
-	"POST:/documents/upload":   {handler: endpoints.DocumentsUpload},
-	"POST:/documents/mkdir":    {handler: endpoints.DocumentsMkdir},
-	"GET:/ledger":              {handler: endpoints.LedgerGet},
-	"GET:/ledger/pdf":          {handler: endpoints.LedgerPdf},
-	"POST:/ledger":             {handler: endpoints.LedgerPost},
-	"POST:/ledger/import/bank": {handler: endpoints.LedgerBankImport},
-	"GET:/ledger/categories":   {handler: endpoints.LedgerCategoriesGet},
-	"POST:/ledger/categories":  {handler: endpoints.LedgerCategoriesPost},
-	"GET:/reports/financial":   {handler: endpoints.FinancialReportGet},
+	"GET:/hello":                           {handler: endpoints.Hello, roles: anyRole},
+	"GET:/documents/list":                  {handler: endpoints.DocumentsList, roles: committeeRoles},
+	"GET:/documents/raw":                   {handler: endpoints.DocumentsRaw, public: true},
+	"GET:/documents/view":                  {handler: endpoints.DocumentsView, roles: committeeRoles},
+	"POST:/documents/save":                 {handler: endpoints.DocumentsSave, roles: committeeRoles},
+	"POST:/documents/upload":               {handler: endpoints.DocumentsUpload, roles: committeeRoles},
+	"POST:/documents/mkdir":                {handler: endpoints.DocumentsMkdir, roles: committeeRoles},
+	"GET:/ledger":                          {handler: endpoints.LedgerGet, roles: treasurerRoles},
+	"GET:/ledger/pdf":                      {handler: endpoints.LedgerPdf, roles: treasurerRoles},
+	"POST:/ledger":                         {handler: endpoints.LedgerPost, roles: treasurerRoles},
+	"POST:/ledger/import/bank":             {handler: endpoints.LedgerBankImport, roles: treasurerRoles},
+	"GET:/ledger/categories":               {handler: endpoints.LedgerCategoriesGet, roles: treasurerRoles},
+	"POST:/ledger/categories":              {handler: endpoints.LedgerCategoriesPost, roles: treasurerRoles},
+	"PUT:/ledger/categories":               {handler: endpoints.LedgerCategoriesPut, roles: treasurerRoles},
+	"DELETE:/ledger/categories":            {handler: endpoints.LedgerCategoriesDelete, roles: treasurerRoles},
+	"POST:/ledger/categories/merge":        {handler: endpoints.LedgerCategoriesMerge, roles: treasurerRoles},
+	"GET:/ledger/rules":                    {handler: endpoints.LedgerRulesGet, roles: treasurerRoles},
+	"POST:/ledger/rules":                   {handler: endpoints.LedgerRulesPost, roles: treasurerRoles},
+	"POST:/ledger/recategorise":            {handler: endpoints.LedgerRecategorise, roles: treasurerRoles},
+	"POST:/ledger/transfer":                {handler: endpoints.LedgerTransferPost, roles: treasurerRoles},
+	"DELETE:/ledger/transfer":              {handler: endpoints.LedgerTransferDelete, roles: treasurerRoles},
+	"POST:/ledger/attachments":             {handler: endpoints.LedgerAttachmentPost, roles: treasurerRoles},
+	"GET:/ledger/reconciliation":           {handler: endpoints.LedgerReconciliationGet, roles: treasurerRoles},
+	"POST:/ledger/reconciliation":          {handler: endpoints.LedgerReconciliationPost, roles: treasurerRoles},
+	"POST:/ledger/reconciliation/complete": {handler: endpoints.LedgerReconciliationComplete, roles: treasurerRoles},
+	"POST:/ledger/reconciliation/unlock":   {handler: endpoints.LedgerReconciliationUnlock, roles: treasurerRoles},
+	"GET:/periods/locks":                   {handler: endpoints.PeriodLocksGet, roles: treasurerRoles},
+	"POST:/periods/locks":                  {handler: endpoints.PeriodLockPost, roles: treasurerRoles},
+	"DELETE:/periods/locks":                {handler: endpoints.PeriodLockDelete, roles: treasurerRoles},
+	"POST:/periods/close":                  {handler: endpoints.PeriodCloseYear, roles: treasurerRoles},
+	"GET:/reimbursements":                  {handler: endpoints.ReimbursementsGet, roles: committeeRoles},
+	"POST:/reimbursements":                 {handler: endpoints.ReimbursementsPost, roles: committeeRoles},
+	"POST:/reimbursements/review":          {handler: endpoints.ReimbursementsReview, roles: treasurerRoles},
+	"POST:/reimbursements/pay":             {handler: endpoints.ReimbursementsPay, roles: treasurerRoles},
+	"GET:/grants":                          {handler: endpoints.GrantsGet, roles: treasurerRoles},
+	"POST:/grants":                         {handler: endpoints.GrantsPost, roles: treasurerRoles},
+	"DELETE:/grants":                       {handler: endpoints.GrantsDelete, roles: treasurerRoles},
+	"GET:/loans":                           {handler: endpoints.LoansGet, roles: treasurerRoles},
+	"POST:/loans":                          {handler: endpoints.LoansPost, roles: treasurerRoles},
+	"DELETE:/loans":                        {handler: endpoints.LoansDelete, roles: treasurerRoles},
+	"GET:/loans/schedule":                  {handler: endpoints.LoanScheduleGet, roles: treasurerRoles},
+	"GET:/trust-funds":                     {handler: endpoints.TrustFundsGet, roles: treasurerRoles},
+	"POST:/trust-funds":                    {handler: endpoints.TrustFundsPost, roles: treasurerRoles},
+	"DELETE:/trust-funds":                  {handler: endpoints.TrustFundsDelete, roles: treasurerRoles},
+	"GET:/trust-funds/ledger":              {handler: endpoints.TrustFundLedgerGet, roles: treasurerRoles},
+	"GET:/journal":                         {handler: endpoints.JournalGet, roles: treasurerRoles},
+	"POST:/journal":                        {handler: endpoints.JournalPost, roles: treasurerRoles},
+	"DELETE:/journal":                      {handler: endpoints.JournalDelete, roles: treasurerRoles},
+	"GET:/journal/accounts":                {handler: endpoints.JournalAccountsGet, roles: treasurerRoles},
+	"GET:/journal/accounts/view":           {handler: endpoints.JournalAccountView, roles: treasurerRoles},
+	"POST:/journal/migrate":                {handler: endpoints.JournalMigrate, roles: treasurerRoles},
+	"GET:/reports/financial":               {handler: endpoints.FinancialReportGet, roles: committeeRoles},
+}
+
+func (r route) allows(request events.APIGatewayProxyRequest) bool {
+	if r.public {
+		return true
+	}
+	return auth.HasRole(auth.RoleFromAuthorizer(request.RequestContext.Authorizer), r.roles)
 }
 
 func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
@@ -76,6 +141,15 @@ func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		}, nil
 	}
 
+	if !route.allows(request) {
+		fmt.Printf("Forbidden: %s for role %s\n", key, auth.RoleFromAuthorizer(request.RequestContext.Authorizer))
+		return events.APIGatewayProxyResponse{
+			Body:       `{"error": "Forbidden"}`,
+			StatusCode: 403,
+			Headers:    headers,
+		}, nil
+	}
+
 	return route.handler(ctx, request, deps)
 }
 
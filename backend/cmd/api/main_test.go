@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/auth"
+	"github.com/eureka-cycling/committee-apps/backend/internal/endpoints"
+)
+
+func TestHandleRequest_RoleAuthorization(t *testing.T) {
+	// Replace every handler with a stub so only the router's authorization is exercised
+	original := routes
+	stubbed := make(map[string]route, len(original))
+	for key, r := range original {
+		r.handler = func(_ context.Context, _ events.APIGatewayProxyRequest, deps endpoints.Dependencies) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{StatusCode: 200, Headers: deps.Headers}, nil
+		}
+		stubbed[key] = r
+	}
+	routes = stubbed
+	t.Cleanup(func() { routes = original })
+
+	allowed := map[string][]auth.Role{
+		"GET:/hello":                           {auth.RoleNone, auth.RoleMember, auth.RoleCommittee, auth.RoleTreasurer},
+		"GET:/documents/list":                  {auth.RoleCommittee, auth.RoleTreasurer},
+		"GET:/documents/raw":                   {auth.RoleNone, auth.RoleMember, auth.RoleCommittee, auth.RoleTreasurer},
+		"GET:/documents/view":                  {auth.RoleCommittee, auth.RoleTreasurer},
+		"POST:/documents/save":                 {auth.RoleCommittee, auth.RoleTreasurer},
+		"POST:/documents/upload":               {auth.RoleCommittee, auth.RoleTreasurer},
+		"POST:/documents/mkdir":                {auth.RoleCommittee, auth.RoleTreasurer},
+		"GET:/ledger":                          {auth.RoleTreasurer},
+		"GET:/ledger/pdf":                      {auth.RoleTreasurer},
+		"POST:/ledger":                         {auth.RoleTreasurer},
+		"POST:/ledger/import/bank":             {auth.RoleTreasurer},
+		"GET:/ledger/categories":               {auth.RoleTreasurer},
+		"POST:/ledger/categories":              {auth.RoleTreasurer},
+		"PUT:/ledger/categories":               {auth.RoleTreasurer},
+		"DELETE:/ledger/categories":            {auth.RoleTreasurer},
+		"POST:/ledger/categories/merge":        {auth.RoleTreasurer},
+		"GET:/ledger/rules":                    {auth.RoleTreasurer},
+		"POST:/ledger/rules":                   {auth.RoleTreasurer},
+		"POST:/ledger/recategorise":            {auth.RoleTreasurer},
+		"POST:/ledger/transfer":                {auth.RoleTreasurer},
+		"DELETE:/ledger/transfer":              {auth.RoleTreasurer},
+		"POST:/ledger/attachments":             {auth.RoleTreasurer},
+		"GET:/ledger/reconciliation":           {auth.RoleTreasurer},
+		"POST:/ledger/reconciliation":          {auth.RoleTreasurer},
+		"POST:/ledger/reconciliation/complete": {auth.RoleTreasurer},
+		"POST:/ledger/reconciliation/unlock":   {auth.RoleTreasurer},
+		"GET:/periods/locks":                   {auth.RoleTreasurer},
+		"POST:/periods/locks":                  {auth.RoleTreasurer},
+		"DELETE:/periods/locks":                {auth.RoleTreasurer},
+		"POST:/periods/close":                  {auth.RoleTreasurer},
+		"GET:/reimbursements":                  {auth.RoleCommittee, auth.RoleTreasurer},
+		"POST:/reimbursements":                 {auth.RoleCommittee, auth.RoleTreasurer},
+		"POST:/reimbursements/review":          {auth.RoleTreasurer},
+		"POST:/reimbursements/pay":             {auth.RoleTreasurer},
+		"GET:/grants":                          {auth.RoleTreasurer},
+		"POST:/grants":                         {auth.RoleTreasurer},
+		"DELETE:/grants":                       {auth.RoleTreasurer},
+		"GET:/loans":                           {auth.RoleTreasurer},
+		"POST:/loans":                          {auth.RoleTreasurer},
+		"DELETE:/loans":                        {auth.RoleTreasurer},
+		"GET:/loans/schedule":                  {auth.RoleTreasurer},
+		"GET:/trust-funds":                     {auth.RoleTreasurer},
+		"POST:/trust-funds":                    {auth.RoleTreasurer},
+		"DELETE:/trust-funds":                  {auth.RoleTreasurer},
+		"GET:/trust-funds/ledger":              {auth.RoleTreasurer},
+		"GET:/journal":                         {auth.RoleTreasurer},
+		"POST:/journal":                        {auth.RoleTreasurer},
+		"DELETE:/journal":                      {auth.RoleTreasurer},
+		"GET:/journal/accounts":                {auth.RoleTreasurer},
+		"GET:/journal/accounts/view":           {auth.RoleTreasurer},
+		"POST:/journal/migrate":                {auth.RoleTreasurer},
+		"GET:/reports/financial":               {auth.RoleCommittee, auth.RoleTreasurer},
+	}
+
+	for key := range routes {
+		if _, ok := allowed[key]; !ok {
+			t.Errorf("route %s has no authorization test case", key)
+		}
+	}
+
+	type testCase struct {
+		name     string
+		method   string
+		resource string
+		role     auth.Role
+		want     int
+	}
+	tests := []testCase{}
+	for key, roles := range allowed {
+		method, resource, _ := strings.Cut(key, ":")
+		for _, role := range auth.AllRoles {
+			want := 403
+			if auth.HasRole(role, roles) {
+				want = 200
+			}
+			tests = append(tests, testCase{
+				name:     key + " as " + string(role),
+				method:   method,
+				resource: resource,
+				role:     role,
+				want:     want,
+			})
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: tt.method,
+				Resource:   tt.resource,
+				RequestContext: events.APIGatewayProxyRequestContext{
+					Authorizer: map[string]interface{}{
+						"claims": map[string]interface{}{"custom:role": string(tt.role)},
+					},
+				},
+			}
+			got, err := handleRequest(context.Background(), request)
+			if err != nil {
+				t.Fatalf("handleRequest() error = %v", err)
+			}
+			if got.StatusCode != tt.want {
+				t.Errorf("handleRequest() status = %d, want %d", got.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleRequest_MissingClaims(t *testing.T) {
+	original := routes
+	routes = map[string]route{
+		"POST:/ledger": {
+			handler: func(_ context.Context, _ events.APIGatewayProxyRequest, deps endpoints.Dependencies) (events.APIGatewayProxyResponse, error) {
+				t.Fatal("handler must not run without a treasurer role")
+				return events.APIGatewayProxyResponse{}, nil
+			},
+			roles: treasurerRoles,
+		},
+	}
+	t.Cleanup(func() { routes = original })
+
+	tests := []struct {
+		name       string
+		authorizer map[string]interface{}
+	}{
+		{name: "No authorizer", authorizer: nil},
+		{name: "No claims", authorizer: map[string]interface{}{}},
+		{name: "No role claim", authorizer: map[string]interface{}{"claims": map[string]interface{}{}}},
+		{name: "Unknown role", authorizer: map[string]interface{}{"claims": map[string]interface{}{"custom:role": "admin"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod:     "POST",
+				Resource:       "/ledger",
+				RequestContext: events.APIGatewayProxyRequestContext{Authorizer: tt.authorizer},
+			}
+			got, err := handleRequest(context.Background(), request)
+			if err != nil {
+				t.Fatalf("handleRequest() error = %v", err)
+			}
+			if got.StatusCode != 403 {
+				t.Errorf("handleRequest() status = %d, want 403", got.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package auth
+
+import "strings"
+
+// Role is the committee role assigned to a user via the Cognito custom:role attribute
+type Role string
+
+const (
+	RoleNone      Role = "none"
+	RoleMember    Role = "member"
+	RoleCommittee Role = "committee"
+	RoleTreasurer Role = "treasurer"
+)
+
+// AllRoles lists every role known to the application
+var AllRoles = []Role{RoleNone, RoleMember, RoleCommittee, RoleTreasurer}
+
+const roleClaim = "custom:role"
+
+// RoleFromAuthorizer extracts the caller's role from the API Gateway authorizer context.
+// Unknown or missing roles resolve to RoleNone.
+func RoleFromAuthorizer(authorizer map[string]interface{}) Role {
+	claims, ok := authorizer["claims"].(map[string]interface{})
+	if !ok {
+		return RoleNone
+	}
+	value, ok := claims[roleClaim].(string)
+	if !ok {
+		return RoleNone
+	}
+	role := Role(strings.ToLower(strings.TrimSpace(value)))
+	for _, known := range AllRoles {
+		if role == known {
+			return role
+		}
+	}
+	return RoleNone
+}
+
+// HasRole reports whether role is one of allowed
+func HasRole(role Role, allowed []Role) bool {
+	for _, candidate := range allowed {
+		if role == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// UserFromAuthorizer returns the caller's email, or their Cognito username if the token has no
+// email, and an empty string when neither is present
+func UserFromAuthorizer(authorizer map[string]interface{}) string {
+	claims, ok := authorizer["claims"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, claim := range []string{"email", "cognito:username"} {
+		if value, ok := claims[claim].(string); ok && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/auth"
+)
+
+// receiptsPrefix is where receipts uploaded against a transaction are kept in the documents bucket
+const receiptsPrefix = "receipts/"
+
+// attachmentLinkLifetime matches the lifetime of the links DocumentsList hands out
+const attachmentLinkLifetime = 24 * time.Hour
+
+// Attachment references supporting evidence for a transaction by its path in the documents bucket
+type Attachment struct {
+	Path string `json:"path"`
+	// URL is a signed link to the document relative to the API, valid until Expires. Token, URL and
+	// Expires are added when a ledger is read and are never stored.
+	URL     string `json:"url,omitempty"`
+	Token   string `json:"token,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+}
+
+type ledgerAttachmentResponse struct {
+	Status       string      `json:"status"`
+	Month        string      `json:"month"`
+	Transaction  Transaction `json:"transaction"`
+	JournalStale bool        `json:"journalStale,omitempty"`
+}
+
+// signAttachments gives every attachment in ledger a signed link to its document
+func signAttachments(ledger *MonthlyLedger, secret string, now time.Time) {
+	expires := now.Add(attachmentLinkLifetime).Unix()
+	for i := range ledger.Transactions {
+		for j := range ledger.Transactions[i].Attachments {
+			attachment := &ledger.Transactions[i].Attachments[j]
+			attachment.Token = auth.GenerateToken(attachment.Path, expires, secret)
+			attachment.Expires = expires
+			attachment.URL = fmt.Sprintf("/documents/raw?path=%s&token=%s&expires=%d", url.QueryEscape(attachment.Path), attachment.Token, expires)
+		}
+	}
+}
+
+// stripAttachmentLinks removes the signed links a client sends back with a ledger it read
+func stripAttachmentLinks(ledgers []MonthlyLedger) {
+	for i := range ledgers {
+		for j := range ledgers[i].Transactions {
+			for k := range ledgers[i].Transactions[j].Attachments {
+				ledgers[i].Transactions[j].Attachments[k] = Attachment{Path: ledgers[i].Transactions[j].Attachments[k].Path}
+			}
+		}
+	}
+}
+
+// validAttachmentPath reports whether path names a document without leaving the documents bucket
+func validAttachmentPath(path string) bool {
+	return path != "" && !strings.HasPrefix(path, "/") && !strings.Contains(path, "..")
+}
+
+// requestBodyReader returns the request body, decoding it if API Gateway base64 encoded it, and
+// its decoded size or -1 if that is not known
+func requestBodyReader(request events.APIGatewayProxyRequest) (io.Reader, int64) {
+	var body io.Reader = strings.NewReader(request.Body)
+	size := int64(len(request.Body))
+	if request.IsBase64Encoded {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+		size = decodedBase64Len(request.Body)
+	}
+	return body, size
+}
+
+// LedgerAttachmentPost uploads a receipt to the documents bucket and attaches it to the
+// transaction named by the type, month and transactionId parameters. The name parameter is the
+// receipt's file name. The ledger month must not be locked.
+func LedgerAttachmentPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	ledgerType, month, badRequest := ledgerMonthParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	transactionID := request.QueryStringParameters["transactionId"]
+	name := strings.TrimSpace(request.QueryStringParameters["name"])
+	switch {
+	case transactionID == "":
+		return events.APIGatewayProxyResponse{Body: `{"error": "Transaction ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	case name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, ".."):
+		return events.APIGatewayProxyResponse{Body: `{"error": "Name must be a file name"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+
+	stored, err := loadLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	ledger := ledgerMonthForUpdate(stored, ledgerType, month)
+	index := -1
+	for i, tx := range ledger.Transactions {
+		if tx.ID == transactionID {
+			index = i
+		}
+	}
+	if index < 0 {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Transaction not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	// Check the lock before uploading so a refused attachment leaves no stray document behind
+	locks, err := loadLedgerLocks(ctx, deps, ledgerType)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if reason := locks.reason(month); reason != "" {
+		return ledgerSaveErrorResponse(&ledgerLockedError{Months: []string{month}, Reasons: []string{reason}}, []string{}, deps.Headers), nil
+	}
+
+	path := fmt.Sprintf("%s%s/%s/%s-%s", receiptsPrefix, ledgerType, month, transactionID, name)
+	body, size := requestBodyReader(request)
+	if err := deps.Storage.SaveReader(ctx, path, body, size); err != nil {
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			return events.APIGatewayProxyResponse{Body: `{"error": "Invalid base64 body"}`, StatusCode: 400, Headers: deps.Headers}, nil
+		}
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+
+	tx := &ledger.Transactions[index]
+	attached := false
+	for _, attachment := range tx.Attachments {
+		attached = attached || attachment.Path == path
+	}
+	response := ledgerAttachmentResponse{Status: "ok", Month: month}
+	if !attached {
+		tx.Attachments = append(tx.Attachments, Attachment{Path: path})
+		result, err := saveLedgers(ctx, deps, ledgerType, []MonthlyLedger{ledger}, true)
+		if err != nil {
+			fmt.Printf("Uploaded %s but could not attach it: %v\n", path, err)
+			return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
+		}
+		response.JournalStale = result.JournalStale
+	}
+
+	fmt.Printf("Attached %s to %s %s transaction %s\n", path, ledgerType, month, transactionID)
+	signAttachments(&ledger, deps.SigningSecret, time.Now())
+	response.Transaction = ledger.Transactions[index]
+	responseBody, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{Body: string(responseBody), StatusCode: 200, Headers: deps.Headers}, nil
+}
@@ -0,0 +1,89 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/auth"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+func TestLedgerAttachmentPost(t *testing.T) {
+	ctx := context.Background()
+	documents, err := storage.NewLocalStorageProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledgers := journalTestLedgers()
+	deps := Dependencies{Storage: documents, Data: newTestDataProvider(t, ledgers...), SigningSecret: "secret", Headers: DefaultHeaders()}
+	attach := func(params map[string]string, body string) events.APIGatewayProxyResponse {
+		t.Helper()
+		got, err := LedgerAttachmentPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: params, Body: base64.StdEncoding.EncodeToString([]byte(body)), IsBase64Encoded: true}, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	params := map[string]string{"type": "BANK", "month": "2025-01", "transactionId": "hall", "name": "hall-invoice.pdf"}
+	got := attach(params, "%PDF-1.4")
+	if got.StatusCode != 200 {
+		t.Fatalf("LedgerAttachmentPost() = %d %s", got.StatusCode, got.Body)
+	}
+	var response ledgerAttachmentResponse
+	if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+		t.Fatal(err)
+	}
+	const path = "receipts/BANK/2025-01/hall-hall-invoice.pdf"
+	if len(response.Transaction.Attachments) != 1 || response.Transaction.Attachments[0].Path != path {
+		t.Fatalf("transaction = %+v", response.Transaction)
+	}
+	content, err := documents.Get(ctx, path)
+	if err != nil || string(content) != "%PDF-1.4" {
+		t.Errorf("receipt = %q, %v", content, err)
+	}
+
+	// Reading the ledger signs the link, and saving it back stores only the path
+	got, err = LedgerGet(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK", "month": "2025-01"}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerGet() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var january MonthlyLedger
+	if err := json.Unmarshal([]byte(got.Body), &january); err != nil {
+		t.Fatal(err)
+	}
+	attachment := january.Transactions[1].Attachments[0]
+	link, err := url.Parse(attachment.URL)
+	if err != nil || link.Path != "/documents/raw" || link.Query().Get("path") != path || !auth.VerifyToken(path, attachment.Expires, link.Query().Get("token"), "secret") {
+		t.Errorf("attachment = %+v", attachment)
+	}
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, []MonthlyLedger{january})}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	stored, err := loadLedgerMonths(ctx, deps, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachments := stored[0].Transactions[1].Attachments; len(attachments) != 1 || attachments[0] != (Attachment{Path: path}) {
+		t.Errorf("stored attachments = %+v", attachments)
+	}
+
+	missing := map[string]string{"type": "BANK", "month": "2025-01", "transactionId": "nope", "name": "receipt.pdf"}
+	if got := attach(missing, "x"); got.StatusCode != 404 {
+		t.Errorf("LedgerAttachmentPost() for unknown transaction = %d %s", got.StatusCode, got.Body)
+	}
+	escaping := map[string]string{"type": "BANK", "month": "2025-01", "transactionId": "hall", "name": "../ledger.json"}
+	if got := attach(escaping, "x"); got.StatusCode != 400 {
+		t.Errorf("LedgerAttachmentPost() with path in name = %d %s", got.StatusCode, got.Body)
+	}
+	january.Transactions[0].Attachments = []Attachment{{Path: "../data/ledger/BANK/2025-01.json"}}
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, []MonthlyLedger{january})}, deps)
+	if err != nil || got.StatusCode != 422 {
+		t.Errorf("LedgerPost() with escaping attachment = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+}
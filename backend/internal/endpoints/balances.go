@@ -0,0 +1,212 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+// recalculateLedger orders transactions by date and recomputes running balances and the
+// closing balance from the opening balance. It reports whether anything changed.
+func recalculateLedger(ledger *MonthlyLedger) bool {
+	changed := false
+	sort.SliceStable(ledger.Transactions, func(i, j int) bool {
+		return ledger.Transactions[i].Date < ledger.Transactions[j].Date
+	})
+	balance := ledger.OpeningBalance
+	for i := range ledger.Transactions {
+		balance += ledger.Transactions[i].Amount
+		if ledger.Transactions[i].RunningBalance != balance {
+			ledger.Transactions[i].RunningBalance = balance
+			changed = true
+		}
+	}
+	if ledger.ClosingBalance != balance {
+		ledger.ClosingBalance = balance
+		changed = true
+	}
+	return changed
+}
+
+// cascadeBalances rolls balances forward through ledgers sorted by month. The first month keeps
+// its opening balance and each later month opens at the previous month's closing balance.
+// It returns the months whose balances changed.
+func cascadeBalances(ledgers []MonthlyLedger) []string {
+	changed := []string{}
+	for i := range ledgers {
+		monthChanged := false
+		if i > 0 && ledgers[i].OpeningBalance != ledgers[i-1].ClosingBalance {
+			ledgers[i].OpeningBalance = ledgers[i-1].ClosingBalance
+			monthChanged = true
+		}
+		if recalculateLedger(&ledgers[i]) {
+			monthChanged = true
+		}
+		if monthChanged {
+			changed = append(changed, ledgers[i].Month)
+		}
+	}
+	return changed
+}
+
+// ledgerConflictError reports months whose submitted version is older than the stored one
+type ledgerConflictError struct {
+	Months []string
+}
+
+func (e *ledgerConflictError) Error() string {
+	return fmt.Sprintf("ledger months %v were modified by another request", e.Months)
+}
+
+func (e *ledgerConflictError) Unwrap() error {
+	return storage.ErrPreconditionFailed
+}
+
+// plannedLedgerWrite is one month that saving a set of ledgers will write
+type plannedLedgerWrite struct {
+	Ledger MonthlyLedger
+	// RolledForward is set for stored months rewritten only because their balances changed
+	RolledForward bool
+}
+
+// planLedgerSave works out which months saving ledgers over the stored months of the same type
+// will write, in month order, with balances rolled forward through every later stored month.
+// Versions are left as stored. When checkVersions is set, each submitted month must carry the
+// currently stored version.
+func planLedgerSave(stored []MonthlyLedger, ledgers []MonthlyLedger, checkVersions bool) ([]plannedLedgerWrite, error) {
+	planned := []plannedLedgerWrite{}
+	if len(ledgers) == 0 {
+		return planned, nil
+	}
+	storedVersions := make(map[string]int64, len(stored))
+	for _, ledger := range stored {
+		storedVersions[ledger.Month] = ledger.Version
+	}
+
+	submitted := make(map[string]bool, len(ledgers))
+	chain := make([]MonthlyLedger, 0, len(ledgers)+len(stored))
+	conflicts := []string{}
+	for _, ledger := range ledgers {
+		if checkVersions && ledger.Version != storedVersions[ledger.Month] {
+			conflicts = append(conflicts, ledger.Month)
+		}
+		ledger.Version = storedVersions[ledger.Month]
+		submitted[ledger.Month] = true
+		chain = append(chain, ledger)
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return planned, &ledgerConflictError{Months: conflicts}
+	}
+	sort.Slice(chain, func(i, j int) bool {
+		return chain[i].Month < chain[j].Month
+	})
+	firstMonth := chain[0].Month
+	for _, ledger := range stored {
+		if ledger.Month > firstMonth && !submitted[ledger.Month] {
+			chain = append(chain, ledger)
+		}
+	}
+	sort.Slice(chain, func(i, j int) bool {
+		return chain[i].Month < chain[j].Month
+	})
+
+	changed := map[string]bool{}
+	for _, month := range cascadeBalances(chain) {
+		changed[month] = true
+	}
+	for _, ledger := range chain {
+		rolledForward := !submitted[ledger.Month]
+		if rolledForward && !changed[ledger.Month] {
+			continue
+		}
+		planned = append(planned, plannedLedgerWrite{Ledger: ledger, RolledForward: rolledForward})
+	}
+	return planned, nil
+}
+
+// lockedLedgerWrites returns a *ledgerLockedError naming the planned months that are locked, or
+// nil if there are none
+func lockedLedgerWrites(locks ledgerLocks, planned []plannedLedgerWrite) *ledgerLockedError {
+	blocked := &ledgerLockedError{}
+	for _, write := range planned {
+		if reason := locks.reason(write.Ledger.Month); reason != "" {
+			blocked.Months = append(blocked.Months, write.Ledger.Month)
+			blocked.Reasons = append(blocked.Reasons, reason)
+		}
+	}
+	if len(blocked.Months) == 0 {
+		return nil
+	}
+	return blocked
+}
+
+// ledgerSaveResult lists what saveLedgers wrote
+type ledgerSaveResult struct {
+	// Written is every month written, in order
+	Written []string
+	// Cascaded is the subset of stored months rewritten only because their balances changed
+	Cascaded []string
+	// JournalStale is set when the months were saved but the journal could not be brought into
+	// step with them, so journal-based reports are behind until the journal is migrated again
+	JournalStale bool
+}
+
+// saveLedgers writes the given months of one ledger type and rolls the balances of every later
+// stored month forward from them. On error the written months identify how much of the update
+// was applied.
+//
+// When checkVersions is set, each submitted month must carry the currently stored version.
+// Nothing is written if any month to be written, including rolled-forward ones, is locked.
+// Every write is conditional on the object being unchanged since it was read, so a concurrent
+// writer causes storage.ErrPreconditionFailed rather than a lost update.
+func saveLedgers(ctx context.Context, deps Dependencies, ledgerType string, ledgers []MonthlyLedger, checkVersions bool) (ledgerSaveResult, error) {
+	result := ledgerSaveResult{Written: []string{}, Cascaded: []string{}}
+	if len(ledgers) == 0 {
+		return result, nil
+	}
+
+	stored, etags, err := loadLedgerMonthsWithETags(ctx, deps, ledgerType)
+	if err != nil {
+		return result, err
+	}
+	planned, err := planLedgerSave(stored, ledgers, checkVersions)
+	if err != nil {
+		return result, err
+	}
+	locks, err := loadLedgerLocks(ctx, deps, ledgerType)
+	if err != nil {
+		return result, err
+	}
+	if blocked := lockedLedgerWrites(locks, planned); blocked != nil {
+		return result, blocked
+	}
+
+	dirPath := ledgerPrefix + ledgerType
+	for _, write := range planned {
+		ledger := write.Ledger
+		ledger.Version++
+		path := fmt.Sprintf("%s/%s.json", dirPath, ledger.Month)
+		content, _ := json.Marshal(ledger)
+		if err := deps.Data.SaveIfMatch(ctx, path, content, etags[ledger.Month]); err != nil {
+			return result, err
+		}
+		result.Written = append(result.Written, ledger.Month)
+		if write.RolledForward {
+			result.Cascaded = append(result.Cascaded, ledger.Month)
+		}
+	}
+	if len(result.Cascaded) > 0 {
+		fmt.Printf("Rolled %s balances forward through %v\n", ledgerType, result.Cascaded)
+	}
+	// The ledger is the record of its cash movements, so its months stay saved when the journal
+	// falls behind; the caller reports that instead of failing the write
+	if err := syncLedgerJournal(ctx, deps, ledgerType); err != nil {
+		fmt.Printf("Journal sync for %s failed: %v\n", ledgerType, err)
+		result.JournalStale = true
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+func TestLedgerPost_CascadesLaterMonths(t *testing.T) {
+	prov := newTestDataProvider(t,
+		MonthlyLedger{Month: "2024-12", Type: "BANK", OpeningBalance: 0, ClosingBalance: 1000,
+			Transactions: []Transaction{{ID: "dec-1", Date: "2024-12-01", Amount: 1000, RunningBalance: 1000}}},
+		MonthlyLedger{Month: "2025-01", Type: "BANK", OpeningBalance: 1000, ClosingBalance: 1500,
+			Transactions: []Transaction{{ID: "jan-1", Date: "2025-01-10", Amount: 500, RunningBalance: 1500}}},
+		MonthlyLedger{Month: "2025-02", Type: "BANK", OpeningBalance: 1500, ClosingBalance: 1300,
+			Transactions: []Transaction{{ID: "feb-1", Date: "2025-02-03", Amount: -200, RunningBalance: 1300}}},
+		MonthlyLedger{Month: "2025-04", Type: "BANK", OpeningBalance: 1300, ClosingBalance: 1300},
+	)
+
+	edited := []MonthlyLedger{{
+		Month:          "2025-01",
+		Type:           "BANK",
+		OpeningBalance: 1000,
+		ClosingBalance: 1750,
+		Transactions: []Transaction{
+			{ID: "jan-2", Date: "2025-01-20", Amount: 250},
+			{ID: "jan-1", Date: "2025-01-10", Amount: 500},
+		},
+	}}
+	body, _ := json.Marshal(edited)
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"type": "BANK"},
+		Body:                  string(body),
+	}
+	got, err := LedgerPost(context.Background(), request, Dependencies{Data: prov})
+	if err != nil {
+		t.Fatalf("LedgerPost() error = %v", err)
+	}
+	if got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() status = %d, body = %s", got.StatusCode, got.Body)
+	}
+	var response ledgerPostResponse
+	if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+		t.Fatal(err)
+	}
+	wantResponse := ledgerPostResponse{Status: "ok", Months: []string{"2025-01", "2025-02", "2025-04"}, CascadedMonths: []string{"2025-02", "2025-04"}}
+	if !reflect.DeepEqual(response, wantResponse) {
+		t.Errorf("LedgerPost() response = %+v, want %+v", response, wantResponse)
+	}
+
+	stored, err := loadLedgerMonths(context.Background(), Dependencies{Data: prov}, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBalances := map[string][2]money.Cents{
+		"2024-12": {0, 1000},
+		"2025-01": {1000, 1750},
+		"2025-02": {1750, 1550},
+		"2025-04": {1550, 1550},
+	}
+	for _, ledger := range stored {
+		want := wantBalances[ledger.Month]
+		if ledger.OpeningBalance != want[0] || ledger.ClosingBalance != want[1] {
+			t.Errorf("%s balances = %s/%s, want %s/%s", ledger.Month, ledger.OpeningBalance, ledger.ClosingBalance, want[0], want[1])
+		}
+	}
+	january := stored[1]
+	if january.Transactions[0].ID != "jan-1" || january.Transactions[1].RunningBalance != 1750 {
+		t.Errorf("2025-01 running balances not recomputed: %+v", january.Transactions)
+	}
+	if stored[2].Transactions[0].RunningBalance != 1550 {
+		t.Errorf("2025-02 running balance = %s, want 15.50", stored[2].Transactions[0].RunningBalance)
+	}
+}
+
+func TestCascadeBalances_Unchanged(t *testing.T) {
+	ledgers := []MonthlyLedger{
+		{Month: "2025-01", OpeningBalance: 100, ClosingBalance: 150, Transactions: []Transaction{{Date: "2025-01-02", Amount: 50, RunningBalance: 150}}},
+		{Month: "2025-02", OpeningBalance: 150, ClosingBalance: 150},
+	}
+	if changed := cascadeBalances(ledgers); len(changed) != 0 {
+		t.Errorf("cascadeBalances() changed = %v, want none", changed)
+	}
+}
+
+func TestLedgerPost_Versioning(t *testing.T) {
+	prov := newTestDataProvider(t, MonthlyLedger{Month: "2025-01", Type: "BANK", Version: 3})
+
+	post := func(version int64) events.APIGatewayProxyResponse {
+		body, _ := json.Marshal([]MonthlyLedger{{Month: "2025-01", Type: "BANK", Version: version}})
+		request := events.APIGatewayProxyRequest{
+			QueryStringParameters: map[string]string{"type": "BANK"},
+			Body:                  string(body),
+		}
+		got, err := LedgerPost(context.Background(), request, Dependencies{Data: prov})
+		if err != nil {
+			t.Fatalf("LedgerPost() error = %v", err)
+		}
+		return got
+	}
+
+	stale := post(2)
+	if stale.StatusCode != 409 {
+		t.Fatalf("LedgerPost() with stale version status = %d, want 409", stale.StatusCode)
+	}
+	var conflict ledgerSaveErrorBody
+	if err := json.Unmarshal([]byte(stale.Body), &conflict); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(conflict.ConflictMonths, []string{"2025-01"}) {
+		t.Errorf("LedgerPost() conflictMonths = %v", conflict.ConflictMonths)
+	}
+
+	if current := post(3); current.StatusCode != 200 {
+		t.Fatalf("LedgerPost() with current version status = %d, body = %s", current.StatusCode, current.Body)
+	}
+	stored, err := loadLedgerMonths(context.Background(), Dependencies{Data: prov}, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored[0].Version != 4 {
+		t.Errorf("stored version = %d, want 4", stored[0].Version)
+	}
+
+	if replay := post(3); replay.StatusCode != 409 {
+		t.Errorf("LedgerPost() replaying version 3 status = %d, want 409", replay.StatusCode)
+	}
+}
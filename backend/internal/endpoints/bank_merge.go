@@ -0,0 +1,220 @@
+package endpoints
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+const (
+	// bankImportReplace overwrites every imported month with the rows from the statement, keeping
+	// the links of the stored transactions they replace
+	bankImportReplace = "replace"
+	// bankImportMerge keeps stored transactions and appends only rows not already recorded
+	bankImportMerge = "merge"
+)
+
+// bankImportConflict is an imported row that shares a date and amount with a stored transaction
+// but not its description, so it may be the same payment with an edited description
+type bankImportConflict struct {
+	Date                string      `json:"date"`
+	Amount              money.Cents `json:"amount"`
+	Description         string      `json:"description"`
+	ExistingID          string      `json:"existingId"`
+	ExistingDescription string      `json:"existingDescription"`
+}
+
+type bankImportMergeResult struct {
+	Matched     int
+	New         int
+	Conflicting int
+	Conflicts   []bankImportConflict
+	// added holds the IDs of imported transactions that were appended
+	added map[string]bool
+}
+
+// bankImportFingerprint identifies a transaction by date, amount and description, ignoring
+// case and whitespace differences in the description
+func bankImportFingerprint(date string, amount money.Cents, description string) string {
+	return fmt.Sprintf("%s|%d|%s", date, amount, strings.ToLower(strings.Join(strings.Fields(description), " ")))
+}
+
+// mergeBankImportLedgers folds generated months into the stored months of the same ledger.
+// Imported rows that match a stored transaction, by the statement's own transaction ID or by
+// fingerprint, keep the stored ID, category and description; unmatched rows are appended and
+// stored transactions missing from the statement are kept. A row that only matches a stored
+// transaction's date and amount is reported as a conflict and not appended. Balances are rolled
+// forward from the first month's opening balance, which is the stored one when that month
+// already exists. Stored months keep their version, so saving the result fails if another
+// request changes them first.
+func mergeBankImportLedgers(generated []MonthlyLedger, stored []MonthlyLedger) ([]MonthlyLedger, bankImportMergeResult) {
+	result := bankImportMergeResult{Conflicts: []bankImportConflict{}, added: map[string]bool{}}
+	storedByMonth := make(map[string]MonthlyLedger, len(stored))
+	for _, ledger := range stored {
+		storedByMonth[ledger.Month] = ledger
+	}
+
+	merged := make([]MonthlyLedger, 0, len(generated))
+	for _, incoming := range generated {
+		existing, ok := storedByMonth[incoming.Month]
+		if !ok {
+			result.New += len(incoming.Transactions)
+			for _, tx := range incoming.Transactions {
+				result.added[tx.ID] = true
+			}
+			merged = append(merged, incoming)
+			continue
+		}
+
+		matched := make(map[int]bool)
+		byID := make(map[string]int, len(existing.Transactions))
+		for j, tx := range existing.Transactions {
+			byID[tx.ID] = j
+		}
+		unmatched := make([]Transaction, 0, len(incoming.Transactions))
+		for _, tx := range incoming.Transactions {
+			if j, ok := byID[tx.ID]; ok && !matched[j] {
+				matched[j] = true
+				result.Matched++
+				continue
+			}
+			unmatched = append(unmatched, tx)
+		}
+
+		byFingerprint := make(map[string][]int)
+		for j, tx := range existing.Transactions {
+			if !matched[j] {
+				key := bankImportFingerprint(tx.Date, tx.Amount, tx.Description)
+				byFingerprint[key] = append(byFingerprint[key], j)
+			}
+		}
+		pending := make([]Transaction, 0, len(unmatched))
+		for _, tx := range unmatched {
+			key := bankImportFingerprint(tx.Date, tx.Amount, tx.Description)
+			if candidates := byFingerprint[key]; len(candidates) > 0 {
+				byFingerprint[key] = candidates[1:]
+				matched[candidates[0]] = true
+				result.Matched++
+				continue
+			}
+			pending = append(pending, tx)
+		}
+
+		transactions := append([]Transaction{}, existing.Transactions...)
+		for _, tx := range pending {
+			conflict := -1
+			for j, stored := range existing.Transactions {
+				if !matched[j] && stored.Date == tx.Date && stored.Amount == tx.Amount {
+					conflict = j
+					break
+				}
+			}
+			if conflict < 0 {
+				result.New++
+				result.added[tx.ID] = true
+				transactions = append(transactions, tx)
+				continue
+			}
+			matched[conflict] = true
+			result.Conflicting++
+			result.Conflicts = append(result.Conflicts, bankImportConflict{
+				Date:                tx.Date,
+				Amount:              tx.Amount,
+				Description:         tx.Description,
+				ExistingID:          existing.Transactions[conflict].ID,
+				ExistingDescription: existing.Transactions[conflict].Description,
+			})
+		}
+
+		ledger := existing
+		ledger.PK = incoming.PK
+		ledger.Type = incoming.Type
+		ledger.Transactions = transactions
+		merged = append(merged, ledger)
+	}
+	cascadeBalances(merged)
+	return merged, result
+}
+
+// linkedTransactionLabel names what a stored transaction is linked to, or is empty if it is not
+// linked to anything a statement row cannot recreate
+func linkedTransactionLabel(tx Transaction) string {
+	switch {
+	case tx.TransferID != "":
+		return "a transfer"
+	case tx.ReimbursementID != "":
+		return "a reimbursement claim"
+	case tx.TrustFundID != "":
+		return "a trust fund"
+	case len(tx.Attachments) > 0:
+		return "attachments"
+	}
+	return ""
+}
+
+// keepBankImportLinks carries the stored transactions' links onto the statement rows that replace
+// them, so replacing a month does not break transfers, reimbursement payments, trust fund tags,
+// attachments or register links by transaction ID. Rows are paired with stored transactions by
+// the statement's own transaction ID, then by fingerprint, then by date and amount; a paired row
+// takes the stored ID and links, and a transfer or reimbursement payment also keeps its stored
+// category. A linked stored
+// transaction that is not on the statement would be lost, so it is reported as a violation.
+func keepBankImportLinks(generated []MonthlyLedger, stored []MonthlyLedger) []ValidationViolation {
+	violations := []ValidationViolation{}
+	storedByMonth := make(map[string]MonthlyLedger, len(stored))
+	for _, ledger := range stored {
+		storedByMonth[ledger.Month] = ledger
+	}
+	for _, incoming := range generated {
+		existing, ok := storedByMonth[incoming.Month]
+		if !ok {
+			continue
+		}
+		paired := make(map[int]int, len(incoming.Transactions))
+		used := make(map[int]bool, len(existing.Transactions))
+		pair := func(key func(Transaction) string) {
+			candidates := make(map[string][]int)
+			for j, tx := range existing.Transactions {
+				if !used[j] {
+					candidates[key(tx)] = append(candidates[key(tx)], j)
+				}
+			}
+			for i, tx := range incoming.Transactions {
+				if _, ok := paired[i]; ok {
+					continue
+				}
+				if matches := candidates[key(tx)]; len(matches) > 0 {
+					candidates[key(tx)] = matches[1:]
+					paired[i] = matches[0]
+					used[matches[0]] = true
+				}
+			}
+		}
+		pair(func(tx Transaction) string { return tx.ID })
+		pair(func(tx Transaction) string { return bankImportFingerprint(tx.Date, tx.Amount, tx.Description) })
+		pair(func(tx Transaction) string { return fmt.Sprintf("%s|%d", tx.Date, tx.Amount) })
+
+		for i, j := range paired {
+			before := existing.Transactions[j]
+			tx := &incoming.Transactions[i]
+			tx.ID = before.ID
+			tx.TransferID, tx.ReimbursementID, tx.TrustFundID = before.TransferID, before.ReimbursementID, before.TrustFundID
+			tx.Attachments = before.Attachments
+			if before.TransferID != "" || before.ReimbursementID != "" {
+				tx.Category = before.Category
+			}
+		}
+		for j, tx := range existing.Transactions {
+			if label := linkedTransactionLabel(tx); label != "" && !used[j] {
+				violations = append(violations, ValidationViolation{
+					Month:         incoming.Month,
+					TransactionID: tx.ID,
+					Field:         "transactions",
+					Message:       fmt.Sprintf("Transaction is linked to %s and is not on the statement; import in merge mode or remove it first", label),
+				})
+			}
+		}
+	}
+	return violations
+}
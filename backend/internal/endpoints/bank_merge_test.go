@@ -0,0 +1,161 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestLedgerBankImport_Merge(t *testing.T) {
+	march := MonthlyLedger{
+		PK:             "LEDGER#BANK#2025-03",
+		Month:          "2025-03",
+		Type:           "BANK",
+		OpeningBalance: 10000,
+		ClosingBalance: 7500,
+		Version:        3,
+		Transactions: []Transaction{
+			{ID: "engraving", Date: "2025-03-04", Category: "Trophies", Description: "ENGRAVING  co", Amount: -4000, RunningBalance: 6000},
+			{ID: "cash-float", Date: "2025-03-05", Category: "Misc", Description: "Manual entry", Amount: 2000, RunningBalance: 8000},
+			{ID: "edited", Date: "2025-03-06", Category: "Equipment", Description: "Cones for juniors", Amount: -500, RunningBalance: 7500},
+		},
+	}
+	prov := newTestDataProvider(t, march)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+
+	csv := "07/03/2025,-1500,Permits\n" +
+		"06/03/2025,-5.00,SPORTSPOWER 123\n" +
+		"04/03/2025,-40.00,Engraving Co\n" +
+		"02/04/2025,100.00,Sponsor\n"
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"type": "BANK", "currentBalance": "95.00", "mode": "merge"},
+		Body:                  csv,
+	}
+	got, err := LedgerBankImport(context.Background(), request, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", got.StatusCode, got.Body)
+	}
+	var response bankImportResponse
+	if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Matched != 1 || response.New != 2 || response.Conflicting != 1 {
+		t.Errorf("counts = %d matched, %d new, %d conflicting", response.Matched, response.New, response.Conflicting)
+	}
+	wantConflicts := []bankImportConflict{{Date: "2025-03-06", Amount: -500, Description: "SPORTSPOWER 123", ExistingID: "edited", ExistingDescription: "Cones for juniors"}}
+	if !reflect.DeepEqual(response.Conflicts, wantConflicts) {
+		t.Errorf("conflicts = %+v, want %+v", response.Conflicts, wantConflicts)
+	}
+
+	content, err := prov.Get(context.Background(), "ledger/BANK/2025-03.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved MonthlyLedger
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, tx := range saved.Transactions {
+		ids = append(ids, tx.ID)
+	}
+	if len(ids) != 4 || ids[0] != "engraving" || ids[1] != "cash-float" || ids[2] != "edited" {
+		t.Errorf("transaction ids = %v", ids)
+	}
+	if saved.Transactions[0].Category != "Trophies" || saved.Transactions[3].Amount != -150000 {
+		t.Errorf("transactions = %+v", saved.Transactions)
+	}
+	if saved.OpeningBalance != 10000 || saved.ClosingBalance != -142500 || saved.Version != 4 {
+		t.Errorf("balances = %s, %s version %d", saved.OpeningBalance, saved.ClosingBalance, saved.Version)
+	}
+	if response.ClosingBalance != -132500 {
+		t.Errorf("closing balance = %s", response.ClosingBalance)
+	}
+}
+
+func TestLedgerBankImport_InvalidMode(t *testing.T) {
+	deps := Dependencies{Data: newTestDataProvider(t), Headers: DefaultHeaders()}
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"currentBalance": "1", "mode": "append"},
+		Body:                  "07/03/2025,-15.00,Permits\n",
+	}
+	got, err := LedgerBankImport(context.Background(), request, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StatusCode != 400 {
+		t.Errorf("status = %d, want 400", got.StatusCode)
+	}
+}
+
+func TestLedgerBankImport_ReplaceKeepsTransfers(t *testing.T) {
+	march := MonthlyLedger{PK: "LEDGER#BANK#2025-03", Month: "2025-03", Type: "BANK", OpeningBalance: 50000,
+		Transactions: []Transaction{{ID: "fees", Date: "2025-03-01", Category: "Membership", Description: "Fees", Amount: 4000}}}
+	recalculateLedger(&march)
+	prov := newTestDataProvider(t, march)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+	got, err := LedgerTransferPost(ctx, events.APIGatewayProxyRequest{Body: `{"from": "BANK", "to": "CASH", "date": "2025-03-14", "amount": "200.00"}`}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerTransferPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var transfer ledgerTransferResponse
+	if err := json.Unmarshal([]byte(got.Body), &transfer); err != nil {
+		t.Fatal(err)
+	}
+	sideIDs := map[string]string{}
+	for _, side := range transfer.Sides {
+		sideIDs[side.Type] = side.TransactionID
+	}
+	load := func(ledgerType string) MonthlyLedger {
+		t.Helper()
+		content, err := prov.Get(ctx, "ledger/"+ledgerType+"/2025-03.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ledger MonthlyLedger
+		if err := json.Unmarshal(content, &ledger); err != nil {
+			t.Fatal(err)
+		}
+		return ledger
+	}
+	replace := func(csv string) events.APIGatewayProxyResponse {
+		t.Helper()
+		got, err := LedgerBankImport(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK", "currentBalance": "340.00"}, Body: csv}, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// The statement's transfer row takes over the stored side, so the transfer keeps both sides
+	if got := replace("14/03/2025,-200.00,TFR TO CASH\n01/03/2025,40.00,Membership fees\n"); got.StatusCode != 200 {
+		t.Fatalf("replace = %d %s", got.StatusCode, got.Body)
+	}
+	saved := load("BANK")
+	if len(saved.Transactions) != 2 {
+		t.Fatalf("transactions = %+v", saved.Transactions)
+	}
+	if tx := saved.Transactions[1]; tx.ID != sideIDs["BANK"] || tx.TransferID != transfer.TransferID || tx.Category != transferCategory || tx.Description != "TFR TO CASH" {
+		t.Errorf("transfer side = %+v", tx)
+	}
+	if cash := load("CASH"); len(cash.Transactions) != 1 || cash.Transactions[0].TransferID != transfer.TransferID {
+		t.Errorf("cash = %+v", cash.Transactions)
+	}
+
+	// A statement without the transfer row would leave the other side behind
+	got = replace("01/03/2025,40.00,Membership fees\n")
+	if got.StatusCode != 422 || !strings.Contains(got.Body, "linked to a transfer") {
+		t.Errorf("replace without the transfer = %d %s", got.StatusCode, got.Body)
+	}
+	if saved := load("BANK"); len(saved.Transactions) != 2 {
+		t.Errorf("transactions after refused replace = %+v", saved.Transactions)
+	}
+}
@@ -0,0 +1,155 @@
+package endpoints
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+// bankImportStatement is a parsed statement file ready for buildBankImportLedgers
+type bankImportStatement struct {
+	Rows    []bankImportRow
+	Skipped []bankImportSkippedRow
+	// Balance is the statement's closing balance when the file reports one
+	Balance *money.Cents
+}
+
+// ofxTransaction collects the elements of one STMTTRN aggregate
+type ofxTransaction struct {
+	Line   int
+	Fields map[string]string
+}
+
+// parseOFXStatement reads the transactions and ledger balance from an OFX 1.x (SGML) or 2.x (XML)
+// statement. SGML leaves element end tags out, so every element's value is taken as the text up
+// to the next tag, which reads both versions the same way.
+func parseOFXStatement(content string) (bankImportStatement, error) {
+	statement := bankImportStatement{Rows: []bankImportRow{}, Skipped: []bankImportSkippedRow{}}
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return statement, fmt.Errorf("invalid ofx: missing <OFX> element")
+	}
+
+	transactions := []ofxTransaction{}
+	var current *ofxTransaction
+	inLedgerBalance := false
+	for pos := start; pos < len(content); {
+		open := strings.IndexByte(content[pos:], '<')
+		if open < 0 {
+			break
+		}
+		open += pos
+		end := strings.IndexByte(content[open:], '>')
+		if end < 0 {
+			return statement, fmt.Errorf("invalid ofx: unterminated tag on line %d", lineNumberAt(content, open))
+		}
+		end += open
+		tag := strings.ToUpper(strings.TrimSpace(content[open+1 : end]))
+		next := strings.IndexByte(content[end+1:], '<')
+		if next < 0 {
+			next = len(content)
+		} else {
+			next += end + 1
+		}
+		value := strings.TrimSpace(html.UnescapeString(content[end+1 : next]))
+		pos = next
+
+		switch tag {
+		case "STMTTRN":
+			current = &ofxTransaction{Line: lineNumberAt(content, open), Fields: map[string]string{}}
+		case "/STMTTRN":
+			if current != nil {
+				transactions = append(transactions, *current)
+				current = nil
+			}
+		case "LEDGERBAL":
+			inLedgerBalance = true
+		case "/LEDGERBAL":
+			inLedgerBalance = false
+		default:
+			if strings.HasPrefix(tag, "/") || value == "" {
+				continue
+			}
+			if current != nil {
+				if _, seen := current.Fields[tag]; !seen {
+					current.Fields[tag] = value
+				}
+			} else if inLedgerBalance && tag == "BALAMT" {
+				balance, err := money.Parse(normalizeAmountString(value))
+				if err != nil {
+					return statement, fmt.Errorf("invalid ofx: ledger balance %q", value)
+				}
+				statement.Balance = &balance
+			}
+		}
+	}
+
+	for _, tx := range transactions {
+		text := fmt.Sprintf("FITID %s", tx.Fields["FITID"])
+		skip := func(reason string) {
+			statement.Skipped = append(statement.Skipped, bankImportSkippedRow{Line: tx.Line, Reason: reason, Text: text})
+		}
+		date, ok := parseOFXDate(tx.Fields["DTPOSTED"])
+		if !ok {
+			skip(fmt.Sprintf("invalid date %q", tx.Fields["DTPOSTED"]))
+			continue
+		}
+		amountRaw := tx.Fields["TRNAMT"]
+		if amountRaw == "" {
+			skip("missing amount")
+			continue
+		}
+		amount, err := money.Parse(normalizeAmountString(amountRaw))
+		if err != nil {
+			skip(fmt.Sprintf("invalid amount %q", amountRaw))
+			continue
+		}
+		description := tx.Fields["NAME"]
+		if memo := tx.Fields["MEMO"]; memo != "" && !strings.EqualFold(memo, description) {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+		statement.Rows = append(statement.Rows, bankImportRow{
+			Date:        date,
+			Amount:      amount,
+			Description: description,
+			ID:          tx.Fields["FITID"],
+		})
+	}
+	orderBankImportRowsNewestFirst(statement.Rows)
+
+	if len(statement.Rows) == 0 {
+		return statement, fmt.Errorf("no transactions found")
+	}
+	return statement, nil
+}
+
+// parseOFXDate reads the date part of an OFX datetime such as 20250307120000.000[+10:AEST]
+func parseOFXDate(value string) (time.Time, bool) {
+	if len(value) < len("20060102") {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("20060102", value[:len("20060102")])
+	return date, err == nil
+}
+
+func lineNumberAt(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// orderBankImportRowsNewestFirst puts rows from an oldest-first statement into the newest-first
+// order buildBankImportLedgers expects and numbers them, so later rows on the same day count as newer
+func orderBankImportRowsNewestFirst(rows []bankImportRow) {
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Date.After(rows[j].Date)
+	})
+	for i := range rows {
+		rows[i].OrigIdx = i
+	}
+}
@@ -0,0 +1,187 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+const testOFXSGML = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>AUD
+<BANKTRANLIST>
+<DTSTART>20250301
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250304120000.000[+10:AEST]
+<TRNAMT>-40.00
+<FITID>2025030401
+<NAME>ENGRAVING CO
+<MEMO>Club trophies
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250310
+<TRNAMT>125.50
+<FITID>2025031001
+<NAME>TIDYHQ &amp; CO
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>2025
+<TRNAMT>-1.00
+<FITID>bad
+</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL>
+<BALAMT>585.50
+<DTASOF>20250331
+</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const testOFXXML = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20250402</DTPOSTED><TRNAMT>-45.00</TRNAMT><FITID>PP-1</FITID><NAME>Flowers</NAME></STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>-45.00</BALAMT><DTASOF>20250430</DTASOF></LEDGERBAL>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>
+`
+
+const testQIF = "!Type:Bank\r\n" +
+	"D03/03/2025\r\nT-15.00\r\nPPermits\r\nLEvents\r\n^\r\n" +
+	"D 5/03'25\r\nT1,200.00\r\nPLake Health Group\r\nMSponsorship\r\n^\r\n" +
+	"D31/02/2025\r\nT-1.00\r\nPBad date\r\n^\r\n"
+
+func TestParseBankImportStatement(t *testing.T) {
+	balance := func(c money.Cents) *money.Cents { return &c }
+	type row struct {
+		ID          string
+		Date        string
+		Amount      money.Cents
+		Description string
+	}
+	tests := []struct {
+		name        string
+		content     string
+		format      string
+		wantFormat  string
+		want        []row
+		wantBalance *money.Cents
+		wantSkipped []bankImportSkippedRow
+	}{
+		{
+			name:       "OFX 1.x SGML",
+			content:    testOFXSGML,
+			wantFormat: "ofx",
+			want: []row{
+				{ID: "2025031001", Date: "2025-03-10", Amount: 12550, Description: "TIDYHQ & CO"},
+				{ID: "2025030401", Date: "2025-03-04", Amount: -4000, Description: "ENGRAVING CO Club trophies"},
+			},
+			wantBalance: balance(58550),
+			wantSkipped: []bankImportSkippedRow{{Line: 25, Reason: `invalid date "2025"`, Text: "FITID bad"}},
+		},
+		{
+			name:        "OFX 2.x XML",
+			content:     testOFXXML,
+			format:      "OFX",
+			wantFormat:  "ofx",
+			want:        []row{{ID: "PP-1", Date: "2025-04-02", Amount: -4500, Description: "Flowers"}},
+			wantBalance: balance(-4500),
+			wantSkipped: []bankImportSkippedRow{},
+		},
+		{
+			name:       "QIF",
+			content:    testQIF,
+			wantFormat: "qif",
+			want: []row{
+				{Date: "2025-03-05", Amount: 120000, Description: "Lake Health Group Sponsorship"},
+				{Date: "2025-03-03", Amount: -1500, Description: "Permits"},
+			},
+			wantSkipped: []bankImportSkippedRow{{Line: 12, Reason: `invalid date "31/02/2025"`, Text: "31/02/2025 -1.00 Bad date"}},
+		},
+		{
+			name:        "CSV",
+			content:     "03/02/2025,-12.50,Coffee\n",
+			wantFormat:  "default",
+			want:        []row{{Date: "2025-02-03", Amount: -1250, Description: "Coffee"}},
+			wantSkipped: []bankImportSkippedRow{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, format, err := parseBankImportStatement(tt.content, tt.format)
+			if err != nil {
+				t.Fatalf("parseBankImportStatement() error = %v", err)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format, tt.wantFormat)
+			}
+			got := make([]row, 0, len(statement.Rows))
+			for _, r := range statement.Rows {
+				got = append(got, row{ID: r.ID, Date: r.Date.Format("2006-01-02"), Amount: r.Amount, Description: r.Description})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(statement.Balance, tt.wantBalance) {
+				t.Errorf("balance = %v, want %v", statement.Balance, tt.wantBalance)
+			}
+			if !reflect.DeepEqual(statement.Skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %+v, want %+v", statement.Skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestLedgerBankImport_OFX(t *testing.T) {
+	prov := newTestDataProvider(t)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"type": "CARD"},
+		Body:                  mustJSON(t, map[string]string{"csv": testOFXSGML, "mode": "merge"}),
+	}
+	for i := 0; i < 2; i++ {
+		got, err := LedgerBankImport(context.Background(), request, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.StatusCode != 200 {
+			t.Fatalf("status = %d: %s", got.StatusCode, got.Body)
+		}
+		var response bankImportResponse
+		if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Format != "ofx" || response.OpeningBalance != 50000 || response.ClosingBalance != 58550 {
+			t.Errorf("import %d response = %+v", i, response)
+		}
+		// Re-importing the same statement matches every transaction by FITID
+		if i == 1 && (response.Matched != 2 || response.New != 0) {
+			t.Errorf("re-import matched %d, new %d", response.Matched, response.New)
+		}
+	}
+
+	content, err := prov.Get(context.Background(), "ledger/CARD/2025-03.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved MonthlyLedger
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Transactions) != 2 || saved.Transactions[0].ID != "2025030401" || saved.Transactions[1].ID != "2025031001" {
+		t.Errorf("transactions = %+v", saved.Transactions)
+	}
+}
@@ -0,0 +1,289 @@
+package endpoints
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+// amountSign describes which direction a bank export treats as positive
+type amountSign int
+
+const (
+	// creditPositive exports show money in as positive and money out as negative
+	creditPositive amountSign = iota
+	// debitPositive exports show charges as positive, as most credit card statements do
+	debitPositive
+)
+
+// noColumn marks an unused column in a bankImportProfile
+const noColumn = -1
+
+// bankImportProfile describes the CSV layout of one bank's transaction export
+type bankImportProfile struct {
+	Name        string
+	DateColumn  int
+	DateLayouts []string
+	// AmountColumn holds a signed amount; set to noColumn when the export splits debits and credits
+	AmountColumn int
+	DebitColumn  int
+	CreditColumn int
+	// DescriptionColumns are joined with spaces; DescriptionToEnd extends the last one to the end of the row
+	DescriptionColumns []int
+	DescriptionToEnd   bool
+	// HeaderCell is the lower-case text of DateColumn in the export's header row, if it has one
+	HeaderCell string
+	Sign       amountSign
+}
+
+const (
+	defaultBankImportFormat = "default"
+	// ofxImportFormat and qifImportFormat are statement file formats rather than CSV layouts
+	ofxImportFormat = "ofx"
+	qifImportFormat = "qif"
+)
+
+// bankImportProfiles is the registry of supported export layouts, keyed by the format parameter.
+// All of these exports list transactions newest first.
+var bankImportProfiles = map[string]bankImportProfile{
+	// default is the original importer layout: date, signed amount, then everything else as the description
+	defaultBankImportFormat: {
+		Name:               "Generic (date, amount, description)",
+		DateColumn:         0,
+		DateLayouts:        []string{"02/01/2006"},
+		AmountColumn:       1,
+		DebitColumn:        noColumn,
+		CreditColumn:       noColumn,
+		DescriptionColumns: []int{2},
+		DescriptionToEnd:   true,
+	},
+	// CommBank NetBank: Date, Amount, Description, Balance (no header)
+	"commbank": {
+		Name:               "Commonwealth Bank",
+		DateColumn:         0,
+		DateLayouts:        []string{"02/01/2006"},
+		AmountColumn:       1,
+		DebitColumn:        noColumn,
+		CreditColumn:       noColumn,
+		DescriptionColumns: []int{2},
+	},
+	// Westpac: Bank Account, Date, Narrative, Debit Amount, Credit Amount, Balance, Categories, Serial
+	"westpac": {
+		Name:               "Westpac",
+		DateColumn:         1,
+		DateLayouts:        []string{"02/01/2006"},
+		AmountColumn:       noColumn,
+		DebitColumn:        3,
+		CreditColumn:       4,
+		DescriptionColumns: []int{2},
+		HeaderCell:         "date",
+	},
+	// NAB: Date, Amount, Account Number, (blank), Transaction Type, Transaction Details, Balance, Category, Merchant Name
+	"nab": {
+		Name:               "NAB",
+		DateColumn:         0,
+		DateLayouts:        []string{"02 Jan 06", "02 Jan 2006", "02/01/2006"},
+		AmountColumn:       1,
+		DebitColumn:        noColumn,
+		CreditColumn:       noColumn,
+		DescriptionColumns: []int{4, 5},
+		HeaderCell:         "date",
+	},
+	// ANZ: Date, Amount, Description (no header)
+	"anz": {
+		Name:               "ANZ",
+		DateColumn:         0,
+		DateLayouts:        []string{"02/01/2006"},
+		AmountColumn:       1,
+		DebitColumn:        noColumn,
+		CreditColumn:       noColumn,
+		DescriptionColumns: []int{2},
+	},
+	// St.George / Bank of Melbourne / BankSA: Date, Description, Debit, Credit, Balance
+	"stgeorge": {
+		Name:               "St.George",
+		DateColumn:         0,
+		DateLayouts:        []string{"02/01/2006"},
+		AmountColumn:       noColumn,
+		DebitColumn:        2,
+		CreditColumn:       3,
+		DescriptionColumns: []int{1},
+		HeaderCell:         "date",
+	},
+	// Bendigo Bank: Date, Amount, Description (no header)
+	"bendigo": {
+		Name:               "Bendigo Bank",
+		DateColumn:         0,
+		DateLayouts:        []string{"02/01/2006"},
+		AmountColumn:       1,
+		DebitColumn:        noColumn,
+		CreditColumn:       noColumn,
+		DescriptionColumns: []int{2},
+	},
+	// American Express: Date, Date Processed, Description, Amount with charges positive
+	"amex": {
+		Name:               "American Express",
+		DateColumn:         0,
+		DateLayouts:        []string{"02/01/2006"},
+		AmountColumn:       3,
+		DebitColumn:        noColumn,
+		CreditColumn:       noColumn,
+		DescriptionColumns: []int{2},
+		HeaderCell:         "date",
+		Sign:               debitPositive,
+	},
+}
+
+func lookupBankImportProfile(format string) (bankImportProfile, bool) {
+	key := strings.ToLower(strings.TrimSpace(format))
+	if key == "" {
+		key = defaultBankImportFormat
+	}
+	profile, ok := bankImportProfiles[key]
+	return profile, ok
+}
+
+func bankImportFormats() []string {
+	formats := make([]string, 0, len(bankImportProfiles)+2)
+	for key := range bankImportProfiles {
+		formats = append(formats, key)
+	}
+	formats = append(formats, ofxImportFormat, qifImportFormat)
+	sort.Strings(formats)
+	return formats
+}
+
+// detectBankImportFormat recognises OFX and QIF files by their headers and treats anything else
+// as CSV in the default layout
+func detectBankImportFormat(content string) string {
+	head := strings.ToUpper(strings.TrimSpace(content))
+	switch {
+	case strings.HasPrefix(head, "OFXHEADER") || strings.Contains(head, "<OFX>"):
+		return ofxImportFormat
+	case strings.HasPrefix(head, "!TYPE:") || strings.HasPrefix(head, "!OPTION:") || strings.HasPrefix(head, "!ACCOUNT"):
+		return qifImportFormat
+	}
+	return defaultBankImportFormat
+}
+
+// parseBankImportStatement parses content as the named format, detecting it when format is empty
+func parseBankImportStatement(content, format string) (bankImportStatement, string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = detectBankImportFormat(content)
+	}
+	switch format {
+	case ofxImportFormat:
+		statement, err := parseOFXStatement(content)
+		return statement, format, err
+	case qifImportFormat:
+		statement, err := parseQIFStatement(content)
+		return statement, format, err
+	}
+	profile, ok := lookupBankImportProfile(format)
+	if !ok {
+		return bankImportStatement{}, format, fmt.Errorf("unknown format %q; supported formats are %s", format, strings.Join(bankImportFormats(), ", "))
+	}
+	rows, skipped, err := parseBankImportRows(content, profile)
+	return bankImportStatement{Rows: rows, Skipped: skipped}, format, err
+}
+
+// requiredColumns is the number of cells a row needs for every mapped column to exist
+func (p bankImportProfile) requiredColumns() int {
+	highest := p.DateColumn
+	for _, column := range append([]int{p.AmountColumn, p.DebitColumn, p.CreditColumn}, p.DescriptionColumns...) {
+		if column > highest {
+			highest = column
+		}
+	}
+	return highest + 1
+}
+
+// isHeader reports whether record looks like a column header row rather than a transaction
+func (p bankImportProfile) isHeader(record []string) bool {
+	if p.DateColumn >= len(record) {
+		return false
+	}
+	cell := strings.ToLower(strings.TrimSpace(record[p.DateColumn]))
+	if p.HeaderCell != "" && cell == p.HeaderCell {
+		return true
+	}
+	return cell != "" && !strings.ContainsFunc(cell, unicode.IsDigit)
+}
+
+func (p bankImportProfile) parseDate(value string) (time.Time, bool) {
+	for _, layout := range p.DateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseAmount returns the amount of record with money in as positive
+func (p bankImportProfile) parseAmount(record []string) (money.Cents, error) {
+	var amount money.Cents
+	if p.AmountColumn != noColumn {
+		raw := normalizeAmountString(record[p.AmountColumn])
+		if raw == "" {
+			return 0, fmt.Errorf("missing amount")
+		}
+		parsed, err := money.Parse(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", record[p.AmountColumn])
+		}
+		amount = parsed
+	} else {
+		debit, debitOK, err := parseOptionalAmount(record[p.DebitColumn])
+		if err != nil {
+			return 0, fmt.Errorf("invalid debit amount %q", record[p.DebitColumn])
+		}
+		credit, creditOK, err := parseOptionalAmount(record[p.CreditColumn])
+		if err != nil {
+			return 0, fmt.Errorf("invalid credit amount %q", record[p.CreditColumn])
+		}
+		if !debitOK && !creditOK {
+			return 0, fmt.Errorf("missing amount")
+		}
+		amount = credit.Abs() - debit.Abs()
+	}
+	if p.Sign == debitPositive {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func parseOptionalAmount(value string) (money.Cents, bool, error) {
+	raw := normalizeAmountString(value)
+	if raw == "" {
+		return 0, false, nil
+	}
+	parsed, err := money.Parse(raw)
+	if err != nil {
+		return 0, false, err
+	}
+	return parsed, true, nil
+}
+
+func (p bankImportProfile) description(record []string) string {
+	parts := make([]string, 0, len(p.DescriptionColumns))
+	for i, column := range p.DescriptionColumns {
+		if column >= len(record) {
+			continue
+		}
+		end := column + 1
+		if p.DescriptionToEnd && i == len(p.DescriptionColumns)-1 {
+			end = len(record)
+		}
+		for _, cell := range record[column:end] {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				parts = append(parts, cell)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
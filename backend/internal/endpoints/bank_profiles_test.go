@@ -0,0 +1,166 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+func TestParseBankImportRows_Profiles(t *testing.T) {
+	type row struct {
+		Date        string
+		Amount      money.Cents
+		Description string
+	}
+	tests := []struct {
+		name        string
+		format      string
+		csv         string
+		want        []row
+		wantSkipped []bankImportSkippedRow
+	}{
+		{
+			name:   "Default keeps trailing columns in the description",
+			format: "",
+			csv:    "03/02/2025,-12.50,Coffee,Shop\n01/02/2025,100.00,TidyHQ payout\n",
+			want: []row{
+				{Date: "2025-02-03", Amount: -1250, Description: "Coffee Shop"},
+				{Date: "2025-02-01", Amount: 10000, Description: "TidyHQ payout"},
+			},
+			wantSkipped: []bankImportSkippedRow{},
+		},
+		{
+			name:   "CommBank ignores the balance column",
+			format: "commbank",
+			csv:    "03/02/2025,\"-1,012.50\",Trophies,\"+4,000.00\"\n",
+			want: []row{
+				{Date: "2025-02-03", Amount: -101250, Description: "Trophies"},
+			},
+			wantSkipped: []bankImportSkippedRow{},
+		},
+		{
+			name:   "Westpac splits debits and credits",
+			format: "westpac",
+			csv: "Bank Account,Date,Narrative,Debit Amount,Credit Amount,Balance,Categories,Serial\n" +
+				"032-000 123456,05/03/2025,Race entry refund,,25.00,500.00,OTHER,\n" +
+				"032-000 123456,04/03/2025,Engraving,40.00,,475.00,OTHER,\n",
+			want: []row{
+				{Date: "2025-03-05", Amount: 2500, Description: "Race entry refund"},
+				{Date: "2025-03-04", Amount: -4000, Description: "Engraving"},
+			},
+			wantSkipped: []bankImportSkippedRow{},
+		},
+		{
+			name:   "NAB joins type and details",
+			format: "NAB",
+			csv: "Date,Amount,Account Number,,Transaction Type,Transaction Details,Balance,Category,Merchant Name\n" +
+				"07 Mar 25,-30.00,123,,EFTPOS,Weed killer,100.00,,\n",
+			want: []row{
+				{Date: "2025-03-07", Amount: -3000, Description: "EFTPOS Weed killer"},
+			},
+			wantSkipped: []bankImportSkippedRow{},
+		},
+		{
+			name:   "Amex charges are positive",
+			format: "amex",
+			csv: "Date,Date Processed,Description,Amount\n" +
+				"10/03/2025,11/03/2025,Flowers,45.00\n" +
+				"12/03/2025,12/03/2025,Payment received,-45.00\n",
+			want: []row{
+				{Date: "2025-03-10", Amount: -4500, Description: "Flowers"},
+				{Date: "2025-03-12", Amount: 4500, Description: "Payment received"},
+			},
+			wantSkipped: []bankImportSkippedRow{},
+		},
+		{
+			name:   "Reports unparsable rows",
+			format: "stgeorge",
+			csv: "Date,Description,Debit,Credit,Balance\n" +
+				"31/02/2025,Bad date,1.00,,\n" +
+				"01/03/2025,No amount,,,\n" +
+				"02/03/2025,Short\n" +
+				"03/03/2025,Permits,15.00,,85.00\n",
+			want: []row{
+				{Date: "2025-03-03", Amount: -1500, Description: "Permits"},
+			},
+			wantSkipped: []bankImportSkippedRow{
+				{Line: 2, Reason: `invalid date "31/02/2025"`, Text: "31/02/2025,Bad date,1.00,,"},
+				{Line: 3, Reason: "missing amount", Text: "01/03/2025,No amount,,,"},
+				{Line: 4, Reason: "expected at least 4 columns", Text: "02/03/2025,Short"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, ok := lookupBankImportProfile(tt.format)
+			if !ok {
+				t.Fatalf("unknown format %q", tt.format)
+			}
+			rows, skipped, err := parseBankImportRows(tt.csv, profile)
+			if err != nil {
+				t.Fatalf("parseBankImportRows() error = %v", err)
+			}
+			got := make([]row, 0, len(rows))
+			for _, r := range rows {
+				got = append(got, row{Date: r.Date.Format("2006-01-02"), Amount: r.Amount, Description: r.Description})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %+v, want %+v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestLedgerBankImport_Format(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      map[string]string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "Unknown format",
+			query:      map[string]string{"type": "BANK", "currentBalance": "100", "format": "bogus"},
+			body:       "03/02/2025,-12.50,Coffee\n",
+			wantStatus: 400,
+		},
+		{
+			name:       "Format from body",
+			query:      map[string]string{},
+			body:       `{"type": "BANK", "currentBalance": "475.00", "format": "westpac", "csv": "Bank Account,Date,Narrative,Debit Amount,Credit Amount,Balance\n1,04/03/2025,Engraving,40.00,,475.00\n1,04/03/2025,,,,\nnot,a,row\n"}`,
+			wantStatus: 200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := Dependencies{Data: newTestDataProvider(t), Headers: DefaultHeaders()}
+			got, err := LedgerBankImport(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: tt.query, Body: tt.body}, deps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", got.StatusCode, tt.wantStatus, got.Body)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			var response bankImportResponse
+			if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Format != "westpac" || response.Transactions != 1 || len(response.SkippedRows) != 2 {
+				t.Errorf("response = %+v", response)
+			}
+			if response.OpeningBalance != 51500 || response.ClosingBalance != 47500 {
+				t.Errorf("balances = %s, %s", response.OpeningBalance, response.ClosingBalance)
+			}
+		})
+	}
+}
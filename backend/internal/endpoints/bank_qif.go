@@ -0,0 +1,124 @@
+package endpoints
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+// qifDateLayouts are tried in order. Australian exports write day before month; the apostrophe
+// some exporters use before two-digit years is normalised to a slash first.
+var qifDateLayouts = []string{"02/01/2006", "2/1/2006", "02/01/06", "2/1/06", "2006-01-02"}
+
+// parseQIFStatement reads the transactions of a QIF bank or credit card export. Each record is a
+// run of lines keyed by their first character and ends with ^. Investment and account-list sections
+// are ignored. QIF carries no balance, so the caller must still supply the current balance.
+func parseQIFStatement(content string) (bankImportStatement, error) {
+	statement := bankImportStatement{Rows: []bankImportRow{}, Skipped: []bankImportSkippedRow{}}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	section := ""
+	fields := map[string]string{}
+	recordLine := 0
+	lineNumber := 0
+
+	finish := func() {
+		defer func() {
+			fields = map[string]string{}
+			recordLine = 0
+		}()
+		if len(fields) == 0 || !isQIFCashSection(section) {
+			return
+		}
+		text := strings.TrimSpace(fields["D"] + " " + fields["T"] + " " + fields["P"])
+		skip := func(reason string) {
+			statement.Skipped = append(statement.Skipped, bankImportSkippedRow{Line: recordLine, Reason: reason, Text: text})
+		}
+		date, ok := parseQIFDate(fields["D"])
+		if !ok {
+			skip(fmt.Sprintf("invalid date %q", fields["D"]))
+			return
+		}
+		amountRaw := fields["T"]
+		if amountRaw == "" {
+			amountRaw = fields["U"]
+		}
+		if amountRaw == "" {
+			skip("missing amount")
+			return
+		}
+		amount, err := money.Parse(normalizeAmountString(amountRaw))
+		if err != nil {
+			skip(fmt.Sprintf("invalid amount %q", amountRaw))
+			return
+		}
+		description := fields["P"]
+		if memo := fields["M"]; memo != "" && !strings.EqualFold(memo, description) {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+		statement.Rows = append(statement.Rows, bankImportRow{
+			Date:        date,
+			Amount:      amount,
+			Description: description,
+		})
+	}
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "!"):
+			finish()
+			if header := strings.ToLower(line); strings.HasPrefix(header, "!type:") {
+				section = strings.TrimSpace(strings.TrimPrefix(header, "!type:"))
+			} else if !strings.HasPrefix(header, "!option") && !strings.HasPrefix(header, "!clear") {
+				section = header
+			}
+		case line == "^":
+			finish()
+		default:
+			if recordLine == 0 {
+				recordLine = lineNumber
+			}
+			code := line[:1]
+			// Split transactions repeat S, E and $; only the first of each field is the transaction's own
+			if _, seen := fields[code]; !seen {
+				fields[code] = strings.TrimSpace(line[1:])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return statement, fmt.Errorf("invalid qif: %w", err)
+	}
+	finish()
+	orderBankImportRowsNewestFirst(statement.Rows)
+
+	if len(statement.Rows) == 0 {
+		return statement, fmt.Errorf("no transactions found")
+	}
+	return statement, nil
+}
+
+func isQIFCashSection(section string) bool {
+	switch section {
+	// Files without a !Type header are treated as bank accounts
+	case "", "bank", "cash", "ccard", "oth a", "oth l":
+		return true
+	}
+	return false
+}
+
+func parseQIFDate(value string) (time.Time, bool) {
+	value = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(value), "'", "/"), " ", "")
+	for _, layout := range qifDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,571 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const categoriesPath = "categories.json"
+
+// Category kinds place a category's transactions in the financial report
+const (
+	categoryIncome    = "income"
+	categoryExpense   = "expense"
+	categoryAsset     = "asset"
+	categoryLiability = "liability"
+	categoryEquity    = "equity"
+	// categoryTransfer moves money between the club's own ledgers and is neither income nor expense
+	categoryTransfer = "transfer"
+)
+
+// GST treatments; an empty treatment means it has not been set
+var categoryGSTTreatments = map[string]bool{"": true, "gst": true, "gst-free": true, "input-taxed": true, "bas-excluded": true}
+
+var categoryKinds = map[string]bool{categoryIncome: true, categoryExpense: true, categoryAsset: true, categoryLiability: true, categoryEquity: true, categoryTransfer: true}
+
+// Category is one entry in the chart of accounts. Transactions refer to categories by name, so
+// renaming one either rewrites them or keeps the old name in Aliases.
+type Category struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Code     string `json:"code,omitempty"`
+	Kind     string `json:"kind"`
+	ParentID string `json:"parentId,omitempty"`
+	Active   bool   `json:"active"`
+	GST      string `json:"gst,omitempty"`
+	// Aliases are former names that historical transactions may still use
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+var defaultCategories = []Category{
+	{ID: "membership", Name: "Membership", Kind: categoryIncome, Active: true},
+	{ID: "event-fee", Name: "Event Fee", Kind: categoryIncome, Active: true},
+	{ID: "equipment", Name: "Equipment", Kind: categoryExpense, Active: true},
+	{ID: "reimbursement", Name: "Reimbursement", Kind: categoryExpense, Active: true},
+	{ID: "sponsorship", Name: "Sponsorship", Kind: categoryIncome, Active: true},
+	{ID: "misc", Name: "Misc", Kind: categoryExpense, Active: true},
+	{ID: "transfer", Name: transferCategory, Kind: categoryTransfer, Active: true},
+}
+
+type categoryMergeRequest struct {
+	SourceID            string `json:"sourceId"`
+	TargetID            string `json:"targetId"`
+	RewriteTransactions bool   `json:"rewriteTransactions"`
+}
+
+type categoryChangeResponse struct {
+	Status   string   `json:"status"`
+	Category Category `json:"category"`
+	// RewrittenMonths lists TYPE/YYYY-MM for every ledger month whose transactions were renamed
+	RewrittenMonths []string `json:"rewrittenMonths"`
+	// JournalStale is set when rewritten months were saved but the journal could not be updated
+	JournalStale bool `json:"journalStale,omitempty"`
+}
+
+// categoryIndex resolves the category names used by transactions, including former names
+type categoryIndex map[string]Category
+
+func newCategoryIndex(categories []Category) categoryIndex {
+	index := categoryIndex{}
+	for _, category := range categories {
+		for _, alias := range category.Aliases {
+			index[strings.ToLower(alias)] = category
+		}
+	}
+	for _, category := range categories {
+		index[strings.ToLower(category.Name)] = category
+	}
+	return index
+}
+
+func (c categoryIndex) lookup(name string) (Category, bool) {
+	category, ok := c[strings.ToLower(strings.TrimSpace(name))]
+	return category, ok
+}
+
+func categorySlug(name string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name)), "-")
+}
+
+// loadCategories reads the chart of accounts and its ETag. Older files hold a bare array of names;
+// those become active categories with IDs derived from the name, and with the default kinds where
+// the name is a default category. An empty ETag means nothing is stored yet.
+func loadCategories(ctx context.Context, deps Dependencies) ([]Category, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, categoriesPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return append([]Category{}, defaultCategories...), "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var stored []Category
+	if err := json.Unmarshal(content, &stored); err == nil {
+		return stored, etag, nil
+	}
+	// Before categories had kinds the file was a plain list of names
+	var names []string
+	if err := json.Unmarshal(content, &names); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %w", categoriesPath, err)
+	}
+	defaults := newCategoryIndex(defaultCategories)
+	categories := make([]Category, 0, len(names))
+	for _, name := range names {
+		category := Category{ID: categorySlug(name), Name: name, Active: true}
+		if known, ok := defaults.lookup(name); ok {
+			category.Kind = known.Kind
+		}
+		categories = append(categories, category)
+	}
+	return categories, etag, nil
+}
+
+func saveCategories(ctx context.Context, deps Dependencies, categories []Category, etag string) error {
+	content, _ := json.Marshal(categories)
+	return deps.Data.SaveIfMatch(ctx, categoriesPath, content, etag)
+}
+
+// validateCategory checks category on its own and against the others in the chart
+func validateCategory(category Category, others []Category) []ValidationViolation {
+	violations := []ValidationViolation{}
+	if strings.TrimSpace(category.Name) == "" {
+		violations = append(violations, ValidationViolation{Field: "name", Message: "Name is required"})
+	}
+	if !categoryKinds[category.Kind] {
+		violations = append(violations, ValidationViolation{Field: "kind", Message: "Kind must be income, expense, asset, liability, equity or transfer"})
+	}
+	if !categoryGSTTreatments[category.GST] {
+		violations = append(violations, ValidationViolation{Field: "gst", Message: "GST must be gst, gst-free, input-taxed or bas-excluded"})
+	}
+
+	byID := map[string]Category{}
+	for _, other := range others {
+		byID[other.ID] = other
+		if strings.EqualFold(other.Name, category.Name) {
+			violations = append(violations, ValidationViolation{Field: "name", Message: fmt.Sprintf("Name %q is already used", category.Name)})
+		}
+		if category.Code != "" && other.Code == category.Code {
+			violations = append(violations, ValidationViolation{Field: "code", Message: fmt.Sprintf("Code %s is already used by %s", category.Code, other.Name)})
+		}
+	}
+	if category.ParentID != "" {
+		parent, ok := byID[category.ParentID]
+		switch {
+		case !ok:
+			violations = append(violations, ValidationViolation{Field: "parentId", Message: "Parent category does not exist"})
+		case parent.Kind != category.Kind:
+			violations = append(violations, ValidationViolation{Field: "parentId", Message: fmt.Sprintf("Parent category %s is %s, not %s", parent.Name, parent.Kind, category.Kind)})
+		default:
+			for seen := map[string]bool{}; ok && parent.ParentID != ""; parent, ok = byID[parent.ParentID] {
+				if parent.ParentID == category.ID || seen[parent.ID] {
+					violations = append(violations, ValidationViolation{Field: "parentId", Message: "Parent category would create a cycle"})
+					break
+				}
+				seen[parent.ID] = true
+			}
+		}
+	}
+	return violations
+}
+
+func findCategory(categories []Category, id string) int {
+	for i, category := range categories {
+		if category.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func categoryResponse(status string, category Category, rewritten []string, journalStale bool, headers map[string]string) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(categoryChangeResponse{Status: status, Category: category, RewrittenMonths: rewritten, JournalStale: journalStale})
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: headers}
+}
+
+func categoryNameSet(names []string) map[string]bool {
+	set := map[string]bool{}
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
+
+// ledgerCategoryLocked returns a *ledgerLockedError naming the locked ledger months, as
+// TYPE/YYYY-MM, with transactions using any of names, or nil if there are none
+func ledgerCategoryLocked(ctx context.Context, deps Dependencies, names []string) error {
+	set := categoryNameSet(names)
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return err
+	}
+	types := make([]string, 0, len(ledgersByType))
+	for ledgerType := range ledgersByType {
+		types = append(types, ledgerType)
+	}
+	sort.Strings(types)
+	blocked := &ledgerLockedError{}
+	for _, ledgerType := range types {
+		locks, err := loadLedgerLocks(ctx, deps, ledgerType)
+		if err != nil {
+			return err
+		}
+		for _, ledger := range ledgersByType[ledgerType] {
+			reason := locks.reason(ledger.Month)
+			if reason == "" {
+				continue
+			}
+			for _, tx := range ledger.Transactions {
+				if set[strings.ToLower(tx.Category)] {
+					blocked.Months = append(blocked.Months, ledgerType+"/"+ledger.Month)
+					blocked.Reasons = append(blocked.Reasons, reason)
+					break
+				}
+			}
+		}
+	}
+	if len(blocked.Months) > 0 {
+		return blocked
+	}
+	return nil
+}
+
+// rewriteCategoryNames renames transactions and category rules using any of from to to, saving
+// each changed ledger month. It changes nothing if any of those months is locked, and returns the
+// rewritten months as TYPE/YYYY-MM and whether the journal was left behind them.
+func rewriteCategoryNames(ctx context.Context, deps Dependencies, from []string, to string) ([]string, bool, error) {
+	rewritten := []string{}
+	journalStale := false
+	if err := ledgerCategoryLocked(ctx, deps, from); err != nil {
+		return rewritten, journalStale, err
+	}
+	names := categoryNameSet(from)
+
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return rewritten, journalStale, err
+	}
+	types := make([]string, 0, len(ledgersByType))
+	for ledgerType := range ledgersByType {
+		types = append(types, ledgerType)
+	}
+	sort.Strings(types)
+	for _, ledgerType := range types {
+		changed := []MonthlyLedger{}
+		for _, ledger := range ledgersByType[ledgerType] {
+			touched := false
+			for i, tx := range ledger.Transactions {
+				if names[strings.ToLower(tx.Category)] {
+					ledger.Transactions[i].Category = to
+					touched = true
+				}
+			}
+			if touched {
+				changed = append(changed, ledger)
+			}
+		}
+		result, err := saveLedgers(ctx, deps, ledgerType, changed, true)
+		for _, month := range result.Written {
+			rewritten = append(rewritten, ledgerType+"/"+month)
+		}
+		journalStale = journalStale || result.JournalStale
+		if err != nil {
+			return rewritten, journalStale, err
+		}
+	}
+
+	rules, err := loadCategoryRules(ctx, deps)
+	if err != nil {
+		return rewritten, journalStale, err
+	}
+	rulesChanged := false
+	for i := range rules {
+		if names[strings.ToLower(rules[i].Category)] {
+			rules[i].Category = to
+			rulesChanged = true
+		}
+	}
+	if rulesChanged {
+		content, _ := json.Marshal(rules)
+		if err := deps.Data.Save(ctx, categoryRulesPath, content); err != nil {
+			return rewritten, journalStale, err
+		}
+	}
+	return rewritten, journalStale, nil
+}
+
+// categoryInUse reports what still refers to the category, or an empty string if nothing does
+func categoryInUse(ctx context.Context, deps Dependencies, category Category, categories []Category) (string, error) {
+	for _, other := range categories {
+		if other.ParentID == category.ID {
+			return fmt.Sprintf("category %s is a child of it", other.Name), nil
+		}
+	}
+	index := newCategoryIndex([]Category{category})
+	rules, err := loadCategoryRules(ctx, deps)
+	if err != nil {
+		return "", err
+	}
+	for _, rule := range rules {
+		if _, ok := index.lookup(rule.Category); ok {
+			return fmt.Sprintf("rule %s assigns it", rule.ID), nil
+		}
+	}
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return "", err
+	}
+	for ledgerType, ledgers := range ledgersByType {
+		for _, ledger := range ledgers {
+			for _, tx := range ledger.Transactions {
+				if _, ok := index.lookup(tx.Category); ok {
+					return fmt.Sprintf("%s %s has transactions in it", ledgerType, ledger.Month), nil
+				}
+			}
+		}
+	}
+	months, _, err := loadJournalMonthsWithETags(ctx, deps)
+	if err != nil {
+		return "", err
+	}
+	for _, month := range months {
+		for _, entry := range month.Entries {
+			for _, line := range entry.Lines {
+				if line.Account == category.ID {
+					return fmt.Sprintf("journal entry %s posts to it", entry.ID), nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+func LedgerCategoriesGet(ctx context.Context, _ events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	body, _ := json.Marshal(categories)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// LedgerCategoriesPost creates a category. It is active unless the body says otherwise.
+func LedgerCategoriesPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	category := Category{Active: true}
+	if err := json.Unmarshal([]byte(request.Body), &category); err != nil {
+		fmt.Printf("Invalid category body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	category.Name = strings.TrimSpace(category.Name)
+
+	categories, etag, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if category.ID == "" {
+		category.ID = categorySlug(category.Name)
+	}
+	if category.ID == "" || findCategory(categories, category.ID) >= 0 || isSystemAccountID(category.ID) {
+		id, err := newUUID()
+		if err != nil {
+			return errorResponse(err, deps.Headers), nil
+		}
+		category.ID = id
+	}
+	if violations := validateCategory(category, categories); len(violations) > 0 {
+		return newValidationErrorResponse("Category validation failed", violations, deps.Headers), nil
+	}
+
+	if err := saveCategories(ctx, deps, append(categories, category), etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Created category %s (%s)\n", category.Name, category.ID)
+	return categoryResponse("ok", category, []string{}, false, deps.Headers), nil
+}
+
+// LedgerCategoriesPut updates the category named by the id parameter. A rename rewrites historical
+// transactions when rewriteTransactions=true, and otherwise keeps the old name as an alias. The
+// transactions are rewritten before the category is saved, so a rename that stops part-way
+// leaves the old name in place and can be run again.
+func LedgerCategoriesPut(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	id := request.QueryStringParameters["id"]
+	if id == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	rewrite := false
+	if raw := request.QueryStringParameters["rewriteTransactions"]; raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return events.APIGatewayProxyResponse{Body: `{"error": "rewriteTransactions must be true or false"}`, StatusCode: 400, Headers: deps.Headers}, nil
+		}
+		rewrite = parsed
+	}
+
+	categories, etag, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	i := findCategory(categories, id)
+	if i < 0 {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Category not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	existing := categories[i]
+	updated := existing
+	if err := json.Unmarshal([]byte(request.Body), &updated); err != nil {
+		fmt.Printf("Invalid category body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	updated.ID = existing.ID
+	updated.Name = strings.TrimSpace(updated.Name)
+	others := append(append([]Category{}, categories[:i]...), categories[i+1:]...)
+	if violations := validateCategory(updated, others); len(violations) > 0 {
+		return newValidationErrorResponse("Category validation failed", violations, deps.Headers), nil
+	}
+
+	renamed := existing.Name != updated.Name
+	rewritten := []string{}
+	journalStale := false
+	if renamed && rewrite {
+		oldNames := append([]string{existing.Name}, existing.Aliases...)
+		// Refuse the rename before writing anything if it would change a locked month
+		if err := ledgerCategoryLocked(ctx, deps, oldNames); err != nil {
+			return ledgerSaveErrorResponse(err, []string{}, deps.Headers), nil
+		}
+		rewritten, journalStale, err = rewriteCategoryNames(ctx, deps, oldNames, updated.Name)
+		if err != nil {
+			fmt.Printf("Category rename stopped after rewriting %v\n", rewritten)
+			return ledgerSaveErrorResponse(err, rewritten, deps.Headers), nil
+		}
+	}
+	if renamed && !rewrite {
+		updated.Aliases = appendAlias(updated.Aliases, existing.Name)
+	}
+	categories[i] = updated
+	if err := saveCategories(ctx, deps, categories, etag); err != nil {
+		return ledgerSaveErrorResponse(err, rewritten, deps.Headers), nil
+	}
+	fmt.Printf("Updated category %s (%s), rewrote %d month(s)\n", updated.Name, updated.ID, len(rewritten))
+	return categoryResponse("ok", updated, rewritten, journalStale, deps.Headers), nil
+}
+
+// LedgerCategoriesDelete removes an unused category. Categories with transactions, child
+// categories or rules pointing at them should be deactivated or merged instead.
+func LedgerCategoriesDelete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	id := request.QueryStringParameters["id"]
+	categories, etag, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	i := findCategory(categories, id)
+	if i < 0 {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Category not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	category := categories[i]
+	reason, err := categoryInUse(ctx, deps, category, categories)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if reason != "" {
+		body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Category %s is in use: %s; deactivate or merge it instead", category.Name, reason)})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+	}
+
+	categories = append(categories[:i], categories[i+1:]...)
+	if err := saveCategories(ctx, deps, categories, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Deleted category %s (%s)\n", category.Name, category.ID)
+	return categoryResponse("deleted", category, []string{}, false, deps.Headers), nil
+}
+
+// LedgerCategoriesMerge folds the source category into the target and removes the source. Both
+// must be of the same kind unless one has none. Its transactions are renamed when
+// rewriteTransactions is set; otherwise the target takes over the source's names as aliases.
+// Journal lines on the source account move to the target either way. The ledgers and journal
+// are rewritten before the categories are saved, so a merge that stops part-way leaves the
+// source in place and can be run again.
+func LedgerCategoriesMerge(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var body categoryMergeRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		fmt.Printf("Invalid merge body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	if body.SourceID == body.TargetID {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Source and target must differ"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+
+	categories, etag, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	sourceIdx, targetIdx := findCategory(categories, body.SourceID), findCategory(categories, body.TargetID)
+	if sourceIdx < 0 || targetIdx < 0 {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Category not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	source, target := categories[sourceIdx], categories[targetIdx]
+	if source.Kind != "" && target.Kind != "" && source.Kind != target.Kind {
+		violations := []ValidationViolation{{Field: "targetId", Message: fmt.Sprintf("Cannot merge %s category %s into %s category %s", source.Kind, source.Name, target.Kind, target.Name)}}
+		return newValidationErrorResponse("Category merge validation failed", violations, deps.Headers), nil
+	}
+	sourceNames := append([]string{source.Name}, source.Aliases...)
+	// Refuse the merge before writing anything if it would change a locked month
+	if err := journalAccountLocked(ctx, deps, source.ID); err != nil {
+		return ledgerSaveErrorResponse(err, []string{}, deps.Headers), nil
+	}
+	if body.RewriteTransactions {
+		if err := ledgerCategoryLocked(ctx, deps, sourceNames); err != nil {
+			return ledgerSaveErrorResponse(err, []string{}, deps.Headers), nil
+		}
+	}
+
+	rewritten := []string{}
+	journalStale := false
+	if body.RewriteTransactions {
+		rewritten, journalStale, err = rewriteCategoryNames(ctx, deps, sourceNames, target.Name)
+		if err != nil {
+			fmt.Printf("Category merge stopped after rewriting %v\n", rewritten)
+			return ledgerSaveErrorResponse(err, rewritten, deps.Headers), nil
+		}
+	}
+	if err := rewriteJournalAccount(ctx, deps, source.ID, target.ID); err != nil {
+		return ledgerSaveErrorResponse(err, rewritten, deps.Headers), nil
+	}
+
+	if !body.RewriteTransactions {
+		for _, name := range sourceNames {
+			target.Aliases = appendAlias(target.Aliases, name)
+		}
+	}
+	categories[targetIdx] = target
+	for i := range categories {
+		if categories[i].ParentID == source.ID {
+			categories[i].ParentID = target.ID
+		}
+	}
+	categories = append(categories[:sourceIdx], categories[sourceIdx+1:]...)
+	if err := saveCategories(ctx, deps, categories, etag); err != nil {
+		return ledgerSaveErrorResponse(err, rewritten, deps.Headers), nil
+	}
+	fmt.Printf("Merged category %s into %s, rewrote %d month(s)\n", source.Name, target.Name, len(rewritten))
+	return categoryResponse("ok", target, rewritten, journalStale, deps.Headers), nil
+}
+
+func appendAlias(aliases []string, name string) []string {
+	for _, alias := range aliases {
+		if strings.EqualFold(alias, name) {
+			return aliases
+		}
+	}
+	return append(aliases, name)
+}
@@ -0,0 +1,220 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestLoadCategories_LegacyNames(t *testing.T) {
+	prov := newTestDataProvider(t)
+	if err := prov.Save(context.Background(), categoriesPath, []byte(`["Membership", "Hall Hire"]`)); err != nil {
+		t.Fatal(err)
+	}
+	got, etag, err := loadCategories(context.Background(), Dependencies{Data: prov})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Category{
+		{ID: "membership", Name: "Membership", Kind: categoryIncome, Active: true},
+		{ID: "hall-hire", Name: "Hall Hire", Active: true},
+	}
+	if !reflect.DeepEqual(got, want) || etag == "" {
+		t.Errorf("loadCategories() = %+v, %q, want %+v", got, etag, want)
+	}
+}
+
+func TestValidateCategory(t *testing.T) {
+	others := []Category{
+		{ID: "income", Name: "Income", Code: "4000", Kind: categoryIncome},
+		{ID: "grants", Name: "Grants", Code: "4100", Kind: categoryIncome, ParentID: "income"},
+	}
+	tests := []struct {
+		name     string
+		category Category
+		want     []ValidationViolation
+	}{
+		{
+			name:     "Valid child",
+			category: Category{ID: "donations", Name: "Donations", Code: "4200", Kind: categoryIncome, ParentID: "income", GST: "gst-free"},
+			want:     []ValidationViolation{},
+		},
+		{
+			name:     "Duplicates and bad values",
+			category: Category{ID: "x", Name: "grants", Code: "4000", Kind: "revenue", GST: "maybe"},
+			want: []ValidationViolation{
+				{Field: "kind", Message: "Kind must be income, expense, asset, liability, equity or transfer"},
+				{Field: "gst", Message: "GST must be gst, gst-free, input-taxed or bas-excluded"},
+				{Field: "code", Message: "Code 4000 is already used by Income"},
+				{Field: "name", Message: `Name "grants" is already used`},
+			},
+		},
+		{
+			name:     "Parent of another kind",
+			category: Category{ID: "hall", Name: "Hall", Kind: categoryExpense, ParentID: "income"},
+			want:     []ValidationViolation{{Field: "parentId", Message: "Parent category Income is income, not expense"}},
+		},
+		{
+			name:     "Cycle",
+			category: Category{ID: "income", Name: "Income", Kind: categoryIncome, ParentID: "grants"},
+			want:     []ValidationViolation{{Field: "parentId", Message: "Parent category would create a cycle"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered := []Category{}
+			for _, other := range others {
+				if other.ID != tt.category.ID {
+					filtered = append(filtered, other)
+				}
+			}
+			if got := validateCategory(tt.category, filtered); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateCategory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedgerCategories_RenameAndMerge(t *testing.T) {
+	march := MonthlyLedger{
+		PK:             "LEDGER#BANK#2025-03",
+		Month:          "2025-03",
+		Type:           "BANK",
+		OpeningBalance: 0,
+		ClosingBalance: 2000,
+		Transactions: []Transaction{
+			{ID: "tx-1", Date: "2025-03-01", Category: "Event Fee", Description: "Entries", Amount: 2500, RunningBalance: 2500},
+			{ID: "tx-2", Date: "2025-03-02", Category: "Sponsorship", Description: "Sponsor", Amount: -500, RunningBalance: 2000},
+		},
+	}
+	prov := newTestDataProvider(t, march)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+
+	call := func(handler HandlerFunc, query map[string]string, body string) categoryChangeResponse {
+		t.Helper()
+		got, err := handler(ctx, events.APIGatewayProxyRequest{QueryStringParameters: query, Body: body}, deps)
+		if err != nil || got.StatusCode != 200 {
+			t.Fatalf("status = %d %s, %v", got.StatusCode, got.Body, err)
+		}
+		var response categoryChangeResponse
+		if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	// Renaming without rewriting keeps the old name as an alias
+	renamed := call(LedgerCategoriesPut, map[string]string{"id": "event-fee"}, `{"name": "Race Entries", "code": "4100"}`)
+	if !reflect.DeepEqual(renamed.Category.Aliases, []string{"Event Fee"}) || len(renamed.RewrittenMonths) != 0 {
+		t.Errorf("rename = %+v", renamed)
+	}
+
+	// Income cannot be merged into expenditure
+	got, err := LedgerCategoriesMerge(ctx, events.APIGatewayProxyRequest{Body: `{"sourceId": "sponsorship", "targetId": "misc"}`}, deps)
+	if err != nil || got.StatusCode != 422 {
+		t.Errorf("merge across kinds = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	// Merging with rewrite renames the source's transactions
+	merged := call(LedgerCategoriesMerge, nil, `{"sourceId": "sponsorship", "targetId": "event-fee", "rewriteTransactions": true}`)
+	if !reflect.DeepEqual(merged.RewrittenMonths, []string{"BANK/2025-03"}) {
+		t.Errorf("merge = %+v", merged)
+	}
+
+	content, _ := prov.Get(ctx, "ledger/BANK/2025-03.json")
+	var saved MonthlyLedger
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Transactions[0].Category != "Event Fee" || saved.Transactions[1].Category != "Race Entries" || saved.Version != 1 {
+		t.Errorf("transactions = %+v", saved.Transactions)
+	}
+
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findCategory(categories, "sponsorship") >= 0 {
+		t.Errorf("source category was not removed: %+v", categories)
+	}
+
+	// Both names now resolve to the one income category
+	items, _, total, _ := buildStatement(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+		map[string][]MonthlyLedger{"BANK": {saved}}, newCategoryIndex(categories))
+	if !reflect.DeepEqual(items, []ReportLineItem{{Label: "Race Entries", Amount: 2000}}) || total != 2000 {
+		t.Errorf("income = %+v, total %s", items, total)
+	}
+
+	got, err = LedgerCategoriesDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": "event-fee"}}, deps)
+	if err != nil || got.StatusCode != 409 {
+		t.Errorf("delete in-use category = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	deleted := call(LedgerCategoriesDelete, map[string]string{"id": "misc"}, "")
+	if deleted.Status != "deleted" {
+		t.Errorf("delete = %+v", deleted)
+	}
+}
+
+func TestLedgerCategoriesPut_RenameLockedMonth(t *testing.T) {
+	march := MonthlyLedger{
+		PK:             "LEDGER#BANK#2025-03",
+		Month:          "2025-03",
+		Type:           "BANK",
+		OpeningBalance: 0,
+		ClosingBalance: 2500,
+		Transactions: []Transaction{
+			{ID: "tx-1", Date: "2025-03-01", Category: "Event Fee", Description: "Entries", Amount: 2500, RunningBalance: 2500},
+		},
+	}
+	deps := Dependencies{Data: newTestDataProvider(t, march), Headers: DefaultHeaders()}
+	ctx := context.Background()
+	got, err := PeriodLockPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"month": "2025-03"}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("PeriodLockPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	// The rename is refused without saving the new name, so the transaction still resolves
+	got, err = LedgerCategoriesPut(ctx, events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"id": "event-fee", "rewriteTransactions": "true"},
+		Body:                  `{"name": "Race Entries"}`,
+	}, deps)
+	if err != nil || got.StatusCode != 409 || !strings.Contains(got.Body, "BANK/2025-03") {
+		t.Errorf("LedgerCategoriesPut() in locked month = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if category, ok := newCategoryIndex(categories).lookup("Event Fee"); !ok || category.ID != "event-fee" {
+		t.Errorf("Event Fee = %+v, %v", category, ok)
+	}
+}
+
+func TestBuildStatement_CategoryKinds(t *testing.T) {
+	categories := newCategoryIndex([]Category{
+		{ID: "membership", Name: "Membership", Kind: categoryIncome},
+		{ID: "equipment", Name: "Equipment", Kind: categoryExpense},
+		{ID: "transfer", Name: "Transfer", Kind: categoryAsset},
+	})
+	ledger := MonthlyLedger{Month: "2025-03", Transactions: []Transaction{
+		{Date: "2025-03-01", Category: "Membership", Amount: 10000},
+		{Date: "2025-03-02", Category: "membership", Amount: -1500},
+		{Date: "2025-03-03", Category: "Equipment", Amount: -4000},
+		{Date: "2025-03-04", Category: "Equipment", Amount: 500},
+		{Date: "2025-03-05", Category: "Transfer", Amount: -20000},
+		{Date: "2025-03-06", Category: "Donations", Amount: 700},
+	}}
+	income, expense, totalIncome, totalExpense := buildStatement(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		map[string][]MonthlyLedger{"BANK": {ledger}}, categories)
+	wantIncome := []ReportLineItem{{Label: "Donations", Amount: 700}, {Label: "Membership", Amount: 8500}}
+	wantExpense := []ReportLineItem{{Label: "Equipment", Amount: 3500}}
+	if !reflect.DeepEqual(income, wantIncome) || !reflect.DeepEqual(expense, wantExpense) || totalIncome != 9200 || totalExpense != 3500 {
+		t.Errorf("buildStatement() = %+v, %+v, %s, %s", income, expense, totalIncome, totalExpense)
+	}
+}
@@ -0,0 +1,179 @@
+package endpoints
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+const (
+	// minSuggestionConfidence is the lowest score worth suggesting; weaker matches get fallbackCategory
+	minSuggestionConfidence = 0.3
+	// reviewSuggestionConfidence is the score below which a suggestion is flagged for review
+	reviewSuggestionConfidence = 0.75
+	// descriptionWeight is the share of the score from description tokens; the rest is amount proximity
+	descriptionWeight = 0.8
+)
+
+// categorySuggestion is a category learned from the most similar past transaction
+type categorySuggestion struct {
+	TransactionID        string      `json:"transactionId"`
+	Date                 string      `json:"date"`
+	Description          string      `json:"description"`
+	Amount               money.Cents `json:"amount"`
+	Category             string      `json:"category"`
+	Confidence           float64     `json:"confidence"`
+	NeedsReview          bool        `json:"needsReview"`
+	MatchedTransactionID string      `json:"matchedTransactionId"`
+	MatchedDescription   string      `json:"matchedDescription"`
+}
+
+type categoryHistoryEntry struct {
+	Transaction
+	tokens map[string]bool
+}
+
+// categoryHistory indexes previously categorised transactions by description token
+type categoryHistory struct {
+	entries []categoryHistoryEntry
+	byToken map[string][]int
+}
+
+// descriptionTokens splits a description into lower-case words, dropping anything with a digit
+// because card numbers, dates and receipt references differ between otherwise identical payments
+func descriptionTokens(description string) map[string]bool {
+	tokens := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 2 || strings.ContainsFunc(word, unicode.IsDigit) {
+			continue
+		}
+		tokens[word] = true
+	}
+	return tokens
+}
+
+// newCategoryHistory learns from every transaction in ledgersByType with a category other than
+// fallbackCategory, which is what unrecognised imports are given and so says nothing about them
+func newCategoryHistory(ledgersByType map[string][]MonthlyLedger) *categoryHistory {
+	history := &categoryHistory{byToken: map[string][]int{}}
+	types := make([]string, 0, len(ledgersByType))
+	for ledgerType := range ledgersByType {
+		types = append(types, ledgerType)
+	}
+	sort.Strings(types)
+	for _, ledgerType := range types {
+		for _, ledger := range ledgersByType[ledgerType] {
+			for _, tx := range ledger.Transactions {
+				if tx.Category == "" || tx.Category == fallbackCategory {
+					continue
+				}
+				tokens := descriptionTokens(tx.Description)
+				if len(tokens) == 0 {
+					continue
+				}
+				for token := range tokens {
+					history.byToken[token] = append(history.byToken[token], len(history.entries))
+				}
+				history.entries = append(history.entries, categoryHistoryEntry{Transaction: tx, tokens: tokens})
+			}
+		}
+	}
+	return history
+}
+
+// amountProximity is 1 for equal amounts, falling towards 0 as they diverge, and 0 for opposite signs
+func amountProximity(a, b money.Cents) float64 {
+	if a == b {
+		return 1
+	}
+	if a < 0 != (b < 0) {
+		return 0
+	}
+	larger := math.Max(float64(a.Abs()), float64(b.Abs()))
+	return 1 - math.Abs(float64(a-b))/larger
+}
+
+// suggest finds the past transaction most like tx, scoring description token overlap (Jaccard)
+// and amount proximity. Ties go to the most recent transaction.
+func (h *categoryHistory) suggest(tx Transaction) (categorySuggestion, bool) {
+	tokens := descriptionTokens(tx.Description)
+	seen := map[int]bool{}
+	candidates := []int{}
+	for token := range tokens {
+		for _, i := range h.byToken[token] {
+			if !seen[i] {
+				seen[i] = true
+				candidates = append(candidates, i)
+			}
+		}
+	}
+	sort.Ints(candidates)
+
+	best, bestScore := -1, 0.0
+	for _, i := range candidates {
+		entry := h.entries[i]
+		shared := 0
+		for token := range tokens {
+			if entry.tokens[token] {
+				shared++
+			}
+		}
+		similarity := float64(shared) / float64(len(tokens)+len(entry.tokens)-shared)
+		score := descriptionWeight*similarity + (1-descriptionWeight)*amountProximity(tx.Amount, entry.Amount)
+		if score > bestScore || score == bestScore && best >= 0 && entry.Date > h.entries[best].Date {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 || bestScore < minSuggestionConfidence {
+		return categorySuggestion{}, false
+	}
+	confidence := math.Round(bestScore*100) / 100
+	return categorySuggestion{
+		TransactionID:        tx.ID,
+		Date:                 tx.Date,
+		Description:          tx.Description,
+		Amount:               tx.Amount,
+		Category:             h.entries[best].Category,
+		Confidence:           confidence,
+		NeedsReview:          confidence < reviewSuggestionConfidence,
+		MatchedTransactionID: h.entries[best].ID,
+		MatchedDescription:   h.entries[best].Description,
+	}, true
+}
+
+// applyCategorySuggestions gives every uncategorised transaction in ledgers the suggested
+// category, or fallbackCategory when nothing similar has been seen, and returns the suggestions
+func applyCategorySuggestions(ledgers []MonthlyLedger, history *categoryHistory) []categorySuggestion {
+	suggestions := []categorySuggestion{}
+	for i := range ledgers {
+		for j, tx := range ledgers[i].Transactions {
+			if tx.Category != "" {
+				continue
+			}
+			suggestion, ok := history.suggest(tx)
+			if !ok {
+				ledgers[i].Transactions[j].Category = fallbackCategory
+				continue
+			}
+			ledgers[i].Transactions[j].Category = suggestion.Category
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
+
+func hasUncategorisedTransactions(ledgers []MonthlyLedger) bool {
+	for _, ledger := range ledgers {
+		for _, tx := range ledger.Transactions {
+			if tx.Category == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+func TestCategoryHistory_Suggest(t *testing.T) {
+	history := newCategoryHistory(map[string][]MonthlyLedger{
+		"BANK": {{
+			Month: "2025-01",
+			Transactions: []Transaction{
+				{ID: "hall-jan", Date: "2025-01-10", Category: "Venue Hire", Description: "Eureka Hall hire ref 1001", Amount: -15000},
+				{ID: "hall-feb", Date: "2025-02-10", Category: "Venue Hire", Description: "Eureka Hall hire ref 1002", Amount: -15000},
+				{ID: "payout", Date: "2025-01-15", Category: "Membership", Description: "TidyHQ payout", Amount: 42000},
+				{ID: "unsorted", Date: "2025-01-20", Category: "Misc", Description: "Eureka Hall hire", Amount: -15000},
+			},
+		}},
+	})
+
+	tests := []struct {
+		name   string
+		tx     Transaction
+		want   categorySuggestion
+		wantOK bool
+	}{
+		{
+			name: "Same payee and amount",
+			tx:   Transaction{ID: "new", Date: "2025-03-10", Description: "EUREKA HALL HIRE REF 1003", Amount: -15000},
+			want: categorySuggestion{
+				TransactionID: "new", Date: "2025-03-10", Description: "EUREKA HALL HIRE REF 1003", Amount: -15000,
+				Category: "Venue Hire", Confidence: 1, MatchedTransactionID: "hall-feb", MatchedDescription: "Eureka Hall hire ref 1002",
+			},
+			wantOK: true,
+		},
+		{
+			name: "Partial match needs review",
+			tx:   Transaction{ID: "new", Date: "2025-03-15", Description: "TidyHQ transfer", Amount: 42000},
+			want: categorySuggestion{
+				TransactionID: "new", Date: "2025-03-15", Description: "TidyHQ transfer", Amount: 42000,
+				Category: "Membership", Confidence: 0.47, NeedsReview: true, MatchedTransactionID: "payout", MatchedDescription: "TidyHQ payout",
+			},
+			wantOK: true,
+		},
+		{
+			name:   "Opposite sign scores too low",
+			tx:     Transaction{ID: "new", Date: "2025-03-15", Description: "TidyHQ refund", Amount: -2000},
+			wantOK: false,
+		},
+		{
+			name:   "Nothing similar",
+			tx:     Transaction{ID: "new", Date: "2025-03-15", Description: "Bike parts", Amount: -2000},
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := history.suggest(tt.tx)
+			if ok != tt.wantOK {
+				t.Fatalf("suggest() ok = %v, want %v (%+v)", ok, tt.wantOK, got)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suggest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedgerBankImport_Suggestions(t *testing.T) {
+	january := MonthlyLedger{
+		PK:             "LEDGER#CASH#2025-01",
+		Month:          "2025-01",
+		Type:           "CASH",
+		OpeningBalance: 0,
+		ClosingBalance: -15000,
+		Transactions: []Transaction{
+			{ID: "hall", Date: "2025-01-10", Category: "Venue Hire", Description: "Eureka Hall hire", Amount: -15000, RunningBalance: -15000},
+		},
+	}
+	deps := Dependencies{Data: newTestDataProvider(t, january), Headers: DefaultHeaders()}
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"type": "BANK", "currentBalance": "0", "dryRun": "true"},
+		Body:                  "10/03/2025,-160.00,Eureka Hall hire\n05/03/2025,-12.00,Coffee\n",
+	}
+	got, err := LedgerBankImport(context.Background(), request, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", got.StatusCode, got.Body)
+	}
+	var response bankImportResponse
+	if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Suggestions) != 1 || response.Suggestions[0].Category != "Venue Hire" || response.Suggestions[0].Confidence != 0.99 {
+		t.Fatalf("suggestions = %+v", response.Suggestions)
+	}
+	categories := map[string]string{}
+	for _, tx := range response.Preview[0].Proposed.Transactions {
+		categories[tx.Description] = tx.Category
+	}
+	want := map[string]string{"Eureka Hall hire": "Venue Hire", "Coffee": fallbackCategory}
+	if !reflect.DeepEqual(categories, want) {
+		t.Errorf("categories = %v, want %v", categories, want)
+	}
+	if money.Cents(-17200) != response.Preview[0].Proposed.ClosingBalance-response.Preview[0].Proposed.OpeningBalance {
+		t.Errorf("proposed = %+v", response.Preview[0].Proposed)
+	}
+}
@@ -2,6 +2,7 @@ package endpoints
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -35,6 +36,30 @@ func errorResponse(err error, headers map[string]string) events.APIGatewayProxyR
 	}
 }
 
+// storageErrorResponse maps typed storage errors to 404/403/409/503 and request timeouts to 504, falling back to a 500
+func storageErrorResponse(err error, headers map[string]string) events.APIGatewayProxyResponse {
+	var accessDenied *storage.AccessDeniedError
+	var throttled *storage.ThrottledError
+	switch {
+	case errors.Is(err, storage.ErrPreconditionFailed):
+		fmt.Printf("Conflict: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Modified by another request"}`, StatusCode: 409, Headers: headers}
+	case errors.Is(err, storage.ErrNotFound):
+		fmt.Printf("Not found: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Not found"}`, StatusCode: 404, Headers: headers}
+	case errors.As(err, &accessDenied):
+		fmt.Printf("Access denied: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Access denied"}`, StatusCode: 403, Headers: headers}
+	case errors.As(err, &throttled):
+		fmt.Printf("Storage throttled: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Storage temporarily unavailable"}`, StatusCode: 503, Headers: headers}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		fmt.Printf("Request timed out: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Request timed out"}`, StatusCode: 504, Headers: headers}
+	}
+	return errorResponse(err, headers)
+}
+
 func getMimeType(path string) string {
 	ext := ""
 	lastDot := strings.LastIndex(path, ".")
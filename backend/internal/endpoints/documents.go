@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -18,11 +21,11 @@ type DocumentItem struct {
 	Expires int64  `json:"expires,omitempty"`
 }
 
-func DocumentsList(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func DocumentsList(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	path := request.QueryStringParameters["path"]
-	items, err := deps.Storage.List(path)
+	items, err := deps.Storage.List(ctx, path)
 	if err != nil {
-		return errorResponse(err, deps.Headers), nil
+		return storageErrorResponse(err, deps.Headers), nil
 	}
 
 	enrichedItems := make([]DocumentItem, len(items))
@@ -39,7 +42,7 @@ func DocumentsList(_ context.Context, request events.APIGatewayProxyRequest, dep
 	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
 }
 
-func DocumentsRaw(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func DocumentsRaw(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	path := request.QueryStringParameters["path"]
 	token := request.QueryStringParameters["token"]
 	expiresStr := request.QueryStringParameters["expires"]
@@ -57,13 +60,13 @@ func DocumentsRaw(_ context.Context, request events.APIGatewayProxyRequest, deps
 		return events.APIGatewayProxyResponse{Body: `{"error": "Expired"}`, StatusCode: 401, Headers: deps.Headers}, nil
 	}
 
-	content, err := deps.Storage.Get(path)
+	encoded, err := readBase64(ctx, deps.Storage, path)
 	if err != nil {
-		return errorResponse(err, deps.Headers), nil
+		return storageErrorResponse(err, deps.Headers), nil
 	}
 
 	return events.APIGatewayProxyResponse{
-		Body:            base64.StdEncoding.EncodeToString(content),
+		Body:            encoded,
 		IsBase64Encoded: true,
 		StatusCode:      200,
 		Headers: map[string]string{
@@ -73,14 +76,14 @@ func DocumentsRaw(_ context.Context, request events.APIGatewayProxyRequest, deps
 	}, nil
 }
 
-func DocumentsView(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func DocumentsView(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	path := request.QueryStringParameters["path"]
-	content, err := deps.Storage.Get(path)
+	encoded, err := readBase64(ctx, deps.Storage, path)
 	if err != nil {
-		return errorResponse(err, deps.Headers), nil
+		return storageErrorResponse(err, deps.Headers), nil
 	}
 	return events.APIGatewayProxyResponse{
-		Body:            base64.StdEncoding.EncodeToString(content),
+		Body:            encoded,
 		IsBase64Encoded: true,
 		StatusCode:      200,
 		Headers: map[string]string{
@@ -90,40 +93,65 @@ func DocumentsView(_ context.Context, request events.APIGatewayProxyRequest, dep
 	}, nil
 }
 
-func DocumentsSave(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func DocumentsSave(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	path := request.QueryStringParameters["path"]
-	err := deps.Storage.Save(path, []byte(request.Body))
+	err := deps.Storage.Save(ctx, path, []byte(request.Body))
 	if err != nil {
-		return errorResponse(err, deps.Headers), nil
+		return storageErrorResponse(err, deps.Headers), nil
 	}
 	return events.APIGatewayProxyResponse{Body: `{"status":"ok"}`, StatusCode: 200, Headers: deps.Headers}, nil
 }
 
-func DocumentsUpload(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func DocumentsUpload(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	path := request.QueryStringParameters["path"]
-	var body []byte
-	var err error
-	if request.IsBase64Encoded {
-		body, err = base64.StdEncoding.DecodeString(request.Body)
-		if err != nil {
+	body, size := requestBodyReader(request)
+	err := deps.Storage.SaveReader(ctx, path, body, size)
+	if err != nil {
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
 			return errorResponse(err, deps.Headers), nil
 		}
-	} else {
-		body = []byte(request.Body)
+		return storageErrorResponse(err, deps.Headers), nil
 	}
+	return events.APIGatewayProxyResponse{Body: `{"status":"ok"}`, StatusCode: 200, Headers: deps.Headers}, nil
+}
 
-	err = deps.Storage.Save(path, body)
+func DocumentsMkdir(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	path := request.QueryStringParameters["path"]
+	err := deps.Storage.Mkdir(ctx, path)
 	if err != nil {
-		return errorResponse(err, deps.Headers), nil
+		return storageErrorResponse(err, deps.Headers), nil
 	}
 	return events.APIGatewayProxyResponse{Body: `{"status":"ok"}`, StatusCode: 200, Headers: deps.Headers}, nil
 }
 
-func DocumentsMkdir(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
-	path := request.QueryStringParameters["path"]
-	err := deps.Storage.Mkdir(path)
+// readBase64 streams an object straight into its base64 encoding for API Gateway binary responses
+func readBase64(ctx context.Context, prov storage.StorageProvider, path string) (string, error) {
+	body, err := prov.GetReader(ctx, path)
 	if err != nil {
-		return errorResponse(err, deps.Headers), nil
+		return "", err
 	}
-	return events.APIGatewayProxyResponse{Body: `{"status":"ok"}`, StatusCode: 200, Headers: deps.Headers}, nil
+	defer body.Close()
+
+	var encoded strings.Builder
+	encoder := base64.NewEncoder(base64.StdEncoding, &encoded)
+	if _, err := io.Copy(encoder, body); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return encoded.String(), nil
+}
+
+// decodedBase64Len returns the exact decoded size of a padded base64 string, or -1 if it can't be determined
+func decodedBase64Len(encoded string) int64 {
+	if len(encoded)%4 != 0 {
+		return -1
+	}
+	padding := len(encoded) - len(strings.TrimRight(encoded, "="))
+	if padding > 2 {
+		return -1
+	}
+	return int64(len(encoded)/4*3 - padding)
 }
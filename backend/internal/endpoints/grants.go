@@ -0,0 +1,424 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const grantsPath = "grants.json"
+
+// Grant statuses. Declined grants are kept for the record but never reported.
+const (
+	grantApplied   = "applied"
+	grantApproved  = "approved"
+	grantAcquitted = "acquitted"
+	grantDeclined  = "declined"
+)
+
+var grantStatuses = map[string]bool{grantApplied: true, grantApproved: true, grantAcquitted: true, grantDeclined: true}
+
+// Grant is one entry in the grant register
+type Grant struct {
+	ID             string      `json:"id"`
+	Grantor        string      `json:"grantor"`
+	Purpose        string      `json:"purpose"`
+	AmountApproved money.Cents `json:"amountApproved"`
+	ApprovedDate   string      `json:"approvedDate,omitempty"`
+	// Received are the ledger transactions in which grant money arrived
+	Received []GrantReceipt `json:"received"`
+	// Expenditure is what has been spent against the grant
+	Expenditure  []GrantExpenditure `json:"expenditure"`
+	AcquittalDue string             `json:"acquittalDue,omitempty"`
+	Status       string             `json:"status"`
+}
+
+// GrantReceipt links grant money received to its ledger transaction, whose date and amount it carries
+type GrantReceipt struct {
+	LedgerType    string      `json:"ledgerType"`
+	TransactionID string      `json:"transactionId"`
+	Date          string      `json:"date"`
+	Amount        money.Cents `json:"amount"`
+}
+
+// GrantExpenditure is spending against a grant. When it is linked to a ledger transaction the date
+// and amount are taken from the transaction.
+type GrantExpenditure struct {
+	Date          string      `json:"date"`
+	Amount        money.Cents `json:"amount"`
+	Description   string      `json:"description"`
+	LedgerType    string      `json:"ledgerType,omitempty"`
+	TransactionID string      `json:"transactionId,omitempty"`
+}
+
+// grantView is a grant with its totals to date
+type grantView struct {
+	Grant
+	TotalReceived    money.Cents `json:"totalReceived"`
+	TotalExpended    money.Cents `json:"totalExpended"`
+	Unexpended       money.Cents `json:"unexpended"`
+	AcquittalOverdue bool        `json:"acquittalOverdue"`
+}
+
+func loadGrants(ctx context.Context, deps Dependencies) ([]Grant, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, grantsPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return []Grant{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var grants []Grant
+	if err := json.Unmarshal(content, &grants); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %w", grantsPath, err)
+	}
+	return grants, etag, nil
+}
+
+func saveGrants(ctx context.Context, deps Dependencies, grants []Grant, etag string) error {
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].Grantor+grants[i].Purpose < grants[j].Grantor+grants[j].Purpose
+	})
+	content, _ := json.Marshal(grants)
+	return deps.Data.SaveIfMatch(ctx, grantsPath, content, etag)
+}
+
+// grantTotals sums what was received and spent up to and including asAt
+func grantTotals(grant Grant, asAt time.Time) (money.Cents, money.Cents) {
+	var received, expended money.Cents
+	for _, receipt := range grant.Received {
+		if date, ok := parseTransactionDate(receipt.Date); ok && !date.After(asAt) {
+			received += receipt.Amount
+		}
+	}
+	for _, spend := range grant.Expenditure {
+		if date, ok := parseTransactionDate(spend.Date); ok && !date.After(asAt) {
+			expended += spend.Amount
+		}
+	}
+	return received, expended
+}
+
+// unexpendedGrants is the grant money received but not yet spent as at end, which the club holds
+// as a liability until it is spent or returned
+func unexpendedGrants(grants []Grant, end time.Time) money.Cents {
+	var total money.Cents
+	for _, grant := range grants {
+		if grant.Status == grantDeclined {
+			continue
+		}
+		if received, expended := grantTotals(grant, end); received > expended {
+			total += received - expended
+		}
+	}
+	return total
+}
+
+// grantIncome is the grant income earned over the period: the grant money received, less the
+// increase in unexpended grants, so that grants are income only as the money is spent
+func grantIncome(grants []Grant, start, end time.Time) money.Cents {
+	var received money.Cents
+	for _, grant := range grants {
+		if grant.Status == grantDeclined {
+			continue
+		}
+		for _, receipt := range grant.Received {
+			if date, ok := parseTransactionDate(receipt.Date); ok && !date.Before(start) && !date.After(end) {
+				received += receipt.Amount
+			}
+		}
+	}
+	return received - (unexpendedGrants(grants, end) - unexpendedGrants(grants, start.AddDate(0, 0, -1)))
+}
+
+// resolveGrantLinks copies grants with each linked receipt and expenditure taking its date and
+// amount from its ledger transaction as it is now, since the transaction may have been edited
+// after the grant was saved. Links to transactions that are no longer in the ledgers are left
+// out, and described in the returned list so the report can flag them.
+func resolveGrantLinks(grants []Grant, transactions map[string]Transaction) ([]Grant, []string) {
+	resolved := make([]Grant, 0, len(grants))
+	missing := []string{}
+	for _, grant := range grants {
+		received := make([]GrantReceipt, 0, len(grant.Received))
+		for _, receipt := range grant.Received {
+			tx, ok := transactions[receipt.LedgerType+"/"+receipt.TransactionID]
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s (%s): received %s transaction %s is no longer in the ledger and is left out.", grant.Grantor, grant.Purpose, receipt.LedgerType, receipt.TransactionID))
+				continue
+			}
+			receipt.Date, receipt.Amount = tx.Date, tx.Amount
+			received = append(received, receipt)
+		}
+		expenditure := make([]GrantExpenditure, 0, len(grant.Expenditure))
+		for _, spend := range grant.Expenditure {
+			if spend.TransactionID != "" {
+				tx, ok := transactions[spend.LedgerType+"/"+spend.TransactionID]
+				if !ok {
+					missing = append(missing, fmt.Sprintf("%s (%s): expenditure %s transaction %s is no longer in the ledger and is left out.", grant.Grantor, grant.Purpose, spend.LedgerType, spend.TransactionID))
+					continue
+				}
+				spend.Date, spend.Amount = tx.Date, -tx.Amount
+			}
+			expenditure = append(expenditure, spend)
+		}
+		grant.Received, grant.Expenditure = received, expenditure
+		resolved = append(resolved, grant)
+	}
+	return resolved, missing
+}
+
+// grantReceiptLinks keys the ledger transactions grant money was received in as TYPE/ID. The
+// report takes them out of the statement and reports grantIncome instead.
+func grantReceiptLinks(grants []Grant) map[string]bool {
+	links := map[string]bool{}
+	for _, grant := range grants {
+		if grant.Status == grantDeclined {
+			continue
+		}
+		for _, receipt := range grant.Received {
+			links[receipt.LedgerType+"/"+receipt.TransactionID] = true
+		}
+	}
+	return links
+}
+
+// grantEntries keys each receipt and expenditure of grant by its contents, mapped to its date.
+// A missing or declined grant has none, since it is not reported.
+func grantEntries(grant *Grant) map[string]string {
+	entries := map[string]string{}
+	if grant == nil || grant.Status == grantDeclined {
+		return entries
+	}
+	for _, receipt := range grant.Received {
+		entries[fmt.Sprintf("received %+v", receipt)] = receipt.Date
+	}
+	for _, expenditure := range grant.Expenditure {
+		entries[fmt.Sprintf("expended %+v", expenditure)] = expenditure.Date
+	}
+	return entries
+}
+
+// buildGrantsNote describes every grant approved by end
+func buildGrantsNote(grants []Grant, end time.Time) ReportNote {
+	details := []string{}
+	for _, grant := range grants {
+		if grant.Status == grantDeclined || grant.Status == grantApplied {
+			continue
+		}
+		if approved, ok := parseTransactionDate(grant.ApprovedDate); ok && approved.After(end) {
+			continue
+		}
+		received, expended := grantTotals(grant, end)
+		detail := fmt.Sprintf("%s (%s): approved %s, received %s, expended %s, unexpended %s",
+			grant.Grantor, grant.Purpose, formatCurrency(grant.AmountApproved), formatCurrency(received), formatCurrency(expended), formatCurrency(max(received-expended, 0)))
+		if grant.Status == grantAcquitted {
+			detail += "; acquitted"
+		} else if grant.AcquittalDue != "" {
+			detail += fmt.Sprintf("; acquittal due %s", grant.AcquittalDue)
+		}
+		details = append(details, detail+".")
+	}
+	if len(details) == 0 {
+		details = append(details, "No grants in the grant register for the period.")
+	}
+	return ReportNote{Title: "Grants", Details: details}
+}
+
+func newGrantView(grant Grant, now time.Time) grantView {
+	received, expended := grantTotals(grant, now)
+	view := grantView{Grant: grant, TotalReceived: received, TotalExpended: expended, Unexpended: max(received-expended, 0)}
+	if due, ok := parseTransactionDate(grant.AcquittalDue); ok && grant.Status == grantApproved {
+		view.AcquittalOverdue = now.After(due)
+	}
+	return view
+}
+
+// validateGrant checks grant and fills in each linked receipt and expenditure from its ledger
+// transaction. A transaction can only be received into one grant.
+func validateGrant(grant *Grant, others []Grant, ledgersByType map[string][]MonthlyLedger) []ValidationViolation {
+	violations := []ValidationViolation{}
+	if grant.Grantor == "" {
+		violations = append(violations, ValidationViolation{Field: "grantor", Message: "Grantor is required"})
+	}
+	if grant.AmountApproved < 0 {
+		violations = append(violations, ValidationViolation{Field: "amountApproved", Message: "Amount approved cannot be negative"})
+	}
+	if !grantStatuses[grant.Status] {
+		violations = append(violations, ValidationViolation{Field: "status", Message: "Status must be applied, approved, acquitted or declined"})
+	}
+	if _, ok := parseTransactionDate(grant.ApprovedDate); grant.ApprovedDate != "" && !ok {
+		violations = append(violations, ValidationViolation{Field: "approvedDate", Message: "Date must be YYYY-MM-DD"})
+	}
+	if _, ok := parseTransactionDate(grant.AcquittalDue); grant.AcquittalDue != "" && !ok {
+		violations = append(violations, ValidationViolation{Field: "acquittalDue", Message: "Date must be YYYY-MM-DD"})
+	}
+
+	transactions := ledgerTransactionIndex(ledgersByType)
+	receivedBy := map[string]string{}
+	for _, other := range others {
+		for _, receipt := range other.Received {
+			receivedBy[receipt.LedgerType+"/"+receipt.TransactionID] = other.Grantor
+		}
+	}
+
+	for i := range grant.Received {
+		receipt := &grant.Received[i]
+		receipt.LedgerType = strings.ToUpper(strings.TrimSpace(receipt.LedgerType))
+		key := receipt.LedgerType + "/" + receipt.TransactionID
+		field := fmt.Sprintf("received[%d]", i)
+		tx, ok := transactions[key]
+		switch {
+		case !ok:
+			violations = append(violations, ValidationViolation{TransactionID: receipt.TransactionID, Field: field, Message: fmt.Sprintf("Transaction is not in the %s ledger", receipt.LedgerType)})
+		case tx.Amount <= 0:
+			violations = append(violations, ValidationViolation{TransactionID: receipt.TransactionID, Field: field, Message: "Grant money received must be a deposit"})
+		case receivedBy[key] != "":
+			violations = append(violations, ValidationViolation{TransactionID: receipt.TransactionID, Field: field, Message: fmt.Sprintf("Transaction is already received into the %s grant", receivedBy[key])})
+		default:
+			receipt.Date, receipt.Amount = tx.Date, tx.Amount
+			receivedBy[key] = grant.Grantor
+		}
+	}
+	for i := range grant.Expenditure {
+		spend := &grant.Expenditure[i]
+		field := fmt.Sprintf("expenditure[%d]", i)
+		if spend.TransactionID != "" {
+			spend.LedgerType = strings.ToUpper(strings.TrimSpace(spend.LedgerType))
+			tx, ok := transactions[spend.LedgerType+"/"+spend.TransactionID]
+			switch {
+			case !ok:
+				violations = append(violations, ValidationViolation{TransactionID: spend.TransactionID, Field: field, Message: fmt.Sprintf("Transaction is not in the %s ledger", spend.LedgerType)})
+			case tx.Amount >= 0:
+				violations = append(violations, ValidationViolation{TransactionID: spend.TransactionID, Field: field, Message: "Grant expenditure must be a payment"})
+			default:
+				spend.Date, spend.Amount = tx.Date, -tx.Amount
+				if spend.Description == "" {
+					spend.Description = tx.Description
+				}
+			}
+			continue
+		}
+		if _, ok := parseTransactionDate(spend.Date); !ok {
+			violations = append(violations, ValidationViolation{Field: field + ".date", Message: "Date must be YYYY-MM-DD"})
+		}
+		if spend.Amount <= 0 {
+			violations = append(violations, ValidationViolation{Field: field + ".amount", Message: "Amount must be positive"})
+		}
+	}
+	return violations
+}
+
+// GrantsGet lists the grant register with what each grant has received and spent to date
+func GrantsGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	grants, _, err := loadGrants(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	now := time.Now()
+	views := make([]grantView, 0, len(grants))
+	for _, grant := range grants {
+		views = append(views, newGrantView(grant, now))
+	}
+	body, _ := json.Marshal(views)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// GrantsPost records a new grant, or replaces the grant with the same ID
+func GrantsPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var grant Grant
+	if err := json.Unmarshal([]byte(request.Body), &grant); err != nil {
+		fmt.Printf("Invalid grant body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	grant.Grantor = strings.TrimSpace(grant.Grantor)
+	grant.Purpose = strings.TrimSpace(grant.Purpose)
+	if grant.Status == "" {
+		grant.Status = grantApproved
+	}
+	if grant.Received == nil {
+		grant.Received = []GrantReceipt{}
+	}
+	if grant.Expenditure == nil {
+		grant.Expenditure = []GrantExpenditure{}
+	}
+
+	grants, etag, err := loadGrants(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	others := make([]Grant, 0, len(grants))
+	var previous *Grant
+	for i, existing := range grants {
+		if existing.ID != grant.ID || grant.ID == "" {
+			others = append(others, existing)
+		} else {
+			previous = &grants[i]
+		}
+	}
+	if grant.ID != "" && previous == nil {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Grant not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	if violations := validateGrant(&grant, others, ledgersByType); len(violations) > 0 {
+		return newValidationErrorResponse("Grant validation failed", violations, deps.Headers), nil
+	}
+	if locked := registerPeriodLocked(ctx, deps, grantEntries(previous), grantEntries(&grant)); locked != nil {
+		return *locked, nil
+	}
+	if grant.ID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return errorResponse(err, deps.Headers), nil
+		}
+		grant.ID = id
+	}
+	if err := saveGrants(ctx, deps, append(others, grant), etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Saved grant %s from %s\n", grant.ID, grant.Grantor)
+	body, _ := json.Marshal(newGrantView(grant, time.Now()))
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// GrantsDelete removes the grant named by the id parameter from the register
+func GrantsDelete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	id := request.QueryStringParameters["id"]
+	if id == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	grants, etag, err := loadGrants(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	kept := make([]Grant, 0, len(grants))
+	var removed *Grant
+	for i, grant := range grants {
+		if grant.ID != id {
+			kept = append(kept, grant)
+		} else {
+			removed = &grants[i]
+		}
+	}
+	if removed == nil {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Grant not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	if locked := registerPeriodLocked(ctx, deps, grantEntries(removed), nil); locked != nil {
+		return *locked, nil
+	}
+	if err := saveGrants(ctx, deps, kept, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Deleted grant %s\n", id)
+	return events.APIGatewayProxyResponse{Body: `{"status": "deleted"}`, StatusCode: 200, Headers: deps.Headers}, nil
+}
@@ -0,0 +1,206 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func grantTestLedgers() []MonthlyLedger {
+	return []MonthlyLedger{testLedger("BANK", "2025-03", 10000,
+		Transaction{ID: "grant", Date: "2025-03-03", Category: "Grants", Description: "Council community grant", Amount: 500000},
+		Transaction{ID: "bikes", Date: "2025-03-20", Category: "Equipment", Description: "Loan bikes", Amount: -320000},
+	)}
+}
+
+func TestValidateGrant(t *testing.T) {
+	ledgersByType := map[string][]MonthlyLedger{"BANK": grantTestLedgers()}
+	others := []Grant{{Grantor: "Sport Victoria", Received: []GrantReceipt{{LedgerType: "BANK", TransactionID: "grant"}}}}
+	tests := []struct {
+		name   string
+		grant  Grant
+		others []Grant
+		want   []ValidationViolation
+		// wantGrant is the grant after its links are filled in, when it is valid
+		wantGrant *Grant
+	}{
+		{
+			name: "Linked receipt and expenditure",
+			grant: Grant{Grantor: "Council", Status: grantApproved,
+				Received:    []GrantReceipt{{LedgerType: "bank", TransactionID: "grant"}},
+				Expenditure: []GrantExpenditure{{LedgerType: "BANK", TransactionID: "bikes"}, {Date: "2025-04-01", Amount: 5000, Description: "Volunteer time"}},
+			},
+			want: []ValidationViolation{},
+			wantGrant: &Grant{Grantor: "Council", Status: grantApproved,
+				Received: []GrantReceipt{{LedgerType: "BANK", TransactionID: "grant", Date: "2025-03-03", Amount: 500000}},
+				Expenditure: []GrantExpenditure{
+					{LedgerType: "BANK", TransactionID: "bikes", Date: "2025-03-20", Amount: 320000, Description: "Loan bikes"},
+					{Date: "2025-04-01", Amount: 5000, Description: "Volunteer time"},
+				},
+			},
+		},
+		{
+			name: "Bad links",
+			grant: Grant{Grantor: "Council", Status: "paid", AcquittalDue: "30/06/2026",
+				Received:    []GrantReceipt{{LedgerType: "BANK", TransactionID: "bikes"}, {LedgerType: "CASH", TransactionID: "grant"}},
+				Expenditure: []GrantExpenditure{{LedgerType: "BANK", TransactionID: "grant"}, {Date: "April", Amount: 0}},
+			},
+			want: []ValidationViolation{
+				{Field: "status", Message: "Status must be applied, approved, acquitted or declined"},
+				{Field: "acquittalDue", Message: "Date must be YYYY-MM-DD"},
+				{TransactionID: "bikes", Field: "received[0]", Message: "Grant money received must be a deposit"},
+				{TransactionID: "grant", Field: "received[1]", Message: "Transaction is not in the CASH ledger"},
+				{TransactionID: "grant", Field: "expenditure[0]", Message: "Grant expenditure must be a payment"},
+				{Field: "expenditure[1].date", Message: "Date must be YYYY-MM-DD"},
+				{Field: "expenditure[1].amount", Message: "Amount must be positive"},
+			},
+		},
+		{
+			name:   "Received into another grant",
+			grant:  Grant{Grantor: "Council", Status: grantApproved, Received: []GrantReceipt{{LedgerType: "BANK", TransactionID: "grant"}}},
+			others: others,
+			want:   []ValidationViolation{{TransactionID: "grant", Field: "received[0]", Message: "Transaction is already received into the Sport Victoria grant"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grant := tt.grant
+			got := validateGrant(&grant, tt.others, ledgersByType)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateGrant() = %+v, want %+v", got, tt.want)
+			}
+			if tt.wantGrant != nil && !reflect.DeepEqual(grant, *tt.wantGrant) {
+				t.Errorf("grant = %+v, want %+v", grant, *tt.wantGrant)
+			}
+		})
+	}
+}
+
+func TestGrantsReport(t *testing.T) {
+	deps := Dependencies{Data: newTestDataProvider(t, grantTestLedgers()...), Headers: DefaultHeaders()}
+	ctx := context.Background()
+
+	body := `{"grantor": "Council", "purpose": "Learn to ride", "amountApproved": "5000.00", "approvedDate": "2025-02-14", "acquittalDue": "2025-12-31",
+		"received": [{"ledgerType": "BANK", "transactionId": "grant"}],
+		"expenditure": [{"ledgerType": "BANK", "transactionId": "bikes"}]}`
+	got, err := GrantsPost(ctx, events.APIGatewayProxyRequest{Body: body}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("GrantsPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var saved grantView
+	if err := json.Unmarshal([]byte(got.Body), &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" || saved.Status != grantApproved || saved.TotalReceived != 500000 || saved.Unexpended != 180000 {
+		t.Errorf("saved = %+v", saved)
+	}
+
+	// Only the grant money spent is income, so the net result matches the unchanged accumulated
+	// funds of $100
+	wantNote := ReportNote{Title: "Grants", Details: []string{
+		"Council (Learn to ride): approved $5,000.00, received $5,000.00, expended $3,200.00, unexpended $1,800.00; acquittal due 2025-12-31.",
+	}}
+	check := func(name string) {
+		t.Helper()
+		report, err := buildFinancialReport(ctx, deps, financialYearPeriod("fy-1", 2025))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(report.Statement.Income, []ReportLineItem{{Label: "Grant income", Amount: 320000}}) || report.Statement.NetResult != 0 {
+			t.Errorf("%s: statement = %+v", name, report.Statement)
+		}
+		if !reflect.DeepEqual(report.BalanceSheet.Liabilities, []ReportLineItem{{Label: "Unexpended grants", Amount: 180000}}) {
+			t.Errorf("%s: liabilities = %+v", name, report.BalanceSheet.Liabilities)
+		}
+		if report.BalanceSheet.TotalAssets != 190000 || report.BalanceSheet.Equity != 10000 {
+			t.Errorf("%s: balance sheet = %+v", name, report.BalanceSheet)
+		}
+		if !reflect.DeepEqual(report.Notes[1], wantNote) {
+			t.Errorf("%s: grants note = %+v, want %+v", name, report.Notes[1], wantNote)
+		}
+	}
+	check("ledgers")
+	got, err = JournalMigrate(ctx, events.APIGatewayProxyRequest{}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("JournalMigrate() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	check("journal")
+
+	// The grant was approved after FY 2024 ended, so that year has neither the note nor the liability
+	earlier, err := buildFinancialReport(ctx, deps, financialYearPeriod("fy-2", 2024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(earlier.BalanceSheet.Liabilities) != 0 || earlier.Notes[1].Details[0] != "No grants in the grant register for the period." {
+		t.Errorf("FY 2024 = %+v, %+v", earlier.BalanceSheet, earlier.Notes[1])
+	}
+
+	// Editing and then deleting the linked expenditure is picked up without re-saving the grant
+	bank, err := loadLedgerMonths(ctx, deps, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank[0].Transactions[1].Amount = -300000
+	recalculateLedger(&bank[0])
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, bank)}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	report, err := buildFinancialReport(ctx, deps, financialYearPeriod("fy-1", 2025))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "expended $3,000.00"; !strings.Contains(report.Notes[1].Details[0], want) || len(report.Notes) != 4 {
+		t.Errorf("edited: notes = %+v, want %q", report.Notes, want)
+	}
+	bank, err = loadLedgerMonths(ctx, deps, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank[0].Transactions = bank[0].Transactions[:1]
+	recalculateLedger(&bank[0])
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, bank)}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	report, err = buildFinancialReport(ctx, deps, financialYearPeriod("fy-1", 2025))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUnresolved := ReportNote{Title: "Unresolved register links", Details: []string{
+		"Council (Learn to ride): expenditure BANK transaction bikes is no longer in the ledger and is left out.",
+	}}
+	if len(report.Notes) != 5 || !reflect.DeepEqual(report.Notes[4], wantUnresolved) || !strings.Contains(report.Notes[1].Details[0], "expended $0.00") {
+		t.Errorf("deleted: notes = %+v", report.Notes)
+	}
+
+	got, err = GrantsDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": saved.ID}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Errorf("GrantsDelete() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+}
+
+func TestGrantIncome(t *testing.T) {
+	grants := []Grant{
+		{Status: grantApproved,
+			Received:    []GrantReceipt{{Date: "2025-03-03", Amount: 500000}},
+			Expenditure: []GrantExpenditure{{Date: "2025-03-20", Amount: 320000}, {Date: "2025-08-01", Amount: 100000}},
+		},
+		{Status: grantDeclined, Received: []GrantReceipt{{Date: "2025-03-03", Amount: 90000}}},
+	}
+	fy2025, fy2026 := financialYearPeriod("fy", 2025), financialYearPeriod("fy", 2026)
+	// What is left unspent at the end of one year is income of the year it is spent in
+	if got := grantIncome(grants, fy2025.Start, fy2025.End); got != 320000 {
+		t.Errorf("grantIncome(FY 2025) = %s", got)
+	}
+	if got := grantIncome(grants, fy2026.Start, fy2026.End); got != 100000 {
+		t.Errorf("grantIncome(FY 2026) = %s", got)
+	}
+	if got := unexpendedGrants(grants, fy2026.End); got != 80000 {
+		t.Errorf("unexpendedGrants(FY 2026) = %s", got)
+	}
+}
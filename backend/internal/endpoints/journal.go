@@ -0,0 +1,935 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const (
+	journalPrefix = "journal/"
+	// journalStatePath records when the ledgers were migrated; until then reports read the ledgers
+	journalStatePath = "journal.json"
+
+	openingBalancesAccountID = "opening-balances"
+	// transfersAccountID clears transfers between ledgers; both sides together leave it at zero
+	transfersAccountID = "transfers"
+	// trustMoneyAccountID holds every trust fund; reports split its balance by fund
+	trustMoneyAccountID = "trust-money"
+	ledgerAccountPrefix = "ledger-"
+)
+
+var ledgerAccountNames = map[string]string{
+	"BANK": "Bank account",
+	"CASH": "Cash on hand",
+	"CARD": "Card balance",
+}
+
+// JournalLine debits or credits one account. Exactly one of Debit and Credit is non-zero.
+type JournalLine struct {
+	Account string      `json:"account"`
+	Debit   money.Cents `json:"debit"`
+	Credit  money.Cents `json:"credit"`
+	Memo    string      `json:"memo,omitempty"`
+}
+
+// JournalSource marks an entry generated from a cash ledger. TransactionID is empty on the entry
+// that carries the ledger's opening balance.
+type JournalSource struct {
+	LedgerType    string `json:"ledgerType"`
+	TransactionID string `json:"transactionId,omitempty"`
+}
+
+type JournalEntry struct {
+	ID          string         `json:"id"`
+	Date        string         `json:"date"`
+	Description string         `json:"description"`
+	Lines       []JournalLine  `json:"lines"`
+	Source      *JournalSource `json:"source,omitempty"`
+}
+
+type JournalMonth struct {
+	Month   string         `json:"month"`
+	Entries []JournalEntry `json:"entries"`
+	// Version increments on every save
+	Version int64 `json:"version"`
+}
+
+type journalState struct {
+	MigratedAt string `json:"migratedAt"`
+}
+
+// JournalAccount is one account in the chart of accounts: a category, the asset account behind a
+// cash ledger, opening balances, transfers or trust money. Balance is on the account's normal side.
+type JournalAccount struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Code       string      `json:"code,omitempty"`
+	Kind       string      `json:"kind"`
+	LedgerType string      `json:"ledgerType,omitempty"`
+	Balance    money.Cents `json:"balance"`
+}
+
+type journalEntryResponse struct {
+	Status string       `json:"status"`
+	Month  string       `json:"month"`
+	Entry  JournalEntry `json:"entry"`
+}
+
+type journalMigrateResponse struct {
+	Status      string   `json:"status"`
+	DryRun      bool     `json:"dryRun"`
+	LedgerTypes []string `json:"ledgerTypes"`
+	Entries     int      `json:"entries"`
+	// Months are the journal months written or, on a dry run, that would be written
+	Months []string `json:"months"`
+	// AddedCategories are names used by transactions that were not yet in the chart of accounts
+	AddedCategories []Category `json:"addedCategories"`
+}
+
+func ledgerAccountID(ledgerType string) string {
+	return ledgerAccountPrefix + strings.ToLower(ledgerType)
+}
+
+func ledgerAccountName(ledgerType string) string {
+	if name := ledgerAccountNames[ledgerType]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s ledger", ledgerType)
+}
+
+// isSystemAccountID reports whether id belongs to an account the journal maintains itself
+func isSystemAccountID(id string) bool {
+	return id == openingBalancesAccountID || id == transfersAccountID || id == trustMoneyAccountID || strings.HasPrefix(id, ledgerAccountPrefix)
+}
+
+// chartOfAccounts holds every account by ID
+type chartOfAccounts map[string]JournalAccount
+
+func newChartOfAccounts(categories []Category, ledgerTypes []string) chartOfAccounts {
+	chart := chartOfAccounts{}
+	for _, category := range categories {
+		chart[category.ID] = JournalAccount{ID: category.ID, Name: category.Name, Code: category.Code, Kind: category.Kind}
+	}
+	chart[openingBalancesAccountID] = JournalAccount{ID: openingBalancesAccountID, Name: "Opening balances", Kind: categoryEquity}
+	chart[transfersAccountID] = JournalAccount{ID: transfersAccountID, Name: "Transfers between ledgers", Kind: categoryTransfer}
+	chart[trustMoneyAccountID] = JournalAccount{ID: trustMoneyAccountID, Name: "Trust money", Kind: categoryLiability}
+	for _, ledgerType := range ledgerTypes {
+		id := ledgerAccountID(ledgerType)
+		chart[id] = JournalAccount{ID: id, Name: ledgerAccountName(ledgerType), Kind: categoryAsset, LedgerType: ledgerType}
+	}
+	return chart
+}
+
+// account returns the account with id, or one named after the ID with no kind if the chart lacks it
+func (c chartOfAccounts) account(id string) JournalAccount {
+	if account, ok := c[id]; ok {
+		return account
+	}
+	return JournalAccount{ID: id, Name: id}
+}
+
+// signedAmount is the line's effect on the account's balance: debits increase assets and
+// expenses, credits increase liabilities, equity and income
+func (a JournalAccount) signedAmount(line JournalLine) money.Cents {
+	switch a.Kind {
+	case categoryLiability, categoryEquity, categoryIncome:
+		return line.Credit - line.Debit
+	}
+	return line.Debit - line.Credit
+}
+
+// isOpeningBalance reports whether the entry carries a cash ledger's opening balance
+func (e JournalEntry) isOpeningBalance() bool {
+	return e.Source != nil && e.Source.TransactionID == ""
+}
+
+// journalLedgerTypes lists the ledger types that have generated entries in months
+func journalLedgerTypes(months []JournalMonth) []string {
+	seen := map[string]bool{}
+	types := []string{}
+	for _, month := range months {
+		for _, entry := range month.Entries {
+			if entry.Source != nil && !seen[entry.Source.LedgerType] {
+				seen[entry.Source.LedgerType] = true
+				types = append(types, entry.Source.LedgerType)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// balancedLines moves amount from creditAccount to debitAccount, swapping the two when it is negative
+func balancedLines(debitAccount, creditAccount string, amount money.Cents) []JournalLine {
+	if amount < 0 {
+		debitAccount, creditAccount, amount = creditAccount, debitAccount, -amount
+	}
+	return []JournalLine{{Account: debitAccount, Debit: amount}, {Account: creditAccount, Credit: amount}}
+}
+
+// categoryAccounts resolves transaction category names to account IDs, adding a category with no
+// kind for any name the chart does not have yet
+type categoryAccounts struct {
+	categories []Category
+	index      categoryIndex
+	added      []Category
+}
+
+func newCategoryAccounts(categories []Category) *categoryAccounts {
+	return &categoryAccounts{categories: append([]Category{}, categories...), index: newCategoryIndex(categories)}
+}
+
+func (c *categoryAccounts) accountFor(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Uncategorised"
+	}
+	if category, ok := c.index.lookup(name); ok {
+		return category.ID
+	}
+	base := categorySlug(name)
+	if base == "" {
+		base = "category"
+	}
+	id := base
+	for n := 2; findCategory(c.categories, id) >= 0 || isSystemAccountID(id); n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	category := Category{ID: id, Name: name, Active: true}
+	c.categories = append(c.categories, category)
+	c.index[strings.ToLower(name)] = category
+	c.added = append(c.added, category)
+	return id
+}
+
+// ledgerJournalEntries turns one ledger type's months into journal entries keyed by month. Money
+// in debits the ledger's asset account and credits the transaction's category, money out does
+// the reverse, and the earliest month's opening balance is posted against opening balances.
+// Transfers between ledgers post against the transfers account and trust money against the trust
+// money account, whatever their category.
+func ledgerJournalEntries(ledgerType string, ledgers []MonthlyLedger, accounts *categoryAccounts) map[string][]JournalEntry {
+	entries := map[string][]JournalEntry{}
+	ledgerAccount := ledgerAccountID(ledgerType)
+	for i, ledger := range ledgers {
+		if i == 0 && ledger.OpeningBalance != 0 {
+			entries[ledger.Month] = append(entries[ledger.Month], JournalEntry{
+				ID:          ledgerAccount + "-opening",
+				Date:        ledger.Month + "-01",
+				Description: "Opening balance",
+				Lines:       balancedLines(ledgerAccount, openingBalancesAccountID, ledger.OpeningBalance),
+				Source:      &JournalSource{LedgerType: ledgerType},
+			})
+		}
+		for _, tx := range ledger.Transactions {
+			if tx.Amount == 0 {
+				continue
+			}
+			var contra string
+			switch {
+			case tx.TransferID != "":
+				contra = transfersAccountID
+			case tx.TrustFundID != "":
+				contra = trustMoneyAccountID
+			default:
+				contra = accounts.accountFor(tx.Category)
+			}
+			entries[ledger.Month] = append(entries[ledger.Month], JournalEntry{
+				ID:          ledgerAccount + "-" + tx.ID,
+				Date:        tx.Date,
+				Description: tx.Description,
+				Lines:       balancedLines(ledgerAccount, contra, tx.Amount),
+				Source:      &JournalSource{LedgerType: ledgerType, TransactionID: tx.ID},
+			})
+		}
+	}
+	return entries
+}
+
+// sortJournalEntries orders entries by date, putting entries made in the journal ahead of
+// generated ones and keeping each ledger's own order within a day
+func sortJournalEntries(entries []JournalEntry) {
+	group := func(entry JournalEntry) string {
+		if entry.Source == nil {
+			return ""
+		}
+		return entry.Source.LedgerType
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Date != entries[j].Date {
+			return entries[i].Date < entries[j].Date
+		}
+		return group(entries[i]) < group(entries[j])
+	})
+}
+
+// journalSyncPlan is what regenerating some ledger types' entries changes in the journal
+type journalSyncPlan struct {
+	// Months are the journal months whose entries changed, with versions as stored
+	Months          []JournalMonth
+	AddedCategories []Category
+	Entries         int
+}
+
+// planJournalSync replaces the generated entries of every ledger type in ledgersByType with
+// entries built from its ledgers, leaving other types and entries made in the journal alone
+func planJournalSync(categories []Category, ledgersByType map[string][]MonthlyLedger, stored []JournalMonth) journalSyncPlan {
+	plan := journalSyncPlan{Months: []JournalMonth{}, AddedCategories: []Category{}}
+	accounts := newCategoryAccounts(categories)
+	types := make([]string, 0, len(ledgersByType))
+	for ledgerType := range ledgersByType {
+		types = append(types, ledgerType)
+	}
+	sort.Strings(types)
+
+	generated := map[string][]JournalEntry{}
+	for _, ledgerType := range types {
+		for month, entries := range ledgerJournalEntries(ledgerType, ledgersByType[ledgerType], accounts) {
+			generated[month] = append(generated[month], entries...)
+			plan.Entries += len(entries)
+		}
+	}
+	plan.AddedCategories = append(plan.AddedCategories, accounts.added...)
+
+	byMonth := map[string]JournalMonth{}
+	for _, month := range stored {
+		byMonth[month.Month] = month
+	}
+	for month := range generated {
+		if _, ok := byMonth[month]; !ok {
+			byMonth[month] = JournalMonth{Month: month, Entries: []JournalEntry{}}
+		}
+	}
+	months := make([]string, 0, len(byMonth))
+	for month := range byMonth {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	for _, month := range months {
+		current := byMonth[month]
+		entries := []JournalEntry{}
+		for _, entry := range current.Entries {
+			if entry.Source != nil {
+				if _, syncing := ledgersByType[entry.Source.LedgerType]; syncing {
+					continue
+				}
+			}
+			entries = append(entries, entry)
+		}
+		entries = append(entries, generated[month]...)
+		sortJournalEntries(entries)
+
+		before, _ := json.Marshal(current.Entries)
+		after, _ := json.Marshal(entries)
+		if bytes.Equal(before, after) {
+			continue
+		}
+		current.Entries = entries
+		plan.Months = append(plan.Months, current)
+	}
+	return plan
+}
+
+func journalEnabled(ctx context.Context, deps Dependencies) (bool, error) {
+	_, err := deps.Data.Get(ctx, journalStatePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// loadJournalMonthsWithETags reads every stored journal month, ordered by month, with its ETag
+func loadJournalMonthsWithETags(ctx context.Context, deps Dependencies) ([]JournalMonth, map[string]string, error) {
+	files, err := deps.Data.List(ctx, strings.TrimSuffix(journalPrefix, "/"))
+	if err != nil {
+		return nil, nil, err
+	}
+	months := []JournalMonth{}
+	etags := map[string]string{}
+	for _, file := range files {
+		if file.IsDir || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		content, etag, err := deps.Data.GetWithETag(ctx, file.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		var month JournalMonth
+		if err := json.Unmarshal(content, &month); err != nil {
+			return nil, nil, err
+		}
+		if month.Month == "" {
+			month.Month = strings.TrimSuffix(file.Name, ".json")
+		}
+		months = append(months, month)
+		etags[month.Month] = etag
+	}
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Month < months[j].Month
+	})
+	return months, etags, nil
+}
+
+func loadJournalMonth(ctx context.Context, deps Dependencies, month string) (JournalMonth, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, journalPrefix+month+".json")
+	if errors.Is(err, storage.ErrNotFound) {
+		return JournalMonth{Month: month, Entries: []JournalEntry{}}, "", nil
+	}
+	if err != nil {
+		return JournalMonth{}, "", err
+	}
+	var journal JournalMonth
+	if err := json.Unmarshal(content, &journal); err != nil {
+		return JournalMonth{}, "", err
+	}
+	journal.Month = month
+	return journal, etag, nil
+}
+
+// saveJournalMonth bumps the month's version and writes it if it is unchanged since etag was read
+func saveJournalMonth(ctx context.Context, deps Dependencies, journal JournalMonth, etag string) error {
+	journal.Version++
+	content, _ := json.Marshal(journal)
+	return deps.Data.SaveIfMatch(ctx, journalPrefix+journal.Month+".json", content, etag)
+}
+
+// syncJournal regenerates the journal entries of each ledger type in ledgersByType, first adding
+// any categories the transactions use that the chart of accounts lacks
+func syncJournal(ctx context.Context, deps Dependencies, ledgersByType map[string][]MonthlyLedger) (journalSyncPlan, error) {
+	categories, categoriesETag, err := loadCategories(ctx, deps)
+	if err != nil {
+		return journalSyncPlan{}, err
+	}
+	stored, etags, err := loadJournalMonthsWithETags(ctx, deps)
+	if err != nil {
+		return journalSyncPlan{}, err
+	}
+	plan := planJournalSync(categories, ledgersByType, stored)
+	if len(plan.AddedCategories) > 0 {
+		if err := saveCategories(ctx, deps, append(categories, plan.AddedCategories...), categoriesETag); err != nil {
+			return plan, err
+		}
+		fmt.Printf("Added %d category(ies) used by ledger transactions to the chart of accounts\n", len(plan.AddedCategories))
+	}
+	for _, month := range plan.Months {
+		if err := saveJournalMonth(ctx, deps, month, etags[month.Month]); err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+// syncLedgerJournal brings the journal up to date with one ledger type once the ledgers have
+// been migrated. Until then there is nothing to keep in step.
+func syncLedgerJournal(ctx context.Context, deps Dependencies, ledgerType string) error {
+	enabled, err := journalEnabled(ctx, deps)
+	if err != nil || !enabled {
+		return err
+	}
+	ledgers, err := loadLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return err
+	}
+	plan, err := syncJournal(ctx, deps, map[string][]MonthlyLedger{ledgerType: ledgers})
+	if err == nil && len(plan.Months) > 0 {
+		fmt.Printf("Synced %s ledger into %d journal month(s)\n", ledgerType, len(plan.Months))
+	}
+	return err
+}
+
+// journalAccountLocked returns a *ledgerLockedError naming the journal months in a locked
+// period that have lines on account, or nil if there are none
+func journalAccountLocked(ctx context.Context, deps Dependencies, account string) error {
+	months, _, err := loadJournalMonthsWithETags(ctx, deps)
+	if err != nil {
+		return err
+	}
+	locks, _, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		return err
+	}
+	blocked := &ledgerLockedError{}
+	for _, month := range months {
+		reason := periodLockReason(locks, month.Month)
+		if reason == "" || !journalMonthUsesAccount(month, account) {
+			continue
+		}
+		blocked.Months = append(blocked.Months, month.Month)
+		blocked.Reasons = append(blocked.Reasons, reason)
+	}
+	if len(blocked.Months) > 0 {
+		return blocked
+	}
+	return nil
+}
+
+func journalMonthUsesAccount(month JournalMonth, account string) bool {
+	for _, entry := range month.Entries {
+		for _, line := range entry.Lines {
+			if line.Account == account {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewriteJournalAccount moves every journal line on account from to account to. It changes
+// nothing if any month with such a line is in a locked period.
+func rewriteJournalAccount(ctx context.Context, deps Dependencies, from, to string) error {
+	if err := journalAccountLocked(ctx, deps, from); err != nil {
+		return err
+	}
+	months, etags, err := loadJournalMonthsWithETags(ctx, deps)
+	if err != nil {
+		return err
+	}
+	for _, month := range months {
+		touched := false
+		for i := range month.Entries {
+			for j := range month.Entries[i].Lines {
+				if month.Entries[i].Lines[j].Account == from {
+					month.Entries[i].Lines[j].Account = to
+					touched = true
+				}
+			}
+		}
+		if !touched {
+			continue
+		}
+		if err := saveJournalMonth(ctx, deps, month, etags[month.Month]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateJournalEntry checks that an entry made in the journal balances and posts only to
+// category accounts or opening balances. Cash ledger accounts are posted through their ledgers.
+func validateJournalEntry(entry JournalEntry, categories []Category) []ValidationViolation {
+	violations := []ValidationViolation{}
+	if _, err := time.Parse("2006-01-02", entry.Date); err != nil || len(entry.Date) != len("2006-01-02") {
+		violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: "date", Message: "Date must be YYYY-MM-DD"})
+	}
+	if entry.Source != nil {
+		violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: "source", Message: "Entries generated from a ledger are changed through that ledger"})
+	}
+	if len(entry.Lines) < 2 {
+		violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: "lines", Message: "An entry needs at least two lines"})
+	}
+
+	chart := newChartOfAccounts(categories, nil)
+	var debits, credits money.Cents
+	for i, line := range entry.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		switch {
+		case line.Account == "":
+			violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: field + ".account", Message: "Account is required"})
+		case strings.HasPrefix(line.Account, ledgerAccountPrefix):
+			violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: field + ".account", Message: fmt.Sprintf("Account %s is kept from its ledger; record cash movements there", line.Account)})
+		case line.Account == trustMoneyAccountID:
+			violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: field + ".account", Message: "Trust money is kept from ledger transactions tagged to a trust fund"})
+		default:
+			if _, ok := chart[line.Account]; !ok {
+				violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: field + ".account", Message: fmt.Sprintf("Account %s is not in the chart of accounts", line.Account)})
+			}
+		}
+		if line.Debit < 0 || line.Credit < 0 || (line.Debit == 0) == (line.Credit == 0) {
+			violations = append(violations, ValidationViolation{TransactionID: entry.ID, Field: field, Message: "Each line needs either a positive debit or a positive credit"})
+		}
+		debits += line.Debit
+		credits += line.Credit
+	}
+	if debits != credits {
+		violations = append(violations, ValidationViolation{
+			TransactionID: entry.ID,
+			Field:         "lines",
+			Message:       fmt.Sprintf("Debits %s do not equal credits %s", debits.Format(), credits.Format()),
+		})
+	}
+	return violations
+}
+
+// journalAccountBalances totals every account's balance over entries dated on or before end
+func journalAccountBalances(months []JournalMonth, chart chartOfAccounts, end time.Time) map[string]money.Cents {
+	balances := map[string]money.Cents{}
+	for _, month := range months {
+		for _, entry := range month.Entries {
+			date, ok := parseTransactionDate(entry.Date)
+			if !ok || date.After(end) {
+				continue
+			}
+			for _, line := range entry.Lines {
+				balances[line.Account] += chart.account(line.Account).signedAmount(line)
+			}
+		}
+	}
+	return balances
+}
+
+// buildJournalStatement totals income and expense accounts over entries dated within the period.
+// Lines on accounts with no kind fall on the side the money moved, as ledger reports did.
+func buildJournalStatement(start, end time.Time, months []JournalMonth, chart chartOfAccounts) ([]ReportLineItem, []ReportLineItem, money.Cents, money.Cents) {
+	incomeTotals := map[string]money.Cents{}
+	expenseTotals := map[string]money.Cents{}
+	for _, month := range months {
+		for _, entry := range month.Entries {
+			date, ok := parseTransactionDate(entry.Date)
+			if !ok || date.Before(start) || date.After(end) {
+				continue
+			}
+			for _, line := range entry.Lines {
+				account := chart.account(line.Account)
+				switch account.Kind {
+				case categoryIncome:
+					incomeTotals[account.Name] += line.Credit - line.Debit
+				case categoryExpense:
+					expenseTotals[account.Name] += line.Debit - line.Credit
+				case "":
+					if line.Credit > 0 {
+						incomeTotals[account.Name] += line.Credit
+					} else {
+						expenseTotals[account.Name] += line.Debit
+					}
+				}
+			}
+		}
+	}
+	incomeItems := mapTotalsToItems(incomeTotals)
+	expenseItems := mapTotalsToItems(expenseTotals)
+	return incomeItems, expenseItems, sumTotals(incomeItems), sumTotals(expenseItems)
+}
+
+// buildJournalBalanceSheet lists asset and liability account balances as at end. Cash ledger
+// accounts are listed once they have any entries; other accounts only when not zero. Trust money
+// is left to the caller, which reports it by fund.
+func buildJournalBalanceSheet(end time.Time, months []JournalMonth, chart chartOfAccounts) ([]ReportLineItem, []ReportLineItem) {
+	balances := journalAccountBalances(months, chart, end)
+	assets := []ReportLineItem{}
+	liabilities := []ReportLineItem{}
+	for id, balance := range balances {
+		account := chart.account(id)
+		switch {
+		case id == trustMoneyAccountID:
+			continue
+		case account.Kind == categoryAsset && (account.LedgerType != "" || balance != 0):
+			assets = append(assets, ReportLineItem{Label: account.Name, Amount: balance})
+		case account.Kind == categoryLiability && balance != 0:
+			liabilities = append(liabilities, ReportLineItem{Label: account.Name, Amount: balance})
+		}
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Label < assets[j].Label
+	})
+	sort.Slice(liabilities, func(i, j int) bool {
+		return liabilities[i].Label < liabilities[j].Label
+	})
+	return assets, liabilities
+}
+
+// journalAccountView presents one account's entries for a month in the shape of a ledger, so a
+// cash ledger's asset account reads the same as the ledger itself. Amounts are on the account's
+// normal side and each transaction's category names the other accounts in the entry.
+func journalAccountView(account JournalAccount, month string, months []JournalMonth, chart chartOfAccounts) MonthlyLedger {
+	view := MonthlyLedger{
+		PK:           fmt.Sprintf("JOURNAL#%s#%s", account.ID, month),
+		Month:        month,
+		Type:         account.ID,
+		Transactions: []Transaction{},
+	}
+	for _, journal := range months {
+		if journal.Month > month {
+			break
+		}
+		for _, entry := range journal.Entries {
+			var amount money.Cents
+			others := []string{}
+			posted := false
+			for _, line := range entry.Lines {
+				if line.Account == account.ID {
+					amount += account.signedAmount(line)
+					posted = true
+				} else {
+					others = append(others, chart.account(line.Account).Name)
+				}
+			}
+			if !posted {
+				continue
+			}
+			if journal.Month < month || entry.isOpeningBalance() {
+				view.OpeningBalance += amount
+				continue
+			}
+			id := entry.ID
+			if entry.Source != nil {
+				id = entry.Source.TransactionID
+			}
+			view.Transactions = append(view.Transactions, Transaction{
+				ID:          id,
+				Date:        entry.Date,
+				Category:    strings.Join(others, ", "),
+				Description: entry.Description,
+				Amount:      amount,
+			})
+		}
+	}
+	recalculateLedger(&view)
+	return view
+}
+
+func monthQueryParam(request events.APIGatewayProxyRequest, headers map[string]string) (string, *events.APIGatewayProxyResponse) {
+	month := request.QueryStringParameters["month"]
+	if month == "" {
+		return "", &events.APIGatewayProxyResponse{Body: `{"error": "Month is required"}`, StatusCode: 400, Headers: headers}
+	}
+	if _, err := time.Parse("2006-01", month); err != nil || len(month) != len("2006-01") {
+		return "", &events.APIGatewayProxyResponse{Body: `{"error": "Month must be YYYY-MM"}`, StatusCode: 400, Headers: headers}
+	}
+	return month, nil
+}
+
+// JournalGet returns one month of journal entries; a month with none is returned empty
+func JournalGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	month, badRequest := monthQueryParam(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	journal, _, err := loadJournalMonth(ctx, deps, month)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	body, _ := json.Marshal(journal)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// JournalPost records or replaces an entry made directly in the journal, such as an accrual.
+// The ledgers must have been migrated first, since reports ignore the journal until then.
+func JournalPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var entry JournalEntry
+	if err := json.Unmarshal([]byte(request.Body), &entry); err != nil {
+		fmt.Printf("Invalid journal entry body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	enabled, err := journalEnabled(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if !enabled {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Migrate the ledgers into the journal first"}`, StatusCode: 409, Headers: deps.Headers}, nil
+	}
+
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if violations := validateJournalEntry(entry, categories); len(violations) > 0 {
+		return newValidationErrorResponse("Journal entry validation failed", violations, deps.Headers), nil
+	}
+	if entry.ID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return errorResponse(err, deps.Headers), nil
+		}
+		entry.ID = id
+	}
+
+	month := entry.Date[:len("2006-01")]
+	if locked := journalPeriodLocked(ctx, deps, month); locked != nil {
+		return *locked, nil
+	}
+	journal, etag, err := loadJournalMonth(ctx, deps, month)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	replaced := false
+	for i, existing := range journal.Entries {
+		if existing.ID != entry.ID {
+			continue
+		}
+		if existing.Source != nil {
+			body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Entry %s is kept from the %s ledger", entry.ID, existing.Source.LedgerType)})
+			return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+		}
+		journal.Entries[i] = entry
+		replaced = true
+	}
+	if !replaced {
+		journal.Entries = append(journal.Entries, entry)
+	}
+	sortJournalEntries(journal.Entries)
+	if err := saveJournalMonth(ctx, deps, journal, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+
+	fmt.Printf("Saved journal entry %s in %s\n", entry.ID, month)
+	body, _ := json.Marshal(journalEntryResponse{Status: "ok", Month: month, Entry: entry})
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// JournalDelete removes an entry made directly in the journal. Entries generated from a ledger go
+// when their transaction is removed from it.
+func JournalDelete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	month, badRequest := monthQueryParam(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	id := request.QueryStringParameters["id"]
+	if locked := journalPeriodLocked(ctx, deps, month); locked != nil {
+		return *locked, nil
+	}
+	journal, etag, err := loadJournalMonth(ctx, deps, month)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	for i, entry := range journal.Entries {
+		if entry.ID != id {
+			continue
+		}
+		if entry.Source != nil {
+			body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Entry %s is kept from the %s ledger", id, entry.Source.LedgerType)})
+			return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+		}
+		journal.Entries = append(journal.Entries[:i], journal.Entries[i+1:]...)
+		if err := saveJournalMonth(ctx, deps, journal, etag); err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		fmt.Printf("Deleted journal entry %s from %s\n", id, month)
+		body, _ := json.Marshal(journalEntryResponse{Status: "deleted", Month: month, Entry: entry})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: `{"error": "Entry not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+}
+
+// JournalAccountsGet lists the chart of accounts with balances as at the asAt date, or today
+func JournalAccountsGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	asAt := time.Now()
+	if raw := request.QueryStringParameters["asAt"]; raw != "" {
+		parsed, ok := parseTransactionDate(raw)
+		if !ok {
+			return events.APIGatewayProxyResponse{Body: `{"error": "asAt must be YYYY-MM-DD"}`, StatusCode: 400, Headers: deps.Headers}, nil
+		}
+		asAt = parsed
+	}
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	months, _, err := loadJournalMonthsWithETags(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+
+	chart := newChartOfAccounts(categories, journalLedgerTypes(months))
+	balances := journalAccountBalances(months, chart, asAt)
+	accounts := make([]JournalAccount, 0, len(chart))
+	for id, account := range chart {
+		account.Balance = balances[id]
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Code != accounts[j].Code {
+			return accounts[i].Code < accounts[j].Code
+		}
+		return accounts[i].Name < accounts[j].Name
+	})
+	body, _ := json.Marshal(accounts)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// JournalAccountView returns one account's month in ledger form. Cash movements only reach the
+// journal from the ledgers, so a ledger's asset account (ledger-bank, ledger-cash, ...) reads
+// exactly as the ledger does.
+func JournalAccountView(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	month, badRequest := monthQueryParam(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	months, _, err := loadJournalMonthsWithETags(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	chart := newChartOfAccounts(categories, journalLedgerTypes(months))
+	account, ok := chart[request.QueryStringParameters["id"]]
+	if !ok {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Account not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	body, _ := json.Marshal(journalAccountView(account, month, months, chart))
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// JournalMigrate converts every stored ledger month into journal entries and switches reports to
+// the journal. Running it again regenerates the ledger entries, which repairs a journal that fell
+// behind its ledgers. With dryRun=true nothing is written.
+func JournalMigrate(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	dryRun := false
+	if raw := request.QueryStringParameters["dryRun"]; raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return events.APIGatewayProxyResponse{Body: `{"error": "dryRun must be true or false"}`, StatusCode: 400, Headers: deps.Headers}, nil
+		}
+		dryRun = parsed
+	}
+
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	var plan journalSyncPlan
+	if dryRun {
+		categories, _, err := loadCategories(ctx, deps)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		stored, _, err := loadJournalMonthsWithETags(ctx, deps)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		plan = planJournalSync(categories, ledgersByType, stored)
+	} else {
+		plan, err = syncJournal(ctx, deps, ledgersByType)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		state, _ := json.Marshal(journalState{MigratedAt: time.Now().UTC().Format(time.RFC3339)})
+		if err := deps.Data.Save(ctx, journalStatePath, state); err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+	}
+
+	response := journalMigrateResponse{
+		Status:          "ok",
+		DryRun:          dryRun,
+		LedgerTypes:     []string{},
+		Entries:         plan.Entries,
+		Months:          []string{},
+		AddedCategories: plan.AddedCategories,
+	}
+	if dryRun {
+		response.Status = "preview"
+	}
+	for ledgerType := range ledgersByType {
+		response.LedgerTypes = append(response.LedgerTypes, ledgerType)
+	}
+	sort.Strings(response.LedgerTypes)
+	for _, month := range plan.Months {
+		response.Months = append(response.Months, month.Month)
+	}
+	fmt.Printf("Migrated ledgers into the journal: %d entries, %d month(s) changed, dry run %v\n", plan.Entries, len(plan.Months), dryRun)
+	body, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
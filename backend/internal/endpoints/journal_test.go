@@ -0,0 +1,228 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+func journalTestLedgers() []MonthlyLedger {
+	return []MonthlyLedger{
+		{
+			PK:             "LEDGER#BANK#2025-01",
+			Month:          "2025-01",
+			Type:           "BANK",
+			OpeningBalance: 10000,
+			ClosingBalance: 12000,
+			Version:        1,
+			Transactions: []Transaction{
+				{ID: "entries", Date: "2025-01-05", Category: "Event Fee", Description: "Race entries", Amount: 2500, RunningBalance: 12500},
+				{ID: "hall", Date: "2025-01-09", Category: "Hall Hire", Description: "Eureka Hall", Amount: -500, RunningBalance: 12000},
+			},
+		},
+		{
+			PK:             "LEDGER#BANK#2025-02",
+			Month:          "2025-02",
+			Type:           "BANK",
+			OpeningBalance: 12000,
+			ClosingBalance: 11000,
+			Version:        1,
+			Transactions: []Transaction{
+				{ID: "cones", Date: "2025-02-03", Category: "equipment", Description: "Cones", Amount: -1000, RunningBalance: 11000},
+			},
+		},
+	}
+}
+
+func TestJournalMigrate(t *testing.T) {
+	ledgers := journalTestLedgers()
+	prov := newTestDataProvider(t, ledgers...)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+
+	migrate := func(dryRun string) journalMigrateResponse {
+		t.Helper()
+		got, err := JournalMigrate(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"dryRun": dryRun}}, deps)
+		if err != nil || got.StatusCode != 200 {
+			t.Fatalf("JournalMigrate() = %d %s, %v", got.StatusCode, got.Body, err)
+		}
+		var response journalMigrateResponse
+		if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	preview := migrate("true")
+	if preview.Status != "preview" || preview.Entries != 4 || !reflect.DeepEqual(preview.Months, []string{"2025-01", "2025-02"}) {
+		t.Errorf("preview = %+v", preview)
+	}
+	if enabled, _ := journalEnabled(ctx, deps); enabled {
+		t.Fatal("dry run enabled the journal")
+	}
+
+	migrated := migrate("false")
+	wantAdded := []Category{{ID: "hall-hire", Name: "Hall Hire", Active: true}}
+	if migrated.Status != "ok" || !reflect.DeepEqual(migrated.AddedCategories, wantAdded) || !reflect.DeepEqual(migrated.LedgerTypes, []string{"BANK"}) {
+		t.Errorf("migrate = %+v", migrated)
+	}
+	january, _, err := loadJournalMonth(ctx, deps, "2025-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJanuary := []JournalEntry{
+		{
+			ID: "ledger-bank-opening", Date: "2025-01-01", Description: "Opening balance",
+			Lines:  []JournalLine{{Account: "ledger-bank", Debit: 10000}, {Account: openingBalancesAccountID, Credit: 10000}},
+			Source: &JournalSource{LedgerType: "BANK"},
+		},
+		{
+			ID: "ledger-bank-entries", Date: "2025-01-05", Description: "Race entries",
+			Lines:  []JournalLine{{Account: "ledger-bank", Debit: 2500}, {Account: "event-fee", Credit: 2500}},
+			Source: &JournalSource{LedgerType: "BANK", TransactionID: "entries"},
+		},
+		{
+			ID: "ledger-bank-hall", Date: "2025-01-09", Description: "Eureka Hall",
+			Lines:  []JournalLine{{Account: "hall-hire", Debit: 500}, {Account: "ledger-bank", Credit: 500}},
+			Source: &JournalSource{LedgerType: "BANK", TransactionID: "hall"},
+		},
+	}
+	if !reflect.DeepEqual(january.Entries, wantJanuary) || january.Version != 1 {
+		t.Errorf("january = %+v", january)
+	}
+
+	// Nothing has changed, so migrating again writes nothing
+	if again := migrate("false"); len(again.Months) != 0 || len(again.AddedCategories) != 0 {
+		t.Errorf("second migration = %+v", again)
+	}
+
+	// Ledger saves keep the journal in step
+	february := ledgers[1]
+	february.Transactions = append(february.Transactions, Transaction{ID: "subs", Date: "2025-02-10", Category: "Membership", Description: "Subs", Amount: 4000})
+	recalculateLedger(&february)
+	got, err := LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, []MonthlyLedger{february})}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	// The bank account reads as the ledger does
+	got, err = JournalAccountView(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": "ledger-bank", "month": "2025-02"}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("JournalAccountView() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var view MonthlyLedger
+	if err := json.Unmarshal([]byte(got.Body), &view); err != nil {
+		t.Fatal(err)
+	}
+	wantTransactions := []Transaction{
+		{ID: "cones", Date: "2025-02-03", Category: "Equipment", Description: "Cones", Amount: -1000, RunningBalance: 11000},
+		{ID: "subs", Date: "2025-02-10", Category: "Membership", Description: "Subs", Amount: 4000, RunningBalance: 15000},
+	}
+	if view.OpeningBalance != 12000 || view.ClosingBalance != 15000 || !reflect.DeepEqual(view.Transactions, wantTransactions) {
+		t.Errorf("view = %+v", view)
+	}
+
+	// A journal that cannot be brought into step is reported rather than ignored
+	if err := prov.Save(ctx, journalPrefix+"2025-02.json", []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	february.Version++
+	february.Transactions[1].Amount = 4500
+	recalculateLedger(&february)
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, []MonthlyLedger{february})}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var saved ledgerPostResponse
+	if err := json.Unmarshal([]byte(got.Body), &saved); err != nil {
+		t.Fatal(err)
+	}
+	if !saved.JournalStale || !reflect.DeepEqual(saved.Months, []string{"2025-02"}) {
+		t.Errorf("LedgerPost() with a broken journal = %+v", saved)
+	}
+}
+
+func TestValidateJournalEntry(t *testing.T) {
+	categories := []Category{
+		{ID: "equipment", Name: "Equipment", Kind: categoryExpense},
+		{ID: "creditors", Name: "Creditors", Kind: categoryLiability},
+	}
+	tests := []struct {
+		name  string
+		entry JournalEntry
+		want  []ValidationViolation
+	}{
+		{
+			name: "Balanced accrual",
+			entry: JournalEntry{ID: "accrual", Date: "2025-06-30", Lines: []JournalLine{
+				{Account: "equipment", Debit: 30000}, {Account: "creditors", Credit: 30000},
+			}},
+			want: []ValidationViolation{},
+		},
+		{
+			name: "Unbalanced",
+			entry: JournalEntry{ID: "accrual", Date: "2025-06-30", Lines: []JournalLine{
+				{Account: "equipment", Debit: 30000}, {Account: "creditors", Credit: 20000},
+			}},
+			want: []ValidationViolation{{TransactionID: "accrual", Field: "lines", Message: "Debits $300.00 do not equal credits $200.00"}},
+		},
+		{
+			name: "Bad lines",
+			entry: JournalEntry{ID: "bad", Date: "30/06/2025", Lines: []JournalLine{
+				{Account: "ledger-bank", Debit: 100}, {Account: "prepayments", Debit: 100, Credit: 100},
+			}},
+			want: []ValidationViolation{
+				{TransactionID: "bad", Field: "date", Message: "Date must be YYYY-MM-DD"},
+				{TransactionID: "bad", Field: "lines[0].account", Message: "Account ledger-bank is kept from its ledger; record cash movements there"},
+				{TransactionID: "bad", Field: "lines[1].account", Message: "Account prepayments is not in the chart of accounts"},
+				{TransactionID: "bad", Field: "lines[1]", Message: "Each line needs either a positive debit or a positive credit"},
+				{TransactionID: "bad", Field: "lines", Message: "Debits $2.00 do not equal credits $1.00"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateJournalEntry(tt.entry, categories); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateJournalEntry() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalReports(t *testing.T) {
+	ledgers := journalTestLedgers()
+	categories := append(append([]Category{}, defaultCategories...), Category{ID: "creditors", Name: "Creditors", Kind: categoryLiability})
+	plan := planJournalSync(categories, map[string][]MonthlyLedger{"BANK": ledgers}, nil)
+	categories = append(categories, plan.AddedCategories...)
+	chart := newChartOfAccounts(categories, []string{"BANK"})
+	start, end := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	// Migrated books report the same statement as the ledgers they came from
+	ledgerIncome, ledgerExpense, _, _ := buildStatement(start, end, map[string][]MonthlyLedger{"BANK": ledgers}, newCategoryIndex(categories))
+	income, expense, _, _ := buildJournalStatement(start, end, plan.Months, chart)
+	if !reflect.DeepEqual(income, ledgerIncome) || !reflect.DeepEqual(expense, ledgerExpense) {
+		t.Errorf("journal statement = %+v %+v, ledger statement = %+v %+v", income, expense, ledgerIncome, ledgerExpense)
+	}
+
+	// An accrual adds an expense and a liability without touching the bank
+	june := JournalMonth{Month: "2025-06", Entries: []JournalEntry{{ID: "accrual", Date: "2025-06-30", Lines: []JournalLine{
+		{Account: "equipment", Debit: 30000}, {Account: "creditors", Credit: 30000},
+	}}}}
+	months := append(plan.Months, june)
+	_, expense, _, totalExpense := buildJournalStatement(start, end, months, chart)
+	assets, liabilities := buildJournalBalanceSheet(end, months, chart)
+	if !reflect.DeepEqual(expense, []ReportLineItem{{Label: "Equipment", Amount: 31000}, {Label: "Hall Hire", Amount: 500}}) || totalExpense != 31500 {
+		t.Errorf("expense = %+v", expense)
+	}
+	if !reflect.DeepEqual(assets, []ReportLineItem{{Label: "Bank account", Amount: 11000}}) {
+		t.Errorf("assets = %+v", assets)
+	}
+	if !reflect.DeepEqual(liabilities, []ReportLineItem{{Label: "Creditors", Amount: money.Cents(30000)}}) {
+		t.Errorf("liabilities = %+v", liabilities)
+	}
+}
@@ -7,67 +7,125 @@ import (
 	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
 	"github.com/go-pdf/fpdf"
 )
 
 type Transaction struct {
-	ID             string  `json:"id"`
-	Date           string  `json:"date"`
-	Category       string  `json:"category"`
-	Description    string  `json:"description"`
-	Amount         float64 `json:"amount"`
-	RunningBalance float64 `json:"runningBalance"`
+	ID             string      `json:"id"`
+	Date           string      `json:"date"`
+	Category       string      `json:"category"`
+	Description    string      `json:"description"`
+	Amount         money.Cents `json:"amount"`
+	RunningBalance money.Cents `json:"runningBalance"`
+	// TransferID links the two sides of a transfer between ledgers
+	TransferID string `json:"transferId,omitempty"`
+	// ReimbursementID links the payment of a reimbursement claim to the claim
+	ReimbursementID string `json:"reimbursementId,omitempty"`
+	// TrustFundID tags money held on behalf of others to its fund in the trust fund register
+	TrustFundID string `json:"trustFundId,omitempty"`
+	// Attachments are receipts and other evidence for the transaction in the documents bucket
+	Attachments []Attachment `json:"attachments,omitempty"`
 }
 
 type MonthlyLedger struct {
 	PK             string        `json:"pk"`
 	Month          string        `json:"month"`
 	Type           string        `json:"type"`
-	OpeningBalance float64       `json:"openingBalance"`
-	ClosingBalance float64       `json:"closingBalance"`
+	OpeningBalance money.Cents   `json:"openingBalance"`
+	ClosingBalance money.Cents   `json:"closingBalance"`
 	Transactions   []Transaction `json:"transactions"`
+	// Version increments on every save; LedgerPost rejects writes based on an older version
+	Version int64 `json:"version"`
 }
 
 const ledgerPrefix = "ledger/"
 
 type bankImportRequest struct {
-	CSV            string   `json:"csv"`
-	CurrentBalance *float64 `json:"currentBalance"`
-	Type           string   `json:"type"`
+	CSV            string       `json:"csv"`
+	Format         string       `json:"format"`
+	Mode           string       `json:"mode"`
+	DryRun         bool         `json:"dryRun"`
+	CurrentBalance *money.Cents `json:"currentBalance"`
+	Type           string       `json:"type"`
 }
 
 type bankImportRow struct {
 	OrigIdx        int
 	Date           time.Time
-	Amount         float64
+	Amount         money.Cents
 	Description    string
 	Category       string
 	Month          string
 	DateISO        string
-	RunningBalance float64
+	RunningBalance money.Cents
 	ID             string
 }
 
-type bankImportResponse struct {
+type ledgerPostResponse struct {
 	Status         string   `json:"status"`
-	Type           string   `json:"type"`
 	Months         []string `json:"months"`
-	Count          int      `json:"count"`
-	Transactions   int      `json:"transactions"`
-	OpeningBalance float64  `json:"openingBalance"`
-	ClosingBalance float64  `json:"closingBalance"`
+	CascadedMonths []string `json:"cascadedMonths"`
+	// RemovedTransfers are the other sides of transfers deleted from the submitted months
+	RemovedTransfers []ledgerTransferSide `json:"removedTransfers,omitempty"`
+	// ReopenedReimbursements are the claims whose payments were deleted, which are approved again
+	ReopenedReimbursements []string `json:"reopenedReimbursements,omitempty"`
+	// JournalStale is set when the months were saved but the journal could not be updated
+	JournalStale bool `json:"journalStale,omitempty"`
+}
+
+type ledgerSaveErrorBody struct {
+	Error          string   `json:"error"`
+	SavedMonths    []string `json:"savedMonths"`
+	ConflictMonths []string `json:"conflictMonths,omitempty"`
+	LockedMonths   []string `json:"lockedMonths,omitempty"`
 }
 
-func LedgerGet(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+type bankImportSkippedRow struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+	Text   string `json:"text"`
+}
+
+type bankImportResponse struct {
+	Status         string                 `json:"status"`
+	Type           string                 `json:"type"`
+	Months         []string               `json:"months"`
+	Count          int                    `json:"count"`
+	Transactions   int                    `json:"transactions"`
+	OpeningBalance money.Cents            `json:"openingBalance"`
+	ClosingBalance money.Cents            `json:"closingBalance"`
+	CascadedMonths []string               `json:"cascadedMonths"`
+	Format         string                 `json:"format"`
+	SkippedRows    []bankImportSkippedRow `json:"skippedRows"`
+	Mode           string                 `json:"mode"`
+	Matched        int                    `json:"matched"`
+	New            int                    `json:"new"`
+	Conflicting    int                    `json:"conflicting"`
+	Conflicts      []bankImportConflict   `json:"conflicts"`
+	DryRun         bool                   `json:"dryRun"`
+	// Suggestions are categories learned from past transactions for rows no rule matched
+	Suggestions []categorySuggestion `json:"suggestions"`
+	// Preview lists every month a dry run would write, compared with what is stored
+	Preview []ledgerMonthDiff `json:"preview,omitempty"`
+	// LockedMonths lists the months a dry run would write that are locked, so the import itself
+	// would be refused
+	LockedMonths []string `json:"lockedMonths,omitempty"`
+	// JournalStale is set when the months were saved but the journal could not be updated
+	JournalStale bool `json:"journalStale,omitempty"`
+}
+
+func LedgerGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	ledgerType := request.QueryStringParameters["type"]
 	if ledgerType == "" {
 		fmt.Printf("Missing ledger type\n")
@@ -87,11 +145,17 @@ func LedgerGet(_ context.Context, request events.APIGatewayProxyRequest, deps De
 
 	dirPath := ledgerPrefix + ledgerType
 	path := fmt.Sprintf("%s/%s.json", dirPath, month)
-	content, err := deps.Data.Get(path)
+	content, err := deps.Data.Get(ctx, path)
 	var ledger MonthlyLedger
 	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
 		fmt.Printf("Ledger not found: %s - %v\n", path, err)
-		openingBalance, foundPrev := findPreviousClosingBalance(dirPath, month, deps)
+		ledger.PK = fmt.Sprintf("LEDGER#%s#%s", ledgerType, month)
+		ledger.Month = month
+		ledger.Type = ledgerType
+		openingBalance, foundPrev := findPreviousClosingBalance(ctx, dirPath, month, deps)
 		if foundPrev {
 			ledger.OpeningBalance = openingBalance
 			ledger.ClosingBalance = openingBalance
@@ -103,13 +167,14 @@ func LedgerGet(_ context.Context, request events.APIGatewayProxyRequest, deps De
 		fmt.Printf("Invalid ledger format: %s - Error: %v\n", path, err)
 		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid ledger format"}`, StatusCode: 400, Headers: deps.Headers}, nil
 	}
+	signAttachments(&ledger, deps.SigningSecret, time.Now())
 
 	body, _ := json.Marshal(ledger)
 	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
 
 }
 
-func findPreviousClosingBalance(dirPath, month string, deps Dependencies) (float64, bool) {
+func findPreviousClosingBalance(ctx context.Context, dirPath, month string, deps Dependencies) (money.Cents, bool) {
 	parsedMonth, err := time.Parse("2006-01", month)
 	if err != nil {
 		fmt.Printf("Invalid month: %s - Error: %v\n", month, err)
@@ -119,9 +184,9 @@ func findPreviousClosingBalance(dirPath, month string, deps Dependencies) (float
 		parsedMonth = parsedMonth.AddDate(0, -1, 0)
 		prevMonth := parsedMonth.Format("2006-01")
 		path := fmt.Sprintf("%s/%s.json", dirPath, prevMonth)
-		content, err := deps.Data.Get(path)
+		content, err := deps.Data.Get(ctx, path)
 		if err != nil {
-			if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "no such file") {
+			if errors.Is(err, storage.ErrNotFound) {
 				continue
 			}
 			fmt.Printf("Failed to read ledger: %s - Error: %v\n", path, err)
@@ -137,36 +202,103 @@ func findPreviousClosingBalance(dirPath, month string, deps Dependencies) (float
 	return 0, false
 }
 
-func LedgerPost(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func LedgerPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	ledgerType := request.QueryStringParameters["type"]
 	if ledgerType == "" {
 		fmt.Printf("Missing ledger type\n")
 		return events.APIGatewayProxyResponse{Body: `{"error": "Type is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
 	}
-	dirPath := ledgerPrefix + ledgerType
 
 	var ledgers []MonthlyLedger
 	if err := json.Unmarshal([]byte(request.Body), &ledgers); err != nil {
 		fmt.Printf("Invalid ledger post format - Error: %v\n", err)
 		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid format"}`, StatusCode: 400, Headers: deps.Headers}, nil
 	}
+	stripAttachmentLinks(ledgers)
+	if violations := validateLedgers(ledgerType, ledgers); len(violations) > 0 {
+		return validationErrorResponse(violations, deps.Headers), nil
+	}
+	funds, _, err := loadTrustFunds(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if violations := validateTrustFundTags(ledgers, funds); len(violations) > 0 {
+		return validationErrorResponse(violations, deps.Headers), nil
+	}
+
+	stored, err := loadLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	result, err := saveLedgers(ctx, deps, ledgerType, ledgers, true)
+	if err != nil {
+		return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
+	}
 
-	for _, ledger := range ledgers {
-		path := fmt.Sprintf("%s/%s.json", dirPath, ledger.Month)
-		content, _ := json.Marshal(ledger)
-		if err := deps.Data.Save(path, content); err != nil {
-			return errorResponse(err, deps.Headers), nil
+	// Deleting one side of a transfer deletes the other
+	response := ledgerPostResponse{Status: "ok", Months: result.Written, CascadedMonths: result.Cascaded, JournalStale: result.JournalStale}
+	for _, transferID := range removedTransferIDs(stored, ledgers) {
+		removed, journalStale, err := removeTransferTransactions(ctx, deps, transferID, ledgerType)
+		response.RemovedTransfers = append(response.RemovedTransfers, removed...)
+		response.JournalStale = response.JournalStale || journalStale
+		if err != nil {
+			fmt.Printf("Transfer %s is left one-sided: %v\n", transferID, err)
+			return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
+		}
+	}
+	// Deleting a claim's payment leaves the claim to be paid again
+	for _, claimID := range removedLinkIDs(stored, ledgers, func(tx Transaction) string { return tx.ReimbursementID }) {
+		if err := reopenReimbursement(ctx, deps, claimID); err != nil {
+			fmt.Printf("Reimbursement %s is left paid without its payment: %v\n", claimID, err)
+			return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
 		}
+		response.ReopenedReimbursements = append(response.ReopenedReimbursements, claimID)
 	}
-	return events.APIGatewayProxyResponse{Body: `{"status":"ok"}`, StatusCode: 200, Headers: deps.Headers}, nil
+	body, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// ledgerSaveErrorResponse reports a failed multi-month write together with the months that were already saved,
+// so callers can detect a partially applied ledger update
+func ledgerSaveErrorResponse(err error, saved []string, headers map[string]string) events.APIGatewayProxyResponse {
+	var response events.APIGatewayProxyResponse
+	var locked *ledgerLockedError
+	if errors.As(err, &locked) {
+		fmt.Printf("Locked: %v\n", err)
+		response = events.APIGatewayProxyResponse{StatusCode: 409, Headers: headers}
+	} else {
+		response = storageErrorResponse(err, headers)
+	}
+	fmt.Printf("Ledger write stopped after %d month(s): %v\n", len(saved), saved)
+	errorBody := ledgerSaveErrorBody{Error: err.Error(), SavedMonths: saved}
+	var conflict *ledgerConflictError
+	if errors.As(err, &conflict) {
+		errorBody.ConflictMonths = conflict.Months
+	}
+	if locked != nil {
+		errorBody.LockedMonths = locked.Months
+	}
+	body, _ := json.Marshal(errorBody)
+	response.Body = string(body)
+	return response
 }
 
-func LedgerBankImport(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func LedgerBankImport(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	ledgerType := strings.TrimSpace(request.QueryStringParameters["type"])
+	format := strings.TrimSpace(request.QueryStringParameters["format"])
+	mode := strings.TrimSpace(request.QueryStringParameters["mode"])
+	dryRun := false
+	if dryRunRaw := strings.TrimSpace(request.QueryStringParameters["dryRun"]); dryRunRaw != "" {
+		parsed, err := strconv.ParseBool(dryRunRaw)
+		if err != nil {
+			return events.APIGatewayProxyResponse{Body: `{"error": "dryRun must be true or false"}`, StatusCode: 400, Headers: deps.Headers}, nil
+		}
+		dryRun = parsed
+	}
 	currentBalanceRaw := strings.TrimSpace(request.QueryStringParameters["currentBalance"])
-	varCurrentBalance := (*float64)(nil)
+	varCurrentBalance := (*money.Cents)(nil)
 	if currentBalanceRaw != "" {
-		parsed, err := strconv.ParseFloat(currentBalanceRaw, 64)
+		parsed, err := money.Parse(normalizeAmountString(currentBalanceRaw))
 		if err != nil {
 			return events.APIGatewayProxyResponse{Body: `{"error": "Current balance must be a number"}`, StatusCode: 400, Headers: deps.Headers}, nil
 		}
@@ -185,6 +317,13 @@ func LedgerBankImport(_ context.Context, request events.APIGatewayProxyRequest,
 		if varCurrentBalance == nil && body.CurrentBalance != nil {
 			varCurrentBalance = body.CurrentBalance
 		}
+		if format == "" {
+			format = strings.TrimSpace(body.Format)
+		}
+		if mode == "" {
+			mode = strings.TrimSpace(body.Mode)
+		}
+		dryRun = dryRun || body.DryRun
 	}
 
 	if ledgerType == "" {
@@ -192,31 +331,82 @@ func LedgerBankImport(_ context.Context, request events.APIGatewayProxyRequest,
 	}
 	ledgerType = strings.ToUpper(ledgerType)
 
-	if varCurrentBalance == nil {
-		return events.APIGatewayProxyResponse{Body: `{"error": "Current balance is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
-	}
 	if csvData == "" {
 		return events.APIGatewayProxyResponse{Body: `{"error": "CSV content is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
 	}
+	mode = strings.ToLower(mode)
+	if mode == "" {
+		mode = bankImportReplace
+	}
+	if mode != bankImportReplace && mode != bankImportMerge {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Mode must be replace or merge"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+
+	statement, format, err := parseBankImportStatement(csvData, format)
+	if len(statement.Skipped) > 0 {
+		fmt.Printf("Skipped %d unparsable %s import row(s)\n", len(statement.Skipped), format)
+	}
+	if err != nil {
+		fmt.Printf("Bank import parse failed: %v\n", err)
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	rows, skipped := statement.Rows, statement.Skipped
+	if statement.Balance != nil {
+		// The statement's own balance is authoritative over one typed in by hand
+		varCurrentBalance = statement.Balance
+	}
+	if varCurrentBalance == nil {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Current balance is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
 
-	rows, err := parseBankImportRows(csvData)
+	rules, err := loadCategoryRuleSet(ctx, deps)
 	if err != nil {
-		return events.APIGatewayProxyResponse{Body: fmt.Sprintf(`{"error": "%s"}`, err.Error()), StatusCode: 400, Headers: deps.Headers}, nil
+		return storageErrorResponse(err, deps.Headers), nil
 	}
-	ledgers, months, openingBalance, closingBalance, err := buildBankImportLedgers(rows, ledgerType, *varCurrentBalance)
+	ledgers, months, openingBalance, closingBalance, err := buildBankImportLedgers(rows, ledgerType, *varCurrentBalance, rules)
 	if err != nil {
 		return errorResponse(err, deps.Headers), nil
 	}
-
-	dirPath := ledgerPrefix + ledgerType
+	generated := make([]MonthlyLedger, 0, len(months))
 	for _, month := range months {
-		ledger := ledgers[month]
-		path := fmt.Sprintf("%s/%s.json", dirPath, month)
-		content, _ := json.Marshal(ledger)
-		if err := deps.Data.Save(path, content); err != nil {
-			return errorResponse(err, deps.Headers), nil
+		generated = append(generated, ledgers[month])
+	}
+	stored, err := loadLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if mode == bankImportReplace {
+		if violations := keepBankImportLinks(generated, stored); len(violations) > 0 {
+			return validationErrorResponse(violations, deps.Headers), nil
 		}
 	}
+	suggestions := []categorySuggestion{}
+	if hasUncategorisedTransactions(generated) {
+		ledgersByType, err := loadLedgerData(ctx, deps)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		suggestions = applyCategorySuggestions(generated, newCategoryHistory(ledgersByType))
+	}
+
+	merge := bankImportMergeResult{New: len(rows), Conflicts: []bankImportConflict{}}
+	if mode == bankImportMerge {
+		generated, merge = mergeBankImportLedgers(generated, stored)
+		appended := []categorySuggestion{}
+		for _, suggestion := range suggestions {
+			if merge.added[suggestion.TransactionID] {
+				appended = append(appended, suggestion)
+			}
+		}
+		suggestions = appended
+		openingBalance = generated[0].OpeningBalance
+		closingBalance = generated[len(generated)-1].ClosingBalance
+		fmt.Printf("Merged %s import: %d matched, %d new, %d conflicting\n", ledgerType, merge.Matched, merge.New, merge.Conflicting)
+	}
+	if violations := validateLedgers(ledgerType, generated); len(violations) > 0 {
+		return validationErrorResponse(violations, deps.Headers), nil
+	}
 
 	response := bankImportResponse{
 		Status:         "ok",
@@ -226,12 +416,50 @@ func LedgerBankImport(_ context.Context, request events.APIGatewayProxyRequest,
 		Transactions:   len(rows),
 		OpeningBalance: openingBalance,
 		ClosingBalance: closingBalance,
+		Format:         format,
+		SkippedRows:    skipped,
+		Mode:           mode,
+		Matched:        merge.Matched,
+		New:            merge.New,
+		Conflicting:    merge.Conflicting,
+		Conflicts:      merge.Conflicts,
+		DryRun:         dryRun,
+		Suggestions:    suggestions,
+	}
+	if dryRun {
+		planned, err := planLedgerSave(stored, generated, mode == bankImportMerge)
+		if err != nil {
+			return ledgerSaveErrorResponse(err, []string{}, deps.Headers), nil
+		}
+		locks, err := loadLedgerLocks(ctx, deps, ledgerType)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		response.Status = "preview"
+		response.CascadedMonths = []string{}
+		response.Preview = previewLedgerSave(stored, planned)
+		if blocked := lockedLedgerWrites(locks, planned); blocked != nil {
+			response.LockedMonths = blocked.Months
+		}
+		for _, write := range planned {
+			if write.RolledForward {
+				response.CascadedMonths = append(response.CascadedMonths, write.Ledger.Month)
+			}
+		}
+		fmt.Printf("Previewed %s import of %d transaction(s) across %d month(s)\n", ledgerType, len(rows), len(months))
+	} else {
+		result, err := saveLedgers(ctx, deps, ledgerType, generated, mode == bankImportMerge)
+		if err != nil {
+			return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
+		}
+		response.CascadedMonths = result.Cascaded
+		response.JournalStale = result.JournalStale
 	}
 	bodyBytes, _ := json.Marshal(response)
 	return events.APIGatewayProxyResponse{Body: string(bodyBytes), StatusCode: 200, Headers: deps.Headers}, nil
 }
 
-func LedgerPdf(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+func LedgerPdf(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	ledgerType := request.QueryStringParameters["type"]
 	if ledgerType == "" {
 		fmt.Printf("Missing ledger type\n")
@@ -249,13 +477,13 @@ func LedgerPdf(_ context.Context, request events.APIGatewayProxyRequest, deps De
 
 	dirPath := ledgerPrefix + ledgerType
 	path := fmt.Sprintf("%s/%s.json", dirPath, month)
-	content, err := deps.Data.Get(path)
+	content, err := deps.Data.Get(ctx, path)
 	if err != nil {
-		if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "no such file") {
+		if errors.Is(err, storage.ErrNotFound) {
 			fmt.Printf("Ledger not found: %s\n", path)
 			return events.APIGatewayProxyResponse{Body: `{"error": "Ledger not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
 		}
-		return errorResponse(err, deps.Headers), nil
+		return storageErrorResponse(err, deps.Headers), nil
 	}
 	var ledger MonthlyLedger
 	if err := json.Unmarshal(content, &ledger); err != nil {
@@ -263,7 +491,7 @@ func LedgerPdf(_ context.Context, request events.APIGatewayProxyRequest, deps De
 		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid ledger format"}`, StatusCode: 400, Headers: deps.Headers}, nil
 	}
 
-	openingBalance, foundPrev := findPreviousClosingBalance(dirPath, month, deps)
+	openingBalance, foundPrev := findPreviousClosingBalance(ctx, dirPath, month, deps)
 	if foundPrev {
 		ledger.OpeningBalance = openingBalance
 	}
@@ -275,7 +503,7 @@ func LedgerPdf(_ context.Context, request events.APIGatewayProxyRequest, deps De
 	copy(rows, transactions)
 	ledgerBalance := ledger.OpeningBalance
 	for i, tx := range rows {
-		ledgerBalance = roundCurrency(ledgerBalance + tx.Amount)
+		ledgerBalance += tx.Amount
 		rows[i].RunningBalance = ledgerBalance
 	}
 
@@ -299,109 +527,104 @@ func LedgerPdf(_ context.Context, request events.APIGatewayProxyRequest, deps De
 	}, nil
 }
 
-func LedgerCategoriesGet(_ context.Context, _ events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
-	path := "categories.json"
-	content, err := deps.Data.Get(path)
-	if err != nil {
-		if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "no such file") {
-			defaultCats := `["Membership", "Event Fee", "Equipment", "Reimbursement", "Sponsorship", "Misc"]`
-			return events.APIGatewayProxyResponse{Body: defaultCats, StatusCode: 200, Headers: deps.Headers}, nil
-		}
-		return errorResponse(err, deps.Headers), nil
-	}
-	return events.APIGatewayProxyResponse{Body: string(content), StatusCode: 200, Headers: deps.Headers}, nil
-}
-
-func LedgerCategoriesPost(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
-	path := "categories.json"
-	err := deps.Data.Save(path, []byte(request.Body))
-	if err != nil {
-		return errorResponse(err, deps.Headers), nil
-	}
-	return events.APIGatewayProxyResponse{Body: `{"status":"ok"}`, StatusCode: 200, Headers: deps.Headers}, nil
-}
-
-func parseBankImportRows(content string) ([]bankImportRow, error) {
+func parseBankImportRows(content string, profile bankImportProfile) ([]bankImportRow, []bankImportSkippedRow, error) {
 	reader := csv.NewReader(strings.NewReader(content))
 	reader.Comma = detectBankImportDelimiter(content)
 	reader.FieldsPerRecord = -1
 	reader.LazyQuotes = true
 
+	minColumns := profile.requiredColumns()
 	rows := make([]bankImportRow, 0)
+	skipped := make([]bankImportSkippedRow, 0)
 	lineIdx := 0
+	seenData := false
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("invalid csv: %w", err)
+			return nil, nil, fmt.Errorf("invalid csv: %w", err)
 		}
-		if len(record) < 3 {
+		line, _ := reader.FieldPos(0)
+		skip := func(reason string) {
+			skipped = append(skipped, bankImportSkippedRow{Line: line, Reason: reason, Text: strings.Join(record, string(reader.Comma))})
 			lineIdx++
-			continue
 		}
-		dateRaw := strings.TrimSpace(record[0])
-		amountRaw := strings.TrimSpace(record[1])
-		description := strings.TrimSpace(strings.Join(record[2:], " "))
-		if dateRaw == "" && amountRaw == "" && description == "" {
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
 			lineIdx++
 			continue
 		}
-		date, err := time.Parse("02/01/2006", dateRaw)
-		if err != nil {
+		if !seenData && profile.isHeader(record) {
+			seenData = true
 			lineIdx++
 			continue
 		}
-		amount, err := strconv.ParseFloat(normalizeAmountString(amountRaw), 64)
+		seenData = true
+		if len(record) < minColumns {
+			skip(fmt.Sprintf("expected at least %d columns", minColumns))
+			continue
+		}
+
+		dateRaw := strings.TrimSpace(record[profile.DateColumn])
+		date, ok := profile.parseDate(dateRaw)
+		if !ok {
+			skip(fmt.Sprintf("invalid date %q", dateRaw))
+			continue
+		}
+		amount, err := profile.parseAmount(record)
 		if err != nil {
-			lineIdx++
+			skip(err.Error())
 			continue
 		}
+		description := profile.description(record)
 
 		rows = append(rows, bankImportRow{
 			OrigIdx:     lineIdx,
 			Date:        date,
 			Amount:      amount,
 			Description: description,
-			Category:    categorizeBankImport(description),
 		})
 		lineIdx++
 	}
 
 	if len(rows) == 0 {
-		return nil, fmt.Errorf("no transactions found")
+		return nil, skipped, fmt.Errorf("no transactions found")
 	}
 
-	return rows, nil
+	return rows, skipped, nil
 }
 
-func buildBankImportLedgers(rows []bankImportRow, ledgerType string, currentBalance float64) (map[string]MonthlyLedger, []string, float64, float64, error) {
+func buildBankImportLedgers(rows []bankImportRow, ledgerType string, currentBalance money.Cents, rules *categoryRuleSet) (map[string]MonthlyLedger, []string, money.Cents, money.Cents, error) {
 	chrono := make([]bankImportRow, len(rows))
 	copy(chrono, rows)
 	sort.Slice(chrono, func(i, j int) bool {
 		return chrono[i].OrigIdx > chrono[j].OrigIdx
 	})
 
-	totalSum := 0.0
+	var totalSum money.Cents
 	for _, row := range chrono {
 		totalSum += row.Amount
 	}
-	openingBalance := roundCurrency(currentBalance - totalSum)
+	openingBalance := currentBalance - totalSum
 	ledgerBalance := openingBalance
 
 	for i := range chrono {
-		ledgerBalance = roundCurrency(ledgerBalance + chrono[i].Amount)
+		ledgerBalance += chrono[i].Amount
 		chrono[i].RunningBalance = ledgerBalance
 		chrono[i].DateISO = chrono[i].Date.Format("2006-01-02")
 		chrono[i].Month = chrono[i].Date.Format("2006-01")
-		id, err := newUUID()
-		if err != nil {
-			return nil, nil, 0, 0, err
+		if chrono[i].ID == "" {
+			id, err := newUUID()
+			if err != nil {
+				return nil, nil, 0, 0, err
+			}
+			chrono[i].ID = id
 		}
-		chrono[i].ID = id
 		if chrono[i].Category == "" {
-			chrono[i].Category = categorizeBankImport(chrono[i].Description)
+			// Rows no rule matches stay uncategorised for applyCategorySuggestions
+			chrono[i].Category, _, _ = rules.categorize(ledgerType, chrono[i].Description, chrono[i].Amount)
 		}
 	}
 
@@ -426,8 +649,8 @@ func buildBankImportLedgers(rows []bankImportRow, ledgerType string, currentBala
 		})
 
 		first := rows[0]
-		opening := roundCurrency(first.RunningBalance - first.Amount)
-		closing := roundCurrency(rows[len(rows)-1].RunningBalance)
+		opening := first.RunningBalance - first.Amount
+		closing := rows[len(rows)-1].RunningBalance
 		transactions := make([]Transaction, 0, len(rows))
 		for _, row := range rows {
 			transactions = append(transactions, Transaction{
@@ -435,8 +658,8 @@ func buildBankImportLedgers(rows []bankImportRow, ledgerType string, currentBala
 				Date:           row.DateISO,
 				Category:       row.Category,
 				Description:    row.Description,
-				Amount:         roundCurrency(row.Amount),
-				RunningBalance: roundCurrency(row.RunningBalance),
+				Amount:         row.Amount,
+				RunningBalance: row.RunningBalance,
 			})
 		}
 
@@ -450,7 +673,7 @@ func buildBankImportLedgers(rows []bankImportRow, ledgerType string, currentBala
 		}
 	}
 
-	return ledgers, months, openingBalance, roundCurrency(currentBalance), nil
+	return ledgers, months, openingBalance, currentBalance, nil
 }
 
 func detectBankImportDelimiter(content string) rune {
@@ -478,26 +701,6 @@ func normalizeAmountString(value string) string {
 	return strings.TrimSpace(value)
 }
 
-func categorizeBankImport(description string) string {
-	value := strings.ToLower(description)
-	if strings.Contains(value, "tidyhq") || strings.Contains(value, "auscycling") || strings.Contains(value, "life membership") || strings.Contains(value, "membership fee") || strings.Contains(value, "affiliation") {
-		return "Membership"
-	}
-	if strings.Contains(value, "reimburse") || strings.Contains(value, "reimbursement") {
-		return "Reimbursement"
-	}
-	if strings.Contains(value, "lake health group") || strings.Contains(value, "spons") {
-		return "Sponsorship"
-	}
-	if strings.Contains(value, "troph") || strings.Contains(value, "engraving") || strings.Contains(value, "weed killer") || strings.Contains(value, "star outdoor") || strings.Contains(value, "electrical services") || strings.Contains(value, "asr electrical") || strings.Contains(value, "flowers") {
-		return "Equipment"
-	}
-	if strings.Contains(value, "entryboss") || strings.Contains(value, "square") || strings.Contains(value, "race entry") || strings.Contains(value, "entry") || strings.Contains(value, "permits") || strings.Contains(value, "raffle") {
-		return "Event Fee"
-	}
-	return "Misc"
-}
-
 func newUUID() (string, error) {
 	var uuid [16]byte
 	if _, err := rand.Read(uuid[:]); err != nil {
@@ -508,14 +711,14 @@ func newUUID() (string, error) {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
 }
 
-func buildLedgerPdf(ledgerType, month string, openingBalance, closingBalance float64, transactions []Transaction) ([]byte, error) {
+func buildLedgerPdf(ledgerType, month string, openingBalance, closingBalance money.Cents, transactions []Transaction) ([]byte, error) {
 	pdf := fpdf.New("P", "mm", "A4", "")
 	pdf.SetMargins(10, 10, 10)
 	pdf.AddPage()
 	pdf.SetFont("Helvetica", "B", 16)
 	pdf.CellFormat(0, 10, fmt.Sprintf("Ledger %s - %s", ledgerType, month), "", 1, "L", false, 0, "")
 	pdf.SetFont("Helvetica", "", 12)
-	pdf.CellFormat(0, 8, fmt.Sprintf("Opening Balance: $%.2f", openingBalance), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 8, "Opening Balance: "+openingBalance.Format(), "", 1, "L", false, 0, "")
 
 	columns := []struct {
 		label string
@@ -542,9 +745,9 @@ func buildLedgerPdf(ledgerType, month string, openingBalance, closingBalance flo
 		debit := ""
 		credit := ""
 		if tx.Amount < 0 {
-			debit = fmt.Sprintf("%.2f", -tx.Amount)
+			debit = tx.Amount.Abs().String()
 		} else if tx.Amount > 0 {
-			credit = fmt.Sprintf("%.2f", tx.Amount)
+			credit = tx.Amount.String()
 		}
 
 		cells := []string{
@@ -553,7 +756,7 @@ func buildLedgerPdf(ledgerType, month string, openingBalance, closingBalance flo
 			tx.Description,
 			debit,
 			credit,
-			fmt.Sprintf("%.2f", tx.RunningBalance),
+			tx.RunningBalance.String(),
 		}
 		for i, column := range columns {
 			pdf.CellFormat(column.width, 6, cells[i], "1", 0, column.align, false, 0, "")
@@ -563,7 +766,7 @@ func buildLedgerPdf(ledgerType, month string, openingBalance, closingBalance flo
 
 	pdf.Ln(4)
 	pdf.SetFont("Helvetica", "B", 12)
-	pdf.CellFormat(0, 8, fmt.Sprintf("Closing Balance: $%.2f", closingBalance), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 8, "Closing Balance: "+closingBalance.Format(), "", 1, "L", false, 0, "")
 
 	var buffer bytes.Buffer
 	if err := pdf.Output(&buffer); err != nil {
@@ -571,7 +774,3 @@ func buildLedgerPdf(ledgerType, month string, openingBalance, closingBalance flo
 	}
 	return buffer.Bytes(), nil
 }
-
-func roundCurrency(value float64) float64 {
-	return math.Round(value*100) / 100
-}
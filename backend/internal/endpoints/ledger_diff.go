@@ -0,0 +1,126 @@
+package endpoints
+
+import "github.com/eureka-cycling/committee-apps/backend/internal/money"
+
+// transactionChange is a stored transaction and the transaction that would replace it
+type transactionChange struct {
+	Before Transaction `json:"before"`
+	After  Transaction `json:"after"`
+}
+
+// ledgerMonthDiff describes how a proposed month differs from the stored one
+type ledgerMonthDiff struct {
+	Month string `json:"month"`
+	// Exists is false when the month has not been stored before
+	Exists              bool                `json:"exists"`
+	RolledForward       bool                `json:"rolledForward"`
+	Proposed            MonthlyLedger       `json:"proposed"`
+	Added               []Transaction       `json:"added"`
+	Changed             []transactionChange `json:"changed"`
+	Removed             []Transaction       `json:"removed"`
+	OpeningBalanceDelta money.Cents         `json:"openingBalanceDelta"`
+	ClosingBalanceDelta money.Cents         `json:"closingBalanceDelta"`
+}
+
+// diffLedgerMonth compares a proposed month with the stored month, which is nil when there is
+// none. Transactions are paired by ID and then by date, amount and description, so a statement
+// row that replaces a stored transaction under a new ID shows as a change rather than a removal
+// and an addition. Running balances are ignored; the balance deltas cover them.
+func diffLedgerMonth(stored *MonthlyLedger, proposed MonthlyLedger) ledgerMonthDiff {
+	diff := ledgerMonthDiff{
+		Month:               proposed.Month,
+		Proposed:            proposed,
+		Added:               []Transaction{},
+		Changed:             []transactionChange{},
+		Removed:             []Transaction{},
+		OpeningBalanceDelta: proposed.OpeningBalance,
+		ClosingBalanceDelta: proposed.ClosingBalance,
+	}
+	if stored == nil {
+		diff.Added = append(diff.Added, proposed.Transactions...)
+		return diff
+	}
+	diff.Exists = true
+	diff.OpeningBalanceDelta = proposed.OpeningBalance - stored.OpeningBalance
+	diff.ClosingBalanceDelta = proposed.ClosingBalance - stored.ClosingBalance
+
+	paired := make(map[int]int, len(proposed.Transactions))
+	used := make(map[int]bool, len(stored.Transactions))
+	byID := make(map[string]int, len(stored.Transactions))
+	for j, tx := range stored.Transactions {
+		byID[tx.ID] = j
+	}
+	for i, tx := range proposed.Transactions {
+		if j, ok := byID[tx.ID]; ok && !used[j] {
+			paired[i] = j
+			used[j] = true
+		}
+	}
+	byFingerprint := make(map[string][]int)
+	for j, tx := range stored.Transactions {
+		if !used[j] {
+			key := bankImportFingerprint(tx.Date, tx.Amount, tx.Description)
+			byFingerprint[key] = append(byFingerprint[key], j)
+		}
+	}
+	for i, tx := range proposed.Transactions {
+		if _, ok := paired[i]; ok {
+			continue
+		}
+		key := bankImportFingerprint(tx.Date, tx.Amount, tx.Description)
+		if candidates := byFingerprint[key]; len(candidates) > 0 {
+			byFingerprint[key] = candidates[1:]
+			paired[i] = candidates[0]
+			used[candidates[0]] = true
+		}
+	}
+
+	for i, tx := range proposed.Transactions {
+		j, ok := paired[i]
+		if !ok {
+			diff.Added = append(diff.Added, tx)
+			continue
+		}
+		before := stored.Transactions[j]
+		if before.ID != tx.ID || before.Date != tx.Date || before.Category != tx.Category ||
+			before.Description != tx.Description || before.Amount != tx.Amount ||
+			before.TransferID != tx.TransferID || before.ReimbursementID != tx.ReimbursementID ||
+			before.TrustFundID != tx.TrustFundID || !sameAttachments(before, tx) {
+			diff.Changed = append(diff.Changed, transactionChange{Before: before, After: tx})
+		}
+	}
+	for j, tx := range stored.Transactions {
+		if !used[j] {
+			diff.Removed = append(diff.Removed, tx)
+		}
+	}
+	return diff
+}
+
+// previewLedgerSave diffs each planned write against the stored month it would replace
+func previewLedgerSave(stored []MonthlyLedger, planned []plannedLedgerWrite) []ledgerMonthDiff {
+	storedByMonth := make(map[string]*MonthlyLedger, len(stored))
+	for i := range stored {
+		storedByMonth[stored[i].Month] = &stored[i]
+	}
+	diffs := make([]ledgerMonthDiff, 0, len(planned))
+	for _, write := range planned {
+		diff := diffLedgerMonth(storedByMonth[write.Ledger.Month], write.Ledger)
+		diff.RolledForward = write.RolledForward
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// sameAttachments reports whether two transactions reference the same documents in the same order
+func sameAttachments(a, b Transaction) bool {
+	if len(a.Attachments) != len(b.Attachments) {
+		return false
+	}
+	for i := range a.Attachments {
+		if a.Attachments[i].Path != b.Attachments[i].Path {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDiffLedgerMonth(t *testing.T) {
+	stored := MonthlyLedger{
+		Month:          "2025-03",
+		OpeningBalance: 1000,
+		ClosingBalance: 500,
+		Transactions: []Transaction{
+			{ID: "a", Date: "2025-03-01", Category: "Misc", Description: "Permits", Amount: -300},
+			{ID: "b", Date: "2025-03-02", Category: "Equipment", Description: "Cones", Amount: -200},
+			{ID: "c", Date: "2025-03-03", Category: "Misc", Description: "Raffle", Amount: 0},
+		},
+	}
+	proposed := MonthlyLedger{
+		Month:          "2025-03",
+		OpeningBalance: 1200,
+		ClosingBalance: 800,
+		Transactions: []Transaction{
+			{ID: "a", Date: "2025-03-01", Category: "Event Fee", Description: "Permits", Amount: -300},
+			{ID: "new-b", Date: "2025-03-02", Category: "Equipment", Description: "Cones", Amount: -200},
+			{ID: "d", Date: "2025-03-04", Category: "Misc", Description: "Donation", Amount: 100},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		stored *MonthlyLedger
+		want   ledgerMonthDiff
+	}{
+		{
+			name:   "New month",
+			stored: nil,
+			want: ledgerMonthDiff{
+				Month:               "2025-03",
+				Proposed:            proposed,
+				Added:               proposed.Transactions,
+				Changed:             []transactionChange{},
+				Removed:             []Transaction{},
+				OpeningBalanceDelta: 1200,
+				ClosingBalanceDelta: 800,
+			},
+		},
+		{
+			name:   "Existing month",
+			stored: &stored,
+			want: ledgerMonthDiff{
+				Month:    "2025-03",
+				Exists:   true,
+				Proposed: proposed,
+				Added:    []Transaction{proposed.Transactions[2]},
+				Changed: []transactionChange{
+					{Before: stored.Transactions[0], After: proposed.Transactions[0]},
+					{Before: stored.Transactions[1], After: proposed.Transactions[1]},
+				},
+				Removed:             []Transaction{stored.Transactions[2]},
+				OpeningBalanceDelta: 200,
+				ClosingBalanceDelta: 300,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLedgerMonth(tt.stored, proposed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLedgerMonth() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLedgerMonth_Links(t *testing.T) {
+	stored := MonthlyLedger{Month: "2025-03", Transactions: []Transaction{
+		{ID: "a", Date: "2025-03-01", Category: "Transfer", Description: "To cash", Amount: -300, TransferID: "t1"},
+		{ID: "b", Date: "2025-03-02", Category: "Equipment", Description: "Cones", Amount: -200, ReimbursementID: "r1"},
+	}}
+	// The same rows without their transfer and reimbursement links
+	proposed := MonthlyLedger{Month: "2025-03", Transactions: []Transaction{
+		{ID: "a", Date: "2025-03-01", Category: "Transfer", Description: "To cash", Amount: -300},
+		{ID: "b", Date: "2025-03-02", Category: "Equipment", Description: "Cones", Amount: -200},
+	}}
+	got := diffLedgerMonth(&stored, proposed)
+	want := []transactionChange{
+		{Before: stored.Transactions[0], After: proposed.Transactions[0]},
+		{Before: stored.Transactions[1], After: proposed.Transactions[1]},
+	}
+	if !reflect.DeepEqual(got.Changed, want) {
+		t.Errorf("diffLedgerMonth().Changed = %+v, want %+v", got.Changed, want)
+	}
+}
+
+func TestLedgerBankImport_DryRun(t *testing.T) {
+	march := MonthlyLedger{
+		PK:             "LEDGER#BANK#2025-03",
+		Month:          "2025-03",
+		Type:           "BANK",
+		OpeningBalance: 10000,
+		ClosingBalance: 8000,
+		Version:        2,
+		Transactions: []Transaction{
+			{ID: "manual", Date: "2025-03-05", Category: "Misc", Description: "Manual entry", Amount: -2000, RunningBalance: 8000},
+		},
+	}
+	april := MonthlyLedger{
+		PK:             "LEDGER#BANK#2025-04",
+		Month:          "2025-04",
+		Type:           "BANK",
+		OpeningBalance: 8000,
+		ClosingBalance: 8000,
+		Version:        1,
+		Transactions:   []Transaction{},
+	}
+	prov := newTestDataProvider(t, march, april)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	before, _ := prov.Get(context.Background(), "ledger/BANK/2025-03.json")
+
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"type": "BANK", "currentBalance": "85.00", "dryRun": "true"},
+		Body:                  "07/03/2025,-15.00,Permits\n",
+	}
+	got, err := LedgerBankImport(context.Background(), request, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", got.StatusCode, got.Body)
+	}
+	var response bankImportResponse
+	if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != "preview" || !response.DryRun || len(response.Preview) != 2 {
+		t.Fatalf("response = %+v", response)
+	}
+	if !reflect.DeepEqual(response.CascadedMonths, []string{"2025-04"}) {
+		t.Errorf("cascaded months = %v", response.CascadedMonths)
+	}
+	marchDiff, aprilDiff := response.Preview[0], response.Preview[1]
+	if len(marchDiff.Added) != 1 || len(marchDiff.Removed) != 1 || marchDiff.Removed[0].ID != "manual" {
+		t.Errorf("march diff = %+v", marchDiff)
+	}
+	if marchDiff.OpeningBalanceDelta != 0 || marchDiff.ClosingBalanceDelta != 500 {
+		t.Errorf("march deltas = %s, %s", marchDiff.OpeningBalanceDelta, marchDiff.ClosingBalanceDelta)
+	}
+	if !aprilDiff.RolledForward || aprilDiff.OpeningBalanceDelta != 500 || aprilDiff.ClosingBalanceDelta != 500 {
+		t.Errorf("april diff = %+v", aprilDiff)
+	}
+
+	after, _ := prov.Get(context.Background(), "ledger/BANK/2025-03.json")
+	if string(after) != string(before) {
+		t.Errorf("dry run changed the stored ledger")
+	}
+
+	// The preview names the locked months the import itself would be refused for
+	got, err = PeriodLockPost(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"month": "2025-04"}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("PeriodLockPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	got, err = LedgerBankImport(context.Background(), request, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("locked dry run = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	response = bankImportResponse{}
+	if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(response.LockedMonths, []string{"2025-04"}) {
+		t.Errorf("locked months = %v", response.LockedMonths)
+	}
+}
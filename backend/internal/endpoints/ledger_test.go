@@ -3,25 +3,58 @@ package endpoints
 import (
 	"context"
 	"encoding/json"
-	"os"
 	"reflect"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
 	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
 )
 
-func TestLedgerGet(t *testing.T) {
-	// Skip in CI
-	if os.Getenv("CI") != "" {
-		t.Skip("skipping test in CI")
+func newTestDataProvider(t *testing.T, ledgers ...MonthlyLedger) storage.StorageProvider {
+	t.Helper()
+	prov, err := storage.NewLocalStorageProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ledger := range ledgers {
+		content, _ := json.Marshal(ledger)
+		if err := prov.Save(context.Background(), ledgerPrefix+ledger.Type+"/"+ledger.Month+".json", content); err != nil {
+			t.Fatal(err)
+		}
 	}
+	return prov
+}
 
-	bucketName := "committeeappsbackendprod-databuckete3889a50-hnlnorx7vzql"
-	prov, err := storage.NewS3StorageProvider(context.Background(), bucketName)
+func mustJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	body, err := json.Marshal(value)
 	if err != nil {
 		t.Fatal(err)
 	}
+	return string(body)
+}
+
+// testLedger is a month of the ledger with its running balances filled in
+func testLedger(ledgerType, month string, opening money.Cents, transactions ...Transaction) MonthlyLedger {
+	ledger := MonthlyLedger{PK: "LEDGER#" + ledgerType + "#" + month, Month: month, Type: ledgerType, OpeningBalance: opening, Transactions: transactions}
+	recalculateLedger(&ledger)
+	return ledger
+}
+
+func TestLedgerGet(t *testing.T) {
+	december := MonthlyLedger{
+		PK:             "LEDGER#CASH#2024-12",
+		Month:          "2024-12",
+		Type:           "CASH",
+		OpeningBalance: 10000,
+		ClosingBalance: 15050,
+		Transactions: []Transaction{
+			{ID: "tx-1", Date: "2024-12-03", Category: "Membership", Description: "Dues", Amount: 5050, RunningBalance: 15050},
+		},
+	}
+	prov := newTestDataProvider(t, december)
+
 	type args struct {
 		in0     context.Context
 		request events.APIGatewayProxyRequest
@@ -45,24 +78,27 @@ func TestLedgerGet(t *testing.T) {
 				},
 			},
 			wantErr: false,
-			want:    events.APIGatewayProxyResponse{},
+			want:    events.APIGatewayProxyResponse{Body: mustJSON(t, december), StatusCode: 200},
 		},
 		{
-			name: "Get 2025-11",
+			name: "Get 2025-01 carries forward the previous closing balance",
 			args: args{
 				in0: context.Background(),
 				request: events.APIGatewayProxyRequest{
-					QueryStringParameters: map[string]string{"month": "2025-11", "type": "CASH"},
+					QueryStringParameters: map[string]string{"month": "2025-01", "type": "CASH"},
 				},
 				deps: Dependencies{
 					Data: prov,
 				},
 			},
 			wantErr: false,
-			want:    events.APIGatewayProxyResponse{},
+			want: events.APIGatewayProxyResponse{
+				Body:       mustJSON(t, MonthlyLedger{PK: "LEDGER#CASH#2025-01", Month: "2025-01", Type: "CASH", OpeningBalance: 15050, ClosingBalance: 15050}),
+				StatusCode: 200,
+			},
 		},
 		{
-			name: "Get 2025-12",
+			name: "Get 2025-12 without recent history",
 			args: args{
 				in0: context.Background(),
 				request: events.APIGatewayProxyRequest{
@@ -73,7 +109,21 @@ func TestLedgerGet(t *testing.T) {
 				},
 			},
 			wantErr: false,
-			want:    events.APIGatewayProxyResponse{},
+			want:    events.APIGatewayProxyResponse{Body: mustJSON(t, MonthlyLedger{PK: "LEDGER#CASH#2025-12", Month: "2025-12", Type: "CASH"}), StatusCode: 200},
+		},
+		{
+			name: "Invalid month",
+			args: args{
+				in0: context.Background(),
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"month": "2025-13", "type": "CASH"},
+				},
+				deps: Dependencies{
+					Data: prov,
+				},
+			},
+			wantErr: false,
+			want:    events.APIGatewayProxyResponse{Body: `{"error": "Month must be YYYY-MM"}`, StatusCode: 400},
 		},
 	}
 	for _, tt := range tests {
@@ -92,3 +142,66 @@ func TestLedgerGet(t *testing.T) {
 		})
 	}
 }
+
+func TestLedgerPdf_NotFound(t *testing.T) {
+	prov := newTestDataProvider(t)
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"month": "2025-01", "type": "BANK"},
+	}
+	got, err := LedgerPdf(context.Background(), request, Dependencies{Data: prov})
+	if err != nil {
+		t.Fatalf("LedgerPdf() error = %v", err)
+	}
+	if got.StatusCode != 404 {
+		t.Errorf("LedgerPdf() status = %d, want 404", got.StatusCode)
+	}
+}
+
+func TestLedgerPost_CancelledContext(t *testing.T) {
+	prov := newTestDataProvider(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"type": "BANK"},
+		Body:                  `[{"month":"2025-01","type":"BANK"}]`,
+	}
+	got, err := LedgerPost(ctx, request, Dependencies{Data: prov})
+	if err != nil {
+		t.Fatalf("LedgerPost() error = %v", err)
+	}
+	if got.StatusCode != 504 {
+		t.Errorf("LedgerPost() status = %d, want 504", got.StatusCode)
+	}
+	var body ledgerSaveErrorBody
+	if err := json.Unmarshal([]byte(got.Body), &body); err != nil {
+		t.Fatalf("LedgerPost() body = %s, error = %v", got.Body, err)
+	}
+	if len(body.SavedMonths) != 0 {
+		t.Errorf("LedgerPost() savedMonths = %v, want none", body.SavedMonths)
+	}
+}
+
+func TestLedgerGet_LegacyFloatAmounts(t *testing.T) {
+	prov := newTestDataProvider(t)
+	legacy := `{"pk":"LEDGER#BANK#2025-01","month":"2025-01","type":"BANK","openingBalance":10.1,"closingBalance":10.3,` +
+		`"transactions":[{"id":"tx-1","date":"2025-01-02","category":"Misc","description":"Float","amount":0.2,"runningBalance":10.3}]}`
+	if err := prov.Save(context.Background(), "ledger/BANK/2025-01.json", []byte(legacy)); err != nil {
+		t.Fatal(err)
+	}
+
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"month": "2025-01", "type": "BANK"},
+	}
+	got, err := LedgerGet(context.Background(), request, Dependencies{Data: prov})
+	if err != nil {
+		t.Fatalf("LedgerGet() error = %v", err)
+	}
+	var ledger MonthlyLedger
+	if err := json.Unmarshal([]byte(got.Body), &ledger); err != nil {
+		t.Fatalf("LedgerGet() body = %s, error = %v", got.Body, err)
+	}
+	if ledger.OpeningBalance+ledger.Transactions[0].Amount != ledger.ClosingBalance {
+		t.Errorf("LedgerGet() opening %s + amount %s != closing %s", ledger.OpeningBalance, ledger.Transactions[0].Amount, ledger.ClosingBalance)
+	}
+}
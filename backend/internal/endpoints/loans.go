@@ -0,0 +1,512 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const loansPath = "loans.json"
+
+// maxLoanTermMonths bounds the amortisation schedule at thirty years of monthly instalments
+const maxLoanTermMonths = 360
+
+// Loan is one entry in the loan schedule. Loans are repaid in equal monthly instalments, the
+// first falling a month after StartDate.
+type Loan struct {
+	ID          string      `json:"id"`
+	Lender      string      `json:"lender"`
+	Description string      `json:"description"`
+	Principal   money.Cents `json:"principal"`
+	// AnnualRate is the nominal annual interest rate as a percentage, such as 6.5
+	AnnualRate float64 `json:"annualRate"`
+	TermMonths int     `json:"termMonths"`
+	StartDate  string  `json:"startDate"`
+	// Drawdown is the ledger deposit of the loan money, when the ledgers go back that far
+	Drawdown *LoanDrawdown `json:"drawdown,omitempty"`
+	// Repayments are the ledger transactions that repaid the loan
+	Repayments []LoanRepayment `json:"repayments"`
+}
+
+// LoanDrawdown links the loan money to its ledger deposit, whose date and amount it carries
+type LoanDrawdown struct {
+	LedgerType    string      `json:"ledgerType"`
+	TransactionID string      `json:"transactionId"`
+	Date          string      `json:"date"`
+	Amount        money.Cents `json:"amount"`
+}
+
+// LoanRepayment links a repayment to its ledger transaction, whose date and amount it carries
+type LoanRepayment struct {
+	LedgerType    string      `json:"ledgerType"`
+	TransactionID string      `json:"transactionId"`
+	Date          string      `json:"date"`
+	Amount        money.Cents `json:"amount"`
+}
+
+// LoanInstalment is one row of an amortisation schedule
+type LoanInstalment struct {
+	Number    int         `json:"number"`
+	Date      string      `json:"date"`
+	Payment   money.Cents `json:"payment"`
+	Interest  money.Cents `json:"interest"`
+	Principal money.Cents `json:"principal"`
+	Balance   money.Cents `json:"balance"`
+}
+
+// loanView is a loan with its outstanding balance split as at a date
+type loanView struct {
+	Loan
+	Repaid      money.Cents `json:"repaid"`
+	Outstanding money.Cents `json:"outstanding"`
+	Current     money.Cents `json:"current"`
+	NonCurrent  money.Cents `json:"nonCurrent"`
+}
+
+type loanScheduleResponse struct {
+	Loan     loanView         `json:"loan"`
+	Schedule []LoanInstalment `json:"schedule"`
+}
+
+func loadLoans(ctx context.Context, deps Dependencies) ([]Loan, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, loansPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return []Loan{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var loans []Loan
+	if err := json.Unmarshal(content, &loans); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %w", loansPath, err)
+	}
+	return loans, etag, nil
+}
+
+func saveLoans(ctx context.Context, deps Dependencies, loans []Loan, etag string) error {
+	sort.SliceStable(loans, func(i, j int) bool {
+		return loans[i].StartDate+loans[i].Lender < loans[j].StartDate+loans[j].Lender
+	})
+	content, _ := json.Marshal(loans)
+	return deps.Data.SaveIfMatch(ctx, loansPath, content, etag)
+}
+
+// monthlyRate is the interest charged each month as a fraction of the balance
+func (loan Loan) monthlyRate() float64 {
+	return loan.AnnualRate / 100 / 12
+}
+
+// monthlyInterest is a month's interest on balance, rounded to the cent
+func (loan Loan) monthlyInterest(balance money.Cents) money.Cents {
+	return money.Cents(math.Round(float64(balance) * loan.monthlyRate()))
+}
+
+// instalmentDate is the date of the nth instalment. Instalments keep the start date's day of the
+// month, falling on the last day of shorter months.
+func (loan Loan) instalmentDate(n int) time.Time {
+	start, _ := parseTransactionDate(loan.StartDate)
+	first := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(start.Day(), lastDay)-1)
+}
+
+// amortisationSchedule repays the principal in equal monthly instalments over the term. The
+// final instalment absorbs the rounding so the balance finishes at exactly zero.
+func amortisationSchedule(loan Loan) []LoanInstalment {
+	rate := loan.monthlyRate()
+	n := loan.TermMonths
+	payment := money.Cents(math.Round(float64(loan.Principal) / float64(n)))
+	if rate > 0 {
+		payment = money.Cents(math.Round(float64(loan.Principal) * rate / (1 - math.Pow(1+rate, -float64(n)))))
+	}
+	schedule := make([]LoanInstalment, 0, n)
+	balance := loan.Principal
+	for i := 1; i <= n; i++ {
+		interest := loan.monthlyInterest(balance)
+		principal := payment - interest
+		if i == n || principal > balance {
+			principal = balance
+		}
+		balance -= principal
+		schedule = append(schedule, LoanInstalment{
+			Number:    i,
+			Date:      loan.instalmentDate(i).Format("2006-01-02"),
+			Payment:   interest + principal,
+			Interest:  interest,
+			Principal: principal,
+			Balance:   balance,
+		})
+	}
+	return schedule
+}
+
+// repaymentHistory applies the repayments in date order, each paying a month's interest on the
+// balance before reducing it
+func repaymentHistory(loan Loan) []LoanInstalment {
+	repayments := append([]LoanRepayment(nil), loan.Repayments...)
+	sort.SliceStable(repayments, func(i, j int) bool { return repayments[i].Date < repayments[j].Date })
+	history := make([]LoanInstalment, 0, len(repayments))
+	balance := loan.Principal
+	for _, repayment := range repayments {
+		if _, ok := parseTransactionDate(repayment.Date); !ok {
+			continue
+		}
+		interest := min(repayment.Amount, max(loan.monthlyInterest(balance), 0))
+		balance -= repayment.Amount - interest
+		history = append(history, LoanInstalment{
+			Number:    len(history) + 1,
+			Date:      repayment.Date,
+			Payment:   repayment.Amount,
+			Interest:  interest,
+			Principal: repayment.Amount - interest,
+			Balance:   balance,
+		})
+	}
+	return history
+}
+
+// outstandingAsAt returns the total repaid by asAt and the balance left
+func outstandingAsAt(loan Loan, asAt time.Time) (money.Cents, money.Cents) {
+	var repaid money.Cents
+	balance := loan.Principal
+	for _, repayment := range repaymentHistory(loan) {
+		if date, _ := parseTransactionDate(repayment.Date); date.After(asAt) {
+			break
+		}
+		repaid += repayment.Payment
+		balance = repayment.Balance
+	}
+	return repaid, max(balance, 0)
+}
+
+// loanInterest totals the interest paid by repayments dated within [start, end]
+func loanInterest(loans []Loan, start, end time.Time) money.Cents {
+	var interest money.Cents
+	for _, loan := range loans {
+		for _, repayment := range repaymentHistory(loan) {
+			if date, _ := parseTransactionDate(repayment.Date); !date.Before(start) && !date.After(end) {
+				interest += repayment.Interest
+			}
+		}
+	}
+	return interest
+}
+
+// resolveLoanLinks copies loans with the drawdown and each repayment taking its date and amount
+// from its ledger transaction as it is now, since the transaction may have been edited after the
+// loan was saved. Links to transactions that are no longer in the ledgers are left out, and
+// described in the returned list so the report can flag them.
+func resolveLoanLinks(loans []Loan, transactions map[string]Transaction) ([]Loan, []string) {
+	resolved := make([]Loan, 0, len(loans))
+	missing := []string{}
+	for _, loan := range loans {
+		if drawdown := loan.Drawdown; drawdown != nil {
+			loan.Drawdown = nil
+			if tx, ok := transactions[drawdown.LedgerType+"/"+drawdown.TransactionID]; ok {
+				loan.Drawdown = &LoanDrawdown{LedgerType: drawdown.LedgerType, TransactionID: drawdown.TransactionID, Date: tx.Date, Amount: tx.Amount}
+			} else {
+				missing = append(missing, fmt.Sprintf("%s (%s): drawdown %s transaction %s is no longer in the ledger and is left out.", loan.Lender, loan.Description, drawdown.LedgerType, drawdown.TransactionID))
+			}
+		}
+		repayments := make([]LoanRepayment, 0, len(loan.Repayments))
+		for _, repayment := range loan.Repayments {
+			tx, ok := transactions[repayment.LedgerType+"/"+repayment.TransactionID]
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s (%s): repayment %s transaction %s is no longer in the ledger and is left out.", loan.Lender, loan.Description, repayment.LedgerType, repayment.TransactionID))
+				continue
+			}
+			repayment.Date, repayment.Amount = tx.Date, -tx.Amount
+			repayments = append(repayments, repayment)
+		}
+		loan.Repayments = repayments
+		resolved = append(resolved, loan)
+	}
+	return resolved, missing
+}
+
+// loanLinks keys each drawdown and repayment transaction in the loan schedule as TYPE/ID
+func loanLinks(loans []Loan) map[string]bool {
+	links := map[string]bool{}
+	for _, loan := range loans {
+		if loan.Drawdown != nil {
+			links[loan.Drawdown.LedgerType+"/"+loan.Drawdown.TransactionID] = true
+		}
+		for _, repayment := range loan.Repayments {
+			links[repayment.LedgerType+"/"+repayment.TransactionID] = true
+		}
+	}
+	return links
+}
+
+// newLoanView splits the outstanding balance as at asAt into the principal due within twelve
+// months, which is whatever the schedule says should be repaid by then including any arrears, and
+// the remainder
+func newLoanView(loan Loan, asAt time.Time) loanView {
+	repaid, outstanding := outstandingAsAt(loan, asAt)
+	scheduled := loan.Principal
+	horizon := asAt.AddDate(1, 0, 0)
+	for _, instalment := range amortisationSchedule(loan) {
+		if date, _ := parseTransactionDate(instalment.Date); !date.After(horizon) {
+			scheduled = instalment.Balance
+		}
+	}
+	current := min(max(outstanding-scheduled, 0), outstanding)
+	return loanView{Loan: loan, Repaid: repaid, Outstanding: outstanding, Current: current, NonCurrent: outstanding - current}
+}
+
+// loanLiabilities reports each loan drawn by end as a current and a non-current liability
+func loanLiabilities(loans []Loan, end time.Time) []ReportLineItem {
+	items := []ReportLineItem{}
+	for _, loan := range loans {
+		if start, ok := parseTransactionDate(loan.StartDate); !ok || start.After(end) {
+			continue
+		}
+		view := newLoanView(loan, end)
+		if view.Current != 0 {
+			items = append(items, ReportLineItem{Label: fmt.Sprintf("%s loan - current", loan.Lender), Amount: view.Current})
+		}
+		if view.NonCurrent != 0 {
+			items = append(items, ReportLineItem{Label: fmt.Sprintf("%s loan - non-current", loan.Lender), Amount: view.NonCurrent})
+		}
+	}
+	return items
+}
+
+// loanEntries keys the start, drawdown and each repayment of loan by its contents and the loan's
+// terms, mapped to its date, so changing the terms changes every entry. A missing loan has none.
+func loanEntries(loan *Loan) map[string]string {
+	entries := map[string]string{}
+	if loan == nil {
+		return entries
+	}
+	terms := fmt.Sprintf("%s|%d|%g|%d|%s", loan.Lender, loan.Principal, loan.AnnualRate, loan.TermMonths, loan.StartDate)
+	entries["start "+terms] = loan.StartDate
+	if loan.Drawdown != nil {
+		entries[fmt.Sprintf("drawdown %s %+v", terms, *loan.Drawdown)] = loan.Drawdown.Date
+	}
+	for _, repayment := range loan.Repayments {
+		entries[fmt.Sprintf("repayment %s %+v", terms, repayment)] = repayment.Date
+	}
+	return entries
+}
+
+// buildLoansNote describes every loan drawn by end
+func buildLoansNote(loans []Loan, end time.Time) ReportNote {
+	details := []string{}
+	for _, loan := range loans {
+		if start, ok := parseTransactionDate(loan.StartDate); !ok || start.After(end) {
+			continue
+		}
+		view := newLoanView(loan, end)
+		detail := fmt.Sprintf("%s (%s): %s at %.2f%% over %d months from %s; repaid %s",
+			loan.Lender, loan.Description, formatCurrency(loan.Principal), loan.AnnualRate, loan.TermMonths, loan.StartDate, formatCurrency(view.Repaid))
+		if view.Outstanding == 0 {
+			detail += ", repaid in full"
+		} else {
+			detail += fmt.Sprintf(", outstanding %s of which %s is due within 12 months", formatCurrency(view.Outstanding), formatCurrency(view.Current))
+		}
+		details = append(details, detail+".")
+	}
+	if len(details) == 0 {
+		details = append(details, "No loans in the loan schedule for the period.")
+	}
+	return ReportNote{Title: "Loans", Details: details}
+}
+
+// validateLoan checks loan and fills in its drawdown and each repayment from their ledger
+// transactions. A transaction can only draw down or repay one loan.
+func validateLoan(loan *Loan, others []Loan, ledgersByType map[string][]MonthlyLedger) []ValidationViolation {
+	violations := []ValidationViolation{}
+	if loan.Lender == "" {
+		violations = append(violations, ValidationViolation{Field: "lender", Message: "Lender is required"})
+	}
+	if loan.Principal <= 0 {
+		violations = append(violations, ValidationViolation{Field: "principal", Message: "Principal must be positive"})
+	}
+	if loan.AnnualRate < 0 || loan.AnnualRate > 100 {
+		violations = append(violations, ValidationViolation{Field: "annualRate", Message: "Annual rate must be between 0 and 100 percent"})
+	}
+	if loan.TermMonths < 1 || loan.TermMonths > maxLoanTermMonths {
+		violations = append(violations, ValidationViolation{Field: "termMonths", Message: fmt.Sprintf("Term must be between 1 and %d months", maxLoanTermMonths)})
+	}
+	if _, ok := parseTransactionDate(loan.StartDate); !ok {
+		violations = append(violations, ValidationViolation{Field: "startDate", Message: "Date must be YYYY-MM-DD"})
+	}
+
+	transactions := ledgerTransactionIndex(ledgersByType)
+	repaidBy := map[string]string{}
+	drawnBy := map[string]string{}
+	for _, other := range others {
+		for _, repayment := range other.Repayments {
+			repaidBy[repayment.LedgerType+"/"+repayment.TransactionID] = other.Lender
+		}
+		if other.Drawdown != nil {
+			drawnBy[other.Drawdown.LedgerType+"/"+other.Drawdown.TransactionID] = other.Lender
+		}
+	}
+
+	if drawdown := loan.Drawdown; drawdown != nil {
+		drawdown.LedgerType = strings.ToUpper(strings.TrimSpace(drawdown.LedgerType))
+		key := drawdown.LedgerType + "/" + drawdown.TransactionID
+		tx, ok := transactions[key]
+		switch {
+		case !ok:
+			violations = append(violations, ValidationViolation{TransactionID: drawdown.TransactionID, Field: "drawdown", Message: fmt.Sprintf("Transaction is not in the %s ledger", drawdown.LedgerType)})
+		case tx.Amount <= 0:
+			violations = append(violations, ValidationViolation{TransactionID: drawdown.TransactionID, Field: "drawdown", Message: "Loan drawdown must be a deposit"})
+		case drawnBy[key] != "":
+			violations = append(violations, ValidationViolation{TransactionID: drawdown.TransactionID, Field: "drawdown", Message: fmt.Sprintf("Transaction already draws down the %s loan", drawnBy[key])})
+		default:
+			drawdown.Date, drawdown.Amount = tx.Date, tx.Amount
+		}
+	}
+
+	for i := range loan.Repayments {
+		repayment := &loan.Repayments[i]
+		repayment.LedgerType = strings.ToUpper(strings.TrimSpace(repayment.LedgerType))
+		key := repayment.LedgerType + "/" + repayment.TransactionID
+		field := fmt.Sprintf("repayments[%d]", i)
+		tx, ok := transactions[key]
+		switch {
+		case !ok:
+			violations = append(violations, ValidationViolation{TransactionID: repayment.TransactionID, Field: field, Message: fmt.Sprintf("Transaction is not in the %s ledger", repayment.LedgerType)})
+		case tx.Amount >= 0:
+			violations = append(violations, ValidationViolation{TransactionID: repayment.TransactionID, Field: field, Message: "Loan repayment must be a payment"})
+		case repaidBy[key] != "":
+			violations = append(violations, ValidationViolation{TransactionID: repayment.TransactionID, Field: field, Message: fmt.Sprintf("Transaction already repays the %s loan", repaidBy[key])})
+		default:
+			repayment.Date, repayment.Amount = tx.Date, -tx.Amount
+			repaidBy[key] = loan.Lender
+		}
+	}
+	return violations
+}
+
+// LoansGet lists the loan schedule with each loan's outstanding balance today
+func LoansGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	loans, _, err := loadLoans(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	now := time.Now()
+	views := make([]loanView, 0, len(loans))
+	for _, loan := range loans {
+		views = append(views, newLoanView(loan, now))
+	}
+	body, _ := json.Marshal(views)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// LoanScheduleGet returns the amortisation schedule of the loan named by the id parameter
+func LoanScheduleGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	id := request.QueryStringParameters["id"]
+	if id == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	loans, _, err := loadLoans(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	for _, loan := range loans {
+		if loan.ID == id {
+			body, _ := json.Marshal(loanScheduleResponse{Loan: newLoanView(loan, time.Now()), Schedule: amortisationSchedule(loan)})
+			return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+		}
+	}
+	return events.APIGatewayProxyResponse{Body: `{"error": "Loan not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+}
+
+// LoansPost records a new loan, or replaces the loan with the same ID
+func LoansPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var loan Loan
+	if err := json.Unmarshal([]byte(request.Body), &loan); err != nil {
+		fmt.Printf("Invalid loan body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	loan.Lender = strings.TrimSpace(loan.Lender)
+	loan.Description = strings.TrimSpace(loan.Description)
+	if loan.Repayments == nil {
+		loan.Repayments = []LoanRepayment{}
+	}
+
+	loans, etag, err := loadLoans(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	others := make([]Loan, 0, len(loans))
+	var previous *Loan
+	for i, existing := range loans {
+		if existing.ID != loan.ID || loan.ID == "" {
+			others = append(others, existing)
+		} else {
+			previous = &loans[i]
+		}
+	}
+	if loan.ID != "" && previous == nil {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Loan not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	if violations := validateLoan(&loan, others, ledgersByType); len(violations) > 0 {
+		return newValidationErrorResponse("Loan validation failed", violations, deps.Headers), nil
+	}
+	if locked := registerPeriodLocked(ctx, deps, loanEntries(previous), loanEntries(&loan)); locked != nil {
+		return *locked, nil
+	}
+	if loan.ID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return errorResponse(err, deps.Headers), nil
+		}
+		loan.ID = id
+	}
+	if err := saveLoans(ctx, deps, append(others, loan), etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Saved loan %s from %s\n", loan.ID, loan.Lender)
+	body, _ := json.Marshal(newLoanView(loan, time.Now()))
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// LoansDelete removes the loan named by the id parameter from the schedule
+func LoansDelete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	id := request.QueryStringParameters["id"]
+	if id == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	loans, etag, err := loadLoans(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	kept := make([]Loan, 0, len(loans))
+	var removed *Loan
+	for i, loan := range loans {
+		if loan.ID != id {
+			kept = append(kept, loan)
+		} else {
+			removed = &loans[i]
+		}
+	}
+	if removed == nil {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Loan not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	if locked := registerPeriodLocked(ctx, deps, loanEntries(removed), nil); locked != nil {
+		return *locked, nil
+	}
+	if err := saveLoans(ctx, deps, kept, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Deleted loan %s\n", id)
+	return events.APIGatewayProxyResponse{Body: `{"status": "deleted"}`, StatusCode: 200, Headers: deps.Headers}, nil
+}
@@ -0,0 +1,234 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func loanTestLedgers() []MonthlyLedger {
+	return []MonthlyLedger{
+		testLedger("BANK", "2024-07", 0,
+			Transaction{ID: "loan", Date: "2024-07-01", Category: "Loans", Description: "Bank loan", Amount: 1200000},
+			Transaction{ID: "fit-out", Date: "2024-07-10", Category: "Equipment", Description: "Clubroom fit-out", Amount: -200000},
+		),
+		testLedger("BANK", "2025-05", 1000000,
+			Transaction{ID: "may", Date: "2025-05-01", Category: "Loans", Description: "Bank loan", Amount: -50000},
+			Transaction{ID: "fees", Date: "2025-05-12", Category: "Membership", Description: "Fees", Amount: 20000},
+			Transaction{ID: "june", Date: "2025-05-30", Category: "Loans", Description: "Bank loan", Amount: -50000},
+		),
+	}
+}
+
+func TestAmortisationSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		loan Loan
+		want []LoanInstalment
+	}{
+		{
+			name: "Interest free from the end of a month",
+			loan: Loan{Principal: 30000, TermMonths: 3, StartDate: "2025-01-31"},
+			want: []LoanInstalment{
+				{Number: 1, Date: "2025-02-28", Payment: 10000, Principal: 10000, Balance: 20000},
+				{Number: 2, Date: "2025-03-31", Payment: 10000, Principal: 10000, Balance: 10000},
+				{Number: 3, Date: "2025-04-30", Payment: 10000, Principal: 10000, Balance: 0},
+			},
+		},
+		{
+			name: "Last instalment takes up the rounding",
+			loan: Loan{Principal: 1000000, AnnualRate: 12, TermMonths: 3, StartDate: "2025-07-15"},
+			want: []LoanInstalment{
+				{Number: 1, Date: "2025-08-15", Payment: 340022, Interest: 10000, Principal: 330022, Balance: 669978},
+				{Number: 2, Date: "2025-09-15", Payment: 340022, Interest: 6700, Principal: 333322, Balance: 336656},
+				{Number: 3, Date: "2025-10-15", Payment: 340023, Interest: 3367, Principal: 336656, Balance: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := amortisationSchedule(tt.loan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("amortisationSchedule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateLoan(t *testing.T) {
+	ledgersByType := map[string][]MonthlyLedger{"BANK": loanTestLedgers()}
+	others := []Loan{{Lender: "Council", Repayments: []LoanRepayment{{LedgerType: "BANK", TransactionID: "june"}}}}
+	loan := Loan{AnnualRate: 120, StartDate: "2025-13-01", Drawdown: &LoanDrawdown{LedgerType: "BANK", TransactionID: "may"}, Repayments: []LoanRepayment{
+		{LedgerType: "bank", TransactionID: "may"},
+		{LedgerType: "BANK", TransactionID: "fees"},
+		{LedgerType: "BANK", TransactionID: "june"},
+	}}
+	want := []ValidationViolation{
+		{Field: "lender", Message: "Lender is required"},
+		{Field: "principal", Message: "Principal must be positive"},
+		{Field: "annualRate", Message: "Annual rate must be between 0 and 100 percent"},
+		{Field: "termMonths", Message: "Term must be between 1 and 360 months"},
+		{Field: "startDate", Message: "Date must be YYYY-MM-DD"},
+		{TransactionID: "may", Field: "drawdown", Message: "Loan drawdown must be a deposit"},
+		{TransactionID: "fees", Field: "repayments[1]", Message: "Loan repayment must be a payment"},
+		{TransactionID: "june", Field: "repayments[2]", Message: "Transaction already repays the Council loan"},
+	}
+	if got := validateLoan(&loan, others, ledgersByType); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateLoan() = %+v, want %+v", got, want)
+	}
+	if repayment := loan.Repayments[0]; repayment != (LoanRepayment{LedgerType: "BANK", TransactionID: "may", Date: "2025-05-01", Amount: 50000}) {
+		t.Errorf("repayment = %+v", repayment)
+	}
+}
+
+func TestLoansReport(t *testing.T) {
+	deps := Dependencies{Data: newTestDataProvider(t, loanTestLedgers()...), Headers: DefaultHeaders()}
+	ctx := context.Background()
+
+	// An interest free loan of $500 a month, the last of 24 instalments falling on 1 July 2026
+	body := `{"lender": "Bendigo Bank", "description": "Clubroom fit-out", "principal": "12000.00", "annualRate": 0, "termMonths": 24, "startDate": "2024-07-01",
+		"drawdown": {"ledgerType": "BANK", "transactionId": "loan"},
+		"repayments": [{"ledgerType": "BANK", "transactionId": "may"}, {"ledgerType": "BANK", "transactionId": "june"}]}`
+	got, err := LoansPost(ctx, events.APIGatewayProxyRequest{Body: body}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LoansPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var saved loanView
+	if err := json.Unmarshal([]byte(got.Body), &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" || saved.Drawdown == nil || saved.Drawdown.Amount != 1200000 || saved.Repaid != 100000 || saved.Outstanding != 1100000 {
+		t.Errorf("saved = %+v", saved)
+	}
+
+	// Only two instalments have been paid, so the arrears are due within 12 months along with the
+	// year's instalments, leaving the final instalment non-current
+	wantLiabilities := []ReportLineItem{
+		{Label: "Bendigo Bank loan - current", Amount: 1050000},
+		{Label: "Bendigo Bank loan - non-current", Amount: 50000},
+	}
+	wantNote := ReportNote{Title: "Loans", Details: []string{
+		"Bendigo Bank (Clubroom fit-out): $12,000.00 at 0.00% over 24 months from 2024-07-01; repaid $1,000.00, outstanding $11,000.00 of which $10,500.00 is due within 12 months.",
+	}}
+	// The drawdown and the interest free repayments stay out of the statement, so the net result
+	// matches the accumulated funds of the club's first year
+	check := func(name string) {
+		t.Helper()
+		report, err := buildFinancialReport(ctx, deps, financialYearPeriod("fy-1", 2025))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(report.Statement.Income, []ReportLineItem{{Label: "Membership", Amount: 20000}}) ||
+			!reflect.DeepEqual(report.Statement.Expenditure, []ReportLineItem{{Label: "Equipment", Amount: 200000}}) {
+			t.Errorf("%s: statement = %+v", name, report.Statement)
+		}
+		if !reflect.DeepEqual(report.BalanceSheet.Liabilities, wantLiabilities) || report.BalanceSheet.TotalLiabilities != 1100000 {
+			t.Errorf("%s: liabilities = %+v", name, report.BalanceSheet.Liabilities)
+		}
+		if report.BalanceSheet.TotalAssets != 920000 || report.BalanceSheet.Equity != report.Statement.NetResult {
+			t.Errorf("%s: balance sheet = %+v, net result %s", name, report.BalanceSheet, report.Statement.NetResult)
+		}
+		if !reflect.DeepEqual(report.Notes[2], wantNote) {
+			t.Errorf("%s: loans note = %+v, want %+v", name, report.Notes[2], wantNote)
+		}
+	}
+	check("ledgers")
+	got, err = JournalMigrate(ctx, events.APIGatewayProxyRequest{}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("JournalMigrate() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	check("journal")
+
+	got, err = LoanScheduleGet(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": saved.ID}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LoanScheduleGet() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var schedule loanScheduleResponse
+	if err := json.Unmarshal([]byte(got.Body), &schedule); err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule.Schedule) != 24 || schedule.Schedule[23] != (LoanInstalment{Number: 24, Date: "2026-07-01", Payment: 50000, Principal: 50000}) {
+		t.Errorf("schedule = %+v", schedule.Schedule)
+	}
+
+	// Editing and then deleting a linked repayment is picked up without re-saving the loan
+	bank, err := loadLedgerMonths(ctx, deps, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank[1].Transactions[2].Amount = -100000
+	recalculateLedger(&bank[1])
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, bank[1:])}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	report, err := buildFinancialReport(ctx, deps, financialYearPeriod("fy-1", 2025))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "repaid $1,500.00, outstanding $10,500.00"; !strings.Contains(report.Notes[2].Details[0], want) || len(report.Notes) != 4 {
+		t.Errorf("edited: notes = %+v, want %q", report.Notes, want)
+	}
+	bank, err = loadLedgerMonths(ctx, deps, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank[1].Transactions = bank[1].Transactions[:2]
+	recalculateLedger(&bank[1])
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, bank[1:])}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	report, err = buildFinancialReport(ctx, deps, financialYearPeriod("fy-1", 2025))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUnresolved := ReportNote{Title: "Unresolved register links", Details: []string{
+		"Bendigo Bank (Clubroom fit-out): repayment BANK transaction june is no longer in the ledger and is left out.",
+	}}
+	if len(report.Notes) != 5 || !reflect.DeepEqual(report.Notes[4], wantUnresolved) || !strings.Contains(report.Notes[2].Details[0], "repaid $500.00") {
+		t.Errorf("deleted: notes = %+v", report.Notes)
+	}
+
+	got, err = LoansDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": saved.ID}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Errorf("LoansDelete() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+}
+
+func TestNewLoanView_RepaidInFull(t *testing.T) {
+	loan := Loan{Lender: "Council", Principal: 100000, TermMonths: 2, StartDate: "2025-01-01", Repayments: []LoanRepayment{
+		{Date: "2025-02-01", Amount: 50000},
+		{Date: "2025-03-01", Amount: 50000},
+	}}
+	view := newLoanView(loan, time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC))
+	if view.Outstanding != 0 || view.Current != 0 || view.NonCurrent != 0 {
+		t.Errorf("view = %+v", view)
+	}
+	if items := loanLiabilities([]Loan{loan}, time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)); len(items) != 0 {
+		t.Errorf("loanLiabilities() = %+v", items)
+	}
+}
+
+func TestLoanInterest(t *testing.T) {
+	loan := Loan{Principal: 1000000, AnnualRate: 12, TermMonths: 3, StartDate: "2025-07-15", Repayments: []LoanRepayment{
+		{Date: "2025-09-15", Amount: 340022},
+		{Date: "2025-08-15", Amount: 340022},
+	}}
+	// Each repayment's interest is the month's interest on the balance the earlier ones left
+	august := time.Date(2025, time.August, 31, 0, 0, 0, 0, time.UTC)
+	if got := loanInterest([]Loan{loan}, time.Date(2025, time.August, 1, 0, 0, 0, 0, time.UTC), august); got != 10000 {
+		t.Errorf("loanInterest(August) = %s", got)
+	}
+	fy2026 := financialYearPeriod("fy", 2026)
+	if got := loanInterest([]Loan{loan}, fy2026.Start, fy2026.End); got != 16700 {
+		t.Errorf("loanInterest(FY 2026) = %s", got)
+	}
+	if repaid, outstanding := outstandingAsAt(loan, fy2026.End); repaid != 680044 || outstanding != 336656 {
+		t.Errorf("outstandingAsAt() = %s, %s", repaid, outstanding)
+	}
+}
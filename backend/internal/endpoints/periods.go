@@ -0,0 +1,406 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const (
+	periodLocksPath   = "period-locks.json"
+	periodClosePrefix = "period-close/"
+)
+
+// PeriodLock stops ledger and journal writes to a whole financial year or to a single month.
+// Exactly one of FinancialYear and Month is set.
+type PeriodLock struct {
+	// FinancialYear is the year the July to June financial year ends in
+	FinancialYear int    `json:"financialYear,omitempty"`
+	Month         string `json:"month,omitempty"`
+	Note          string `json:"note,omitempty"`
+	LockedAt      string `json:"lockedAt"`
+	// Closed is set when the lock came from a year-end close, whose snapshot is reported while the
+	// year stays locked
+	Closed bool `json:"closed,omitempty"`
+}
+
+// PeriodClose is the snapshot taken when a financial year is closed
+type PeriodClose struct {
+	FinancialYear   int                     `json:"financialYear"`
+	ClosedAt        string                  `json:"closedAt"`
+	ClosingBalances map[string]money.Cents  `json:"closingBalances"`
+	Report          FinancialReportResponse `json:"report"`
+}
+
+// ledgerLockedError reports months that a ledger write would change but which are locked, with
+// the reason each one is locked
+type ledgerLockedError struct {
+	Months  []string
+	Reasons []string
+}
+
+func (e *ledgerLockedError) Error() string {
+	locked := make([]string, len(e.Months))
+	for i, month := range e.Months {
+		locked[i] = fmt.Sprintf("%s (%s)", month, e.Reasons[i])
+	}
+	return "ledger months are locked: " + strings.Join(locked, ", ")
+}
+
+// ledgerLocks are the locks on one ledger type's months
+type ledgerLocks struct {
+	reconciled map[string]string
+	periods    []PeriodLock
+}
+
+// reason explains why month is locked, or is empty if it is not
+func (l ledgerLocks) reason(month string) string {
+	if reason := periodLockReason(l.periods, month); reason != "" {
+		return reason
+	}
+	return l.reconciled[month]
+}
+
+func loadLedgerLocks(ctx context.Context, deps Dependencies, ledgerType string) (ledgerLocks, error) {
+	reconciled, err := lockedLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return ledgerLocks{}, err
+	}
+	periods, _, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		return ledgerLocks{}, err
+	}
+	return ledgerLocks{reconciled: reconciled, periods: periods}, nil
+}
+
+// financialYearOf returns the year the financial year containing month, as YYYY-MM, ends in
+func financialYearOf(month string) int {
+	parsed, ok := parseLedgerMonth(month)
+	if !ok {
+		return 0
+	}
+	return currentFinancialYearEnd(parsed)
+}
+
+func (l PeriodLock) covers(month string) bool {
+	if l.Month != "" {
+		return l.Month == month
+	}
+	return l.FinancialYear == financialYearOf(month)
+}
+
+func (l PeriodLock) label() string {
+	if l.Month != "" {
+		return l.Month
+	}
+	return fmt.Sprintf("FY %d", l.FinancialYear)
+}
+
+// periodLockReason explains which lock covers month, or is empty if none does
+func periodLockReason(locks []PeriodLock, month string) string {
+	for _, lock := range locks {
+		if !lock.covers(month) {
+			continue
+		}
+		if lock.Closed {
+			return lock.label() + " is closed"
+		}
+		return lock.label() + " is locked"
+	}
+	return ""
+}
+
+func loadPeriodLocks(ctx context.Context, deps Dependencies) ([]PeriodLock, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, periodLocksPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return []PeriodLock{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var locks []PeriodLock
+	if err := json.Unmarshal(content, &locks); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %w", periodLocksPath, err)
+	}
+	return locks, etag, nil
+}
+
+// savePeriodLocks writes the registry ordered by the period each lock starts in
+func savePeriodLocks(ctx context.Context, deps Dependencies, locks []PeriodLock, etag string) error {
+	start := func(lock PeriodLock) string {
+		if lock.Month != "" {
+			return lock.Month
+		}
+		return fmt.Sprintf("%d-07", lock.FinancialYear-1)
+	}
+	sort.SliceStable(locks, func(i, j int) bool {
+		return start(locks[i]) < start(locks[j])
+	})
+	content, _ := json.Marshal(locks)
+	return deps.Data.SaveIfMatch(ctx, periodLocksPath, content, etag)
+}
+
+func periodClosePath(financialYear int) string {
+	return fmt.Sprintf("%s%d.json", periodClosePrefix, financialYear)
+}
+
+// loadClosedYear returns the close snapshot of financialYear if the year is closed and still
+// locked, and nil otherwise
+func loadClosedYear(ctx context.Context, deps Dependencies, financialYear int) (*PeriodClose, error) {
+	if financialYear == 0 {
+		return nil, nil
+	}
+	locks, _, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	closed := false
+	for _, lock := range locks {
+		closed = closed || (lock.FinancialYear == financialYear && lock.Closed)
+	}
+	if !closed {
+		return nil, nil
+	}
+	content, err := deps.Data.Get(ctx, periodClosePath(financialYear))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot PeriodClose
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// periodLockParams reads the financialYear or month query parameter that names a lock
+func periodLockParams(request events.APIGatewayProxyRequest, headers map[string]string) (PeriodLock, *events.APIGatewayProxyResponse) {
+	year := request.QueryStringParameters["financialYear"]
+	month := request.QueryStringParameters["month"]
+	switch {
+	case year != "" && month != "":
+		return PeriodLock{}, &events.APIGatewayProxyResponse{Body: `{"error": "Give either financialYear or month, not both"}`, StatusCode: 400, Headers: headers}
+	case month != "":
+		month, badRequest := monthQueryParam(request, headers)
+		return PeriodLock{Month: month}, badRequest
+	}
+	financialYear, err := strconv.Atoi(year)
+	if err != nil || financialYear <= 0 {
+		return PeriodLock{}, &events.APIGatewayProxyResponse{Body: `{"error": "financialYear or month is required"}`, StatusCode: 400, Headers: headers}
+	}
+	return PeriodLock{FinancialYear: financialYear}, nil
+}
+
+// validatePeriodLock checks a new lock's period is not already locked
+func validatePeriodLock(lock PeriodLock, locks []PeriodLock) []ValidationViolation {
+	violations := []ValidationViolation{}
+	for _, other := range locks {
+		if other.FinancialYear == lock.FinancialYear && other.Month == lock.Month {
+			violations = append(violations, ValidationViolation{Month: lock.Month, Field: "period", Message: fmt.Sprintf("%s is already locked", lock.label())})
+		}
+	}
+	return violations
+}
+
+// PeriodLocksGet lists the locked financial years and months
+func PeriodLocksGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	locks, _, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	body, _ := json.Marshal(locks)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// PeriodLockPost locks the financial year or month named by the financialYear or month parameter
+// against ledger and journal writes, with an optional note parameter
+func PeriodLockPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	lock, badRequest := periodLockParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	locks, etag, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	lock.Note = strings.TrimSpace(request.QueryStringParameters["note"])
+	lock.LockedAt = time.Now().UTC().Format(time.RFC3339)
+	if violations := validatePeriodLock(lock, locks); len(violations) > 0 {
+		return newValidationErrorResponse("Period lock validation failed", violations, deps.Headers), nil
+	}
+	if err := savePeriodLocks(ctx, deps, append(locks, lock), etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Locked %s\n", lock.label())
+	body, _ := json.Marshal(lock)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// PeriodLockDelete unlocks the financial year or month named by the financialYear or month
+// parameter. Unlocking a closed year reports it from the ledgers again.
+func PeriodLockDelete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	target, badRequest := periodLockParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	locks, etag, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	kept := make([]PeriodLock, 0, len(locks))
+	var removed *PeriodLock
+	for i, lock := range locks {
+		if lock.FinancialYear == target.FinancialYear && lock.Month == target.Month {
+			removed = &locks[i]
+			continue
+		}
+		kept = append(kept, lock)
+	}
+	if removed == nil {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Period is not locked"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	if err := savePeriodLocks(ctx, deps, kept, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Unlocked %s\n", removed.label())
+	body, _ := json.Marshal(removed)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// PeriodCloseYear closes the financial year named by the financialYear parameter once it has ended.
+// It snapshots the year's financial report and each ledger's closing balance, which the fy-1 and
+// fy-2 reports then read, and locks the year.
+func PeriodCloseYear(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	target, badRequest := periodLockParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	if target.FinancialYear == 0 {
+		return events.APIGatewayProxyResponse{Body: `{"error": "financialYear is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	spec := financialYearPeriod(fmt.Sprintf("fy%d", target.FinancialYear), target.FinancialYear)
+	now := time.Now().UTC()
+	if !now.After(spec.End) {
+		body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("%s has not ended", spec.Label)})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+	}
+
+	locks, etag, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	lock := PeriodLock{FinancialYear: target.FinancialYear}
+	kept := make([]PeriodLock, 0, len(locks))
+	for _, existing := range locks {
+		if existing.FinancialYear == target.FinancialYear {
+			if existing.Closed {
+				body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("%s is already closed; unlock it before closing it again", spec.Label)})
+				return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+			}
+			lock = existing
+			continue
+		}
+		kept = append(kept, existing)
+	}
+
+	report, err := buildFinancialReport(ctx, deps, spec)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	snapshot := PeriodClose{
+		FinancialYear:   target.FinancialYear,
+		ClosedAt:        now.Format(time.RFC3339),
+		ClosingBalances: map[string]money.Cents{},
+		Report:          report,
+	}
+	for ledgerType, ledgers := range ledgersByType {
+		if balance, ok := ledgerBalanceAsAt(ledgers, spec.End); ok {
+			snapshot.ClosingBalances[ledgerType] = balance
+		}
+	}
+	content, _ := json.Marshal(snapshot)
+	if err := deps.Data.Save(ctx, periodClosePath(target.FinancialYear), content); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+
+	lock.Closed = true
+	lock.LockedAt = snapshot.ClosedAt
+	if err := savePeriodLocks(ctx, deps, append(kept, lock), etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Closed %s with closing balances %v\n", spec.Label, snapshot.ClosingBalances)
+	body, _ := json.Marshal(snapshot)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// registerPeriodLocked returns a 409 response naming the locked months of register entries that
+// a change adds or removes, and nil if there are none. before and after map each entry the
+// reports use, keyed so that any change to it changes the key, to its date.
+func registerPeriodLocked(ctx context.Context, deps Dependencies, before, after map[string]string) *events.APIGatewayProxyResponse {
+	changed := map[string]bool{}
+	for key, date := range before {
+		if _, ok := after[key]; !ok && len(date) >= len("2006-01") {
+			changed[date[:len("2006-01")]] = true
+		}
+	}
+	for key, date := range after {
+		if _, ok := before[key]; !ok && len(date) >= len("2006-01") {
+			changed[date[:len("2006-01")]] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	locks, _, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		response := storageErrorResponse(err, deps.Headers)
+		return &response
+	}
+	months := make([]string, 0, len(changed))
+	for month := range changed {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	blocked := &ledgerLockedError{}
+	for _, month := range months {
+		if reason := periodLockReason(locks, month); reason != "" {
+			blocked.Months = append(blocked.Months, month)
+			blocked.Reasons = append(blocked.Reasons, reason)
+		}
+	}
+	if len(blocked.Months) == 0 {
+		return nil
+	}
+	response := ledgerSaveErrorResponse(blocked, []string{}, deps.Headers)
+	return &response
+}
+
+// journalPeriodLocked returns a 409 response if month is in a locked period, and nil otherwise
+func journalPeriodLocked(ctx context.Context, deps Dependencies, month string) *events.APIGatewayProxyResponse {
+	locks, _, err := loadPeriodLocks(ctx, deps)
+	if err != nil {
+		response := storageErrorResponse(err, deps.Headers)
+		return &response
+	}
+	reason := periodLockReason(locks, month)
+	if reason == "" {
+		return nil
+	}
+	body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Journal month %s cannot be changed: %s", month, reason)})
+	return &events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}
+}
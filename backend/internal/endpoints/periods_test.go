@@ -0,0 +1,267 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestPeriodLockReason(t *testing.T) {
+	locks := []PeriodLock{
+		{FinancialYear: 2024, Closed: true},
+		{FinancialYear: 2025},
+		{Month: "2025-09"},
+	}
+	tests := []struct {
+		month string
+		want  string
+	}{
+		{month: "2023-06", want: ""},
+		{month: "2023-07", want: "FY 2024 is closed"},
+		{month: "2024-06", want: "FY 2024 is closed"},
+		{month: "2024-07", want: "FY 2025 is locked"},
+		{month: "2025-06", want: "FY 2025 is locked"},
+		{month: "2025-07", want: ""},
+		{month: "2025-09", want: "2025-09 is locked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			if got := periodLockReason(locks, tt.month); got != tt.want {
+				t.Errorf("periodLockReason(%s) = %q, want %q", tt.month, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodLocks(t *testing.T) {
+	ledgers := journalTestLedgers()
+	prov := newTestDataProvider(t, ledgers...)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+
+	post := func(ledger MonthlyLedger) events.APIGatewayProxyResponse {
+		t.Helper()
+		ledger.Transactions = append(ledger.Transactions, Transaction{ID: "late-" + ledger.Month, Date: ledger.Month + "-20", Category: "Membership", Description: "Late subs", Amount: 1000})
+		recalculateLedger(&ledger)
+		got, err := LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, []MonthlyLedger{ledger})}, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	lock := func(params map[string]string, wantStatus int) {
+		t.Helper()
+		got, err := PeriodLockPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: params}, deps)
+		if err != nil || got.StatusCode != wantStatus {
+			t.Fatalf("PeriodLockPost(%v) = %d %s, %v", params, got.StatusCode, got.Body, err)
+		}
+	}
+
+	lock(map[string]string{"financialYear": "2025", "note": "Adopted at the 2025 AGM"}, 200)
+	lock(map[string]string{"financialYear": "2025"}, 422)
+	lock(map[string]string{"financialYear": "2025", "month": "2025-02"}, 400)
+	lock(map[string]string{"month": "2025-2"}, 400)
+	got := post(ledgers[1])
+	if got.StatusCode != 409 || !strings.Contains(got.Body, "2025-02 (FY 2025 is locked)") {
+		t.Errorf("LedgerPost() in locked year = %d %s", got.StatusCode, got.Body)
+	}
+
+	got, err := PeriodLockDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"financialYear": "2025"}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("PeriodLockDelete() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	got, err = PeriodLockDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"financialYear": "2025"}}, deps)
+	if err != nil || got.StatusCode != 404 {
+		t.Errorf("PeriodLockDelete() of unlocked year = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	// A month lock also stops changes to earlier months from rolling forward into it
+	lock(map[string]string{"month": "2025-02"}, 200)
+	if got := post(ledgers[0]); got.StatusCode != 409 || !strings.Contains(got.Body, "2025-02 (2025-02 is locked)") {
+		t.Errorf("LedgerPost() rolling into locked month = %d %s", got.StatusCode, got.Body)
+	}
+	got, err = PeriodLocksGet(ctx, events.APIGatewayProxyRequest{}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("PeriodLocksGet() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var locks []PeriodLock
+	if err := json.Unmarshal([]byte(got.Body), &locks); err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 || locks[0].Month != "2025-02" || locks[0].LockedAt == "" {
+		t.Errorf("locks = %+v", locks)
+	}
+	if _, err := PeriodLockDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"month": "2025-02"}}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if got := post(ledgers[0]); got.StatusCode != 200 {
+		t.Errorf("LedgerPost() after unlock = %d %s", got.StatusCode, got.Body)
+	}
+}
+
+func TestPeriodCloseYear(t *testing.T) {
+	// Last financial year, so that it has ended and is what fy-1 reports
+	endYear := currentFinancialYearEnd(time.Now()) - 1
+	month := fmt.Sprintf("%d-03", endYear)
+	ledger := MonthlyLedger{
+		PK:             "LEDGER#BANK#" + month,
+		Month:          month,
+		Type:           "BANK",
+		OpeningBalance: 10000,
+		Transactions: []Transaction{
+			{ID: "entries", Date: month + "-05", Category: "Event Fee", Description: "Race entries", Amount: 2500},
+		},
+	}
+	recalculateLedger(&ledger)
+	prov := newTestDataProvider(t, ledger)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+	closeYear := func(year int) events.APIGatewayProxyResponse {
+		t.Helper()
+		got, err := PeriodCloseYear(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"financialYear": strconv.Itoa(year)}}, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	report := func() FinancialReportResponse {
+		t.Helper()
+		got, err := FinancialReportGet(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"period": "fy-1"}}, deps)
+		if err != nil || got.StatusCode != 200 {
+			t.Fatalf("FinancialReportGet() = %d %s, %v", got.StatusCode, got.Body, err)
+		}
+		var response FinancialReportResponse
+		if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	if got := closeYear(endYear + 1); got.StatusCode != 409 {
+		t.Errorf("PeriodCloseYear() of the current year = %d %s", got.StatusCode, got.Body)
+	}
+	got := closeYear(endYear)
+	if got.StatusCode != 200 {
+		t.Fatalf("PeriodCloseYear() = %d %s", got.StatusCode, got.Body)
+	}
+	var snapshot PeriodClose
+	if err := json.Unmarshal([]byte(got.Body), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ClosingBalances["BANK"] != 12500 || snapshot.Report.Statement.TotalIncome != 2500 {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+	if got := closeYear(endYear); got.StatusCode != 409 {
+		t.Errorf("PeriodCloseYear() twice = %d %s", got.StatusCode, got.Body)
+	}
+
+	// The closed year is reported from its snapshot, even if its ledger is changed behind the lock
+	changed := ledger
+	changed.Transactions = append(changed.Transactions, Transaction{ID: "late", Date: month + "-20", Category: "Membership", Description: "Subs", Amount: 1000})
+	recalculateLedger(&changed)
+	if err := prov.Save(ctx, ledgerPrefix+"BANK/"+month+".json", []byte(mustJSON(t, changed))); err != nil {
+		t.Fatal(err)
+	}
+	if got := report(); got.Period != "fy-1" || got.Statement.TotalIncome != 2500 || got.BalanceSheet.TotalAssets != 12500 {
+		t.Errorf("closed report = %+v", got)
+	}
+
+	if _, err := PeriodLockDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"financialYear": strconv.Itoa(endYear)}}, deps); err != nil {
+		t.Fatal(err)
+	}
+	if got := report(); got.Statement.TotalIncome != 3500 || got.BalanceSheet.TotalAssets != 13500 {
+		t.Errorf("report after unlock = %+v", got)
+	}
+}
+
+func TestLedgerCategoriesMerge_LockedPeriod(t *testing.T) {
+	prov := newTestDataProvider(t, journalTestLedgers()...)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+	if got, err := JournalMigrate(ctx, events.APIGatewayProxyRequest{}, deps); err != nil || got.StatusCode != 200 {
+		t.Fatalf("JournalMigrate() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	if got, err := PeriodLockPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"month": "2025-02"}}, deps); err != nil || got.StatusCode != 200 {
+		t.Fatalf("PeriodLockPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	// Equipment is only used in the locked month, so neither kind of merge may move it
+	for _, body := range []string{
+		`{"sourceId": "equipment", "targetId": "misc"}`,
+		`{"sourceId": "equipment", "targetId": "misc", "rewriteTransactions": true}`,
+	} {
+		got, err := LedgerCategoriesMerge(ctx, events.APIGatewayProxyRequest{Body: body}, deps)
+		if err != nil || got.StatusCode != 409 || !strings.Contains(got.Body, "2025-02 is locked") {
+			t.Errorf("LedgerCategoriesMerge(%s) = %d %s, %v", body, got.StatusCode, got.Body, err)
+		}
+	}
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findCategory(categories, "equipment") < 0 {
+		t.Errorf("refused merge removed the source category: %+v", categories)
+	}
+	february, _, err := loadJournalMonth(ctx, deps, "2025-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !journalMonthUsesAccount(february, "equipment") {
+		t.Errorf("refused merge moved locked journal lines: %+v", february.Entries)
+	}
+
+	// Event Fee is only used in an open month
+	got, err := LedgerCategoriesMerge(ctx, events.APIGatewayProxyRequest{Body: `{"sourceId": "event-fee", "targetId": "membership", "rewriteTransactions": true}`}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Errorf("LedgerCategoriesMerge() in open month = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+}
+
+func TestRegisterPeriodLocks(t *testing.T) {
+	ledgers := grantTestLedgers()
+	ledgers[0].Transactions = append(ledgers[0].Transactions, Transaction{ID: "entries", Date: "2025-03-08", Category: "Race entries", Description: "Entries", Amount: 30000, TrustFundID: "entries"})
+	recalculateLedger(&ledgers[0])
+	deps := Dependencies{Data: newTestDataProvider(t, ledgers...), Headers: DefaultHeaders()}
+	ctx := context.Background()
+	if err := saveTrustFunds(ctx, deps, []TrustFund{{ID: "entries", Name: "Open entries", HeldFor: "Ballarat CC"}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	call := func(handler HandlerFunc, request events.APIGatewayProxyRequest, wantStatus int) string {
+		t.Helper()
+		got, err := handler(ctx, request, deps)
+		if err != nil || got.StatusCode != wantStatus {
+			t.Fatalf("status = %d %s, %v; want %d", got.StatusCode, got.Body, err, wantStatus)
+		}
+		return got.Body
+	}
+	var grant grantView
+	body := call(GrantsPost, events.APIGatewayProxyRequest{Body: `{"grantor": "Council", "received": [{"ledgerType": "BANK", "transactionId": "grant"}]}`}, 200)
+	if err := json.Unmarshal([]byte(body), &grant); err != nil {
+		t.Fatal(err)
+	}
+	call(PeriodLockPost, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"financialYear": "2025"}}, 200)
+
+	// Saving the grant unchanged or with spending after the locked year is allowed
+	call(GrantsPost, events.APIGatewayProxyRequest{Body: fmt.Sprintf(`{"id": %q, "grantor": "Council", "received": [{"ledgerType": "BANK", "transactionId": "grant"}]}`, grant.ID)}, 200)
+	call(GrantsPost, events.APIGatewayProxyRequest{Body: fmt.Sprintf(`{"id": %q, "grantor": "Council", "received": [{"ledgerType": "BANK", "transactionId": "grant"}],
+		"expenditure": [{"date": "2025-08-01", "amount": "100.00", "description": "Coaching"}]}`, grant.ID)}, 200)
+	body = call(GrantsPost, events.APIGatewayProxyRequest{Body: fmt.Sprintf(`{"id": %q, "grantor": "Council", "received": [{"ledgerType": "BANK", "transactionId": "grant"}],
+		"expenditure": [{"ledgerType": "BANK", "transactionId": "bikes"}]}`, grant.ID)}, 409)
+	if !strings.Contains(body, `"lockedMonths":["2025-03"]`) {
+		t.Errorf("GrantsPost() in locked year = %s", body)
+	}
+	call(GrantsDelete, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": grant.ID}}, 409)
+
+	call(LoansPost, events.APIGatewayProxyRequest{Body: `{"lender": "Bank", "principal": "1000.00", "termMonths": 12, "startDate": "2025-01-01"}`}, 409)
+	call(LoansPost, events.APIGatewayProxyRequest{Body: `{"lender": "Bank", "principal": "1000.00", "termMonths": 12, "startDate": "2025-08-01"}`}, 200)
+
+	// Renaming a fund relabels its trust money in the locked year
+	call(TrustFundsPost, events.APIGatewayProxyRequest{Body: `{"id": "entries", "name": "Ballarat Open entries", "heldFor": "Ballarat CC"}`}, 409)
+	call(TrustFundsPost, events.APIGatewayProxyRequest{Body: `{"id": "entries", "name": "Open entries", "heldFor": "Ballarat CC", "description": "Entries collected for Ballarat"}`}, 200)
+}
@@ -0,0 +1,342 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const reconciliationPrefix = "reconciliation/"
+
+// Reconciliation statuses. A reconciled month is locked against ledger writes until unlocked.
+const (
+	reconciliationInProgress = "in-progress"
+	reconciliationReconciled = "reconciled"
+)
+
+// Reconciliation records one ledger month checked against the bank statement
+type Reconciliation struct {
+	Type             string      `json:"type"`
+	Month            string      `json:"month"`
+	StatementDate    string      `json:"statementDate"`
+	StatementBalance money.Cents `json:"statementBalance"`
+	// Cleared are the transactions, from this month or earlier ones, that appear on this statement
+	Cleared      []string `json:"cleared"`
+	Status       string   `json:"status"`
+	ReconciledAt string   `json:"reconciledAt,omitempty"`
+	Version      int64    `json:"version"`
+}
+
+type reconciliationRequest struct {
+	StatementDate    string      `json:"statementDate"`
+	StatementBalance money.Cents `json:"statementBalance"`
+	Cleared          []string    `json:"cleared"`
+}
+
+// reconciliationReport compares the statement with the ledger. The ledger balance less the
+// outstanding items is what the statement should show; any difference is unexplained.
+type reconciliationReport struct {
+	Reconciliation
+	LedgerBalance    money.Cents   `json:"ledgerBalance"`
+	Outstanding      []Transaction `json:"outstanding"`
+	OutstandingTotal money.Cents   `json:"outstandingTotal"`
+	ClearedBalance   money.Cents   `json:"clearedBalance"`
+	Difference       money.Cents   `json:"difference"`
+}
+
+func reconciliationPath(ledgerType, month string) string {
+	return fmt.Sprintf("%s%s/%s.json", reconciliationPrefix, ledgerType, month)
+}
+
+// loadReconciliations reads every reconciliation of one ledger type, ordered by month
+func loadReconciliations(ctx context.Context, deps Dependencies, ledgerType string) ([]Reconciliation, error) {
+	files, err := deps.Data.List(ctx, reconciliationPrefix+ledgerType)
+	if err != nil {
+		return nil, err
+	}
+	reconciliations := []Reconciliation{}
+	for _, file := range files {
+		if file.IsDir || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		content, err := deps.Data.Get(ctx, file.Path)
+		if err != nil {
+			return nil, err
+		}
+		var reconciliation Reconciliation
+		if err := json.Unmarshal(content, &reconciliation); err != nil {
+			return nil, err
+		}
+		reconciliations = append(reconciliations, reconciliation)
+	}
+	sort.Slice(reconciliations, func(i, j int) bool {
+		return reconciliations[i].Month < reconciliations[j].Month
+	})
+	return reconciliations, nil
+}
+
+// loadReconciliation reads one month's reconciliation and its ETag. A month not yet started is
+// returned in progress with an empty ETag.
+func loadReconciliation(ctx context.Context, deps Dependencies, ledgerType, month string) (Reconciliation, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, reconciliationPath(ledgerType, month))
+	if errors.Is(err, storage.ErrNotFound) {
+		return Reconciliation{Type: ledgerType, Month: month, Cleared: []string{}, Status: reconciliationInProgress}, "", nil
+	}
+	if err != nil {
+		return Reconciliation{}, "", err
+	}
+	var reconciliation Reconciliation
+	if err := json.Unmarshal(content, &reconciliation); err != nil {
+		return Reconciliation{}, "", err
+	}
+	return reconciliation, etag, nil
+}
+
+func saveReconciliation(ctx context.Context, deps Dependencies, reconciliation Reconciliation, etag string) error {
+	reconciliation.Version++
+	content, _ := json.Marshal(reconciliation)
+	return deps.Data.SaveIfMatch(ctx, reconciliationPath(reconciliation.Type, reconciliation.Month), content, etag)
+}
+
+// lockedLedgerMonths returns the locked months of one ledger type with the reason for each lock
+func lockedLedgerMonths(ctx context.Context, deps Dependencies, ledgerType string) (map[string]string, error) {
+	reconciliations, err := loadReconciliations(ctx, deps, ledgerType)
+	if err != nil {
+		return nil, err
+	}
+	locked := map[string]string{}
+	for _, reconciliation := range reconciliations {
+		if reconciliation.Status == reconciliationReconciled {
+			locked[reconciliation.Month] = "reconciled"
+		}
+	}
+	return locked, nil
+}
+
+// buildReconciliationReport works out the outstanding items for reconciliation from ledgers,
+// which are sorted by month. A transaction is outstanding if it is dated in or before the month
+// and was not cleared by this reconciliation or an earlier one.
+func buildReconciliationReport(reconciliation Reconciliation, ledgers []MonthlyLedger, earlier []Reconciliation) reconciliationReport {
+	cleared := map[string]bool{}
+	for _, previous := range earlier {
+		if previous.Month < reconciliation.Month {
+			for _, id := range previous.Cleared {
+				cleared[id] = true
+			}
+		}
+	}
+	for _, id := range reconciliation.Cleared {
+		cleared[id] = true
+	}
+
+	report := reconciliationReport{
+		Reconciliation: reconciliation,
+		LedgerBalance:  ledgerMonthForUpdate(ledgers, reconciliation.Type, reconciliation.Month).ClosingBalance,
+		Outstanding:    []Transaction{},
+	}
+	for _, ledger := range ledgers {
+		if ledger.Month > reconciliation.Month {
+			break
+		}
+		for _, tx := range ledger.Transactions {
+			if !cleared[tx.ID] {
+				report.Outstanding = append(report.Outstanding, tx)
+				report.OutstandingTotal += tx.Amount
+			}
+		}
+	}
+	report.ClearedBalance = report.LedgerBalance - report.OutstandingTotal
+	report.Difference = reconciliation.StatementBalance - report.ClearedBalance
+	return report
+}
+
+// validateReconciliation checks the statement details and that every cleared transaction is in
+// the ledger by the end of the month and was not cleared by an earlier reconciliation
+func validateReconciliation(reconciliation Reconciliation, ledgers []MonthlyLedger, earlier []Reconciliation) []ValidationViolation {
+	violations := []ValidationViolation{}
+	if _, err := time.Parse("2006-01-02", reconciliation.StatementDate); err != nil || len(reconciliation.StatementDate) != len("2006-01-02") {
+		violations = append(violations, ValidationViolation{Month: reconciliation.Month, Field: "statementDate", Message: "Statement date must be YYYY-MM-DD"})
+	}
+
+	known := map[string]bool{}
+	for _, ledger := range ledgers {
+		if ledger.Month > reconciliation.Month {
+			break
+		}
+		for _, tx := range ledger.Transactions {
+			known[tx.ID] = true
+		}
+	}
+	clearedIn := map[string]string{}
+	for _, previous := range earlier {
+		if previous.Month < reconciliation.Month {
+			for _, id := range previous.Cleared {
+				clearedIn[id] = previous.Month
+			}
+		}
+	}
+	for i, id := range reconciliation.Cleared {
+		field := fmt.Sprintf("cleared[%d]", i)
+		switch {
+		case !known[id]:
+			violations = append(violations, ValidationViolation{Month: reconciliation.Month, TransactionID: id, Field: field, Message: fmt.Sprintf("Transaction is not in the %s ledger by %s", reconciliation.Type, reconciliation.Month)})
+		case clearedIn[id] != "":
+			violations = append(violations, ValidationViolation{Month: reconciliation.Month, TransactionID: id, Field: field, Message: fmt.Sprintf("Transaction was already cleared in %s", clearedIn[id])})
+		}
+	}
+	return violations
+}
+
+// ledgerMonthParams reads the type and month query parameters naming one ledger month
+func ledgerMonthParams(request events.APIGatewayProxyRequest, headers map[string]string) (string, string, *events.APIGatewayProxyResponse) {
+	ledgerType := strings.ToUpper(strings.TrimSpace(request.QueryStringParameters["type"]))
+	if ledgerType == "" {
+		return "", "", &events.APIGatewayProxyResponse{Body: `{"error": "Type is required"}`, StatusCode: 400, Headers: headers}
+	}
+	month, badRequest := monthQueryParam(request, headers)
+	if badRequest != nil {
+		return "", "", badRequest
+	}
+	return ledgerType, month, nil
+}
+
+// loadReconciliationContext reads everything a reconciliation report needs for one month
+func loadReconciliationContext(ctx context.Context, deps Dependencies, ledgerType, month string) (Reconciliation, string, []MonthlyLedger, []Reconciliation, error) {
+	reconciliation, etag, err := loadReconciliation(ctx, deps, ledgerType, month)
+	if err != nil {
+		return Reconciliation{}, "", nil, nil, err
+	}
+	ledgers, err := loadLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return Reconciliation{}, "", nil, nil, err
+	}
+	all, err := loadReconciliations(ctx, deps, ledgerType)
+	if err != nil {
+		return Reconciliation{}, "", nil, nil, err
+	}
+	return reconciliation, etag, ledgers, all, nil
+}
+
+func reconciliationReportResponse(report reconciliationReport, headers map[string]string) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(report)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: headers}
+}
+
+// LedgerReconciliationGet reports the reconciliation of one ledger month against its statement
+func LedgerReconciliationGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	ledgerType, month, badRequest := ledgerMonthParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	reconciliation, _, ledgers, all, err := loadReconciliationContext(ctx, deps, ledgerType, month)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	return reconciliationReportResponse(buildReconciliationReport(reconciliation, ledgers, all), deps.Headers), nil
+}
+
+// LedgerReconciliationPost records the statement closing balance and date and the cleared
+// transactions for a month that is not yet reconciled, and returns the updated report
+func LedgerReconciliationPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	ledgerType, month, badRequest := ledgerMonthParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	var body reconciliationRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		fmt.Printf("Invalid reconciliation body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	reconciliation, etag, ledgers, all, err := loadReconciliationContext(ctx, deps, ledgerType, month)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if reconciliation.Status == reconciliationReconciled {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Month is reconciled; unlock it before changing the reconciliation"}`, StatusCode: 409, Headers: deps.Headers}, nil
+	}
+
+	reconciliation.StatementDate = body.StatementDate
+	reconciliation.StatementBalance = body.StatementBalance
+	reconciliation.Cleared = []string{}
+	seen := map[string]bool{}
+	for _, id := range body.Cleared {
+		if !seen[id] {
+			seen[id] = true
+			reconciliation.Cleared = append(reconciliation.Cleared, id)
+		}
+	}
+	if violations := validateReconciliation(reconciliation, ledgers, all); len(violations) > 0 {
+		return newValidationErrorResponse("Reconciliation validation failed", violations, deps.Headers), nil
+	}
+	if err := saveReconciliation(ctx, deps, reconciliation, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	reconciliation.Version++
+
+	report := buildReconciliationReport(reconciliation, ledgers, all)
+	fmt.Printf("Saved %s %s reconciliation with %d cleared, difference %s\n", ledgerType, month, len(reconciliation.Cleared), report.Difference.Format())
+	return reconciliationReportResponse(report, deps.Headers), nil
+}
+
+// LedgerReconciliationComplete marks a month reconciled, which locks it against ledger writes.
+// The statement must agree with the ledger once outstanding items are allowed for.
+func LedgerReconciliationComplete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	ledgerType, month, badRequest := ledgerMonthParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	reconciliation, etag, ledgers, all, err := loadReconciliationContext(ctx, deps, ledgerType, month)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if etag == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Enter the statement balance before reconciling"}`, StatusCode: 409, Headers: deps.Headers}, nil
+	}
+	report := buildReconciliationReport(reconciliation, ledgers, all)
+	if report.Difference != 0 {
+		body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Statement differs from the ledger by %s", report.Difference.Format())})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 422, Headers: deps.Headers}, nil
+	}
+
+	reconciliation.Status = reconciliationReconciled
+	reconciliation.ReconciledAt = time.Now().UTC().Format(time.RFC3339)
+	if err := saveReconciliation(ctx, deps, reconciliation, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	reconciliation.Version++
+	report.Reconciliation = reconciliation
+	fmt.Printf("Reconciled and locked %s %s\n", ledgerType, month)
+	return reconciliationReportResponse(report, deps.Headers), nil
+}
+
+// LedgerReconciliationUnlock reopens a reconciled month so its ledger can be changed again
+func LedgerReconciliationUnlock(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	ledgerType, month, badRequest := ledgerMonthParams(request, deps.Headers)
+	if badRequest != nil {
+		return *badRequest, nil
+	}
+	reconciliation, etag, ledgers, all, err := loadReconciliationContext(ctx, deps, ledgerType, month)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if reconciliation.Status != reconciliationReconciled {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Month is not reconciled"}`, StatusCode: 409, Headers: deps.Headers}, nil
+	}
+
+	reconciliation.Status = reconciliationInProgress
+	reconciliation.ReconciledAt = ""
+	if err := saveReconciliation(ctx, deps, reconciliation, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	reconciliation.Version++
+	fmt.Printf("Unlocked %s %s\n", ledgerType, month)
+	return reconciliationReportResponse(buildReconciliationReport(reconciliation, ledgers, all), deps.Headers), nil
+}
@@ -0,0 +1,151 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+func TestBuildReconciliationReport(t *testing.T) {
+	ledgers := journalTestLedgers()
+	earlier := []Reconciliation{{Type: "BANK", Month: "2025-01", Cleared: []string{"entries"}, Status: reconciliationReconciled}}
+	tests := []struct {
+		name            string
+		reconciliation  Reconciliation
+		wantOutstanding []string
+		wantDifference  money.Cents
+	}{
+		{
+			name:            "Everything cleared",
+			reconciliation:  Reconciliation{Type: "BANK", Month: "2025-02", StatementBalance: 11000, Cleared: []string{"hall", "cones"}},
+			wantOutstanding: []string{},
+			wantDifference:  0,
+		},
+		{
+			name:            "Unpresented cheque",
+			reconciliation:  Reconciliation{Type: "BANK", Month: "2025-02", StatementBalance: 11500, Cleared: []string{"cones"}},
+			wantOutstanding: []string{"hall"},
+			wantDifference:  0,
+		},
+		{
+			name:            "Unexplained difference",
+			reconciliation:  Reconciliation{Type: "BANK", Month: "2025-02", StatementBalance: 11250, Cleared: []string{"hall", "cones"}},
+			wantOutstanding: []string{},
+			wantDifference:  250,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildReconciliationReport(tt.reconciliation, ledgers, earlier)
+			outstanding := []string{}
+			for _, tx := range got.Outstanding {
+				outstanding = append(outstanding, tx.ID)
+			}
+			if got.LedgerBalance != 11000 || !reflect.DeepEqual(outstanding, tt.wantOutstanding) || got.Difference != tt.wantDifference {
+				t.Errorf("buildReconciliationReport() = %+v, want outstanding %v and difference %s", got, tt.wantOutstanding, tt.wantDifference)
+			}
+		})
+	}
+}
+
+func TestValidateReconciliation(t *testing.T) {
+	ledgers := journalTestLedgers()
+	earlier := []Reconciliation{{Type: "BANK", Month: "2025-01", Cleared: []string{"entries"}}}
+	reconciliation := Reconciliation{Type: "BANK", Month: "2025-01", StatementDate: "31/01/2025", Cleared: []string{"hall", "cones"}}
+	want := []ValidationViolation{
+		{Month: "2025-01", Field: "statementDate", Message: "Statement date must be YYYY-MM-DD"},
+		{Month: "2025-01", TransactionID: "cones", Field: "cleared[1]", Message: "Transaction is not in the BANK ledger by 2025-01"},
+	}
+	if got := validateReconciliation(reconciliation, ledgers, earlier); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateReconciliation() = %+v, want %+v", got, want)
+	}
+
+	reconciliation = Reconciliation{Type: "BANK", Month: "2025-02", StatementDate: "2025-02-28", Cleared: []string{"entries"}}
+	want = []ValidationViolation{
+		{Month: "2025-02", TransactionID: "entries", Field: "cleared[0]", Message: "Transaction was already cleared in 2025-01"},
+	}
+	if got := validateReconciliation(reconciliation, ledgers, earlier); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateReconciliation() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLedgerReconciliationLock(t *testing.T) {
+	ledgers := journalTestLedgers()
+	prov := newTestDataProvider(t, ledgers...)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+	january := map[string]string{"type": "bank", "month": "2025-01"}
+
+	got, err := LedgerReconciliationComplete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: january}, deps)
+	if err != nil || got.StatusCode != 409 {
+		t.Errorf("LedgerReconciliationComplete() before statement = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	// The hall hire has not reached the statement, so the statement is $5 higher than the ledger
+	got, err = LedgerReconciliationPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: january, Body: `{"statementDate": "2025-01-31", "statementBalance": "120.00", "cleared": ["entries"]}`}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerReconciliationPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	got, err = LedgerReconciliationComplete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: january}, deps)
+	if err != nil || got.StatusCode != 422 {
+		t.Fatalf("LedgerReconciliationComplete() with difference = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	got, err = LedgerReconciliationPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: january, Body: `{"statementDate": "2025-01-31", "statementBalance": "125.00", "cleared": ["entries"]}`}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerReconciliationPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	got, err = LedgerReconciliationComplete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: january}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerReconciliationComplete() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var report reconciliationReport
+	if err := json.Unmarshal([]byte(got.Body), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != reconciliationReconciled || report.ReconciledAt == "" || report.OutstandingTotal != -500 {
+		t.Errorf("report = %+v", report)
+	}
+
+	// Editing January directly is refused, and so is an edit that would roll forward into it
+	post := func(ledger MonthlyLedger) events.APIGatewayProxyResponse {
+		t.Helper()
+		recalculateLedger(&ledger)
+		got, err := LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, []MonthlyLedger{ledger})}, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	edited := ledgers[0]
+	edited.Transactions = append(edited.Transactions, Transaction{ID: "late", Date: "2025-01-20", Category: "Membership", Description: "Late subs", Amount: 1000})
+	december := MonthlyLedger{PK: "LEDGER#BANK#2024-12", Month: "2024-12", Type: "BANK", OpeningBalance: 10000, Transactions: []Transaction{
+		{ID: "deposit", Date: "2024-12-15", Category: "Membership", Description: "Subs", Amount: 1000},
+	}}
+	for _, ledger := range []MonthlyLedger{edited, december} {
+		got := post(ledger)
+		var body ledgerSaveErrorBody
+		if err := json.Unmarshal([]byte(got.Body), &body); err != nil {
+			t.Fatal(err)
+		}
+		if got.StatusCode != 409 || !reflect.DeepEqual(body.LockedMonths, []string{"2025-01"}) || len(body.SavedMonths) != 0 {
+			t.Errorf("LedgerPost(%s) on locked month = %d %s", ledger.Month, got.StatusCode, got.Body)
+		}
+	}
+	got, err = LedgerReconciliationPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: january, Body: `{"statementDate": "2025-01-31", "statementBalance": "125.00"}`}, deps)
+	if err != nil || got.StatusCode != 409 {
+		t.Errorf("LedgerReconciliationPost() on reconciled month = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	got, err = LedgerReconciliationUnlock(ctx, events.APIGatewayProxyRequest{QueryStringParameters: january}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerReconciliationUnlock() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	if got := post(edited); got.StatusCode != 200 {
+		t.Errorf("LedgerPost() after unlock = %d %s", got.StatusCode, got.Body)
+	}
+}
@@ -0,0 +1,387 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/auth"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const reimbursementsPrefix = "reimbursements/"
+
+// reimbursementCategory is the category of the ledger transaction that pays a claim
+const reimbursementCategory = "Reimbursement"
+
+// Reimbursement statuses. A claim is submitted, then approved or rejected by the treasurer, and
+// an approved claim is paid.
+const (
+	reimbursementSubmitted = "submitted"
+	reimbursementApproved  = "approved"
+	reimbursementRejected  = "rejected"
+	reimbursementPaid      = "paid"
+)
+
+// Reimbursement is a claim by a member for money they spent on the club's behalf
+type Reimbursement struct {
+	ID          string      `json:"id"`
+	Claimant    string      `json:"claimant"`
+	Amount      money.Cents `json:"amount"`
+	Category    string      `json:"category"`
+	Description string      `json:"description"`
+	// ReceiptPath is the receipt's path in the documents bucket
+	ReceiptPath string `json:"receiptPath,omitempty"`
+	Status      string `json:"status"`
+	SubmittedAt string `json:"submittedAt"`
+	SubmittedBy string `json:"submittedBy,omitempty"`
+	ReviewedAt  string `json:"reviewedAt,omitempty"`
+	ReviewedBy  string `json:"reviewedBy,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+	// Payment is the ledger transaction that paid the claim
+	Payment *ReimbursementPayment `json:"payment,omitempty"`
+	Version int64                 `json:"version"`
+}
+
+type ReimbursementPayment struct {
+	LedgerType    string `json:"ledgerType"`
+	Month         string `json:"month"`
+	TransactionID string `json:"transactionId"`
+	PaidAt        string `json:"paidAt"`
+}
+
+type reimbursementReviewRequest struct {
+	// Decision is approve or reject
+	Decision string `json:"decision"`
+	Comment  string `json:"comment"`
+}
+
+type reimbursementPayRequest struct {
+	LedgerType string `json:"ledgerType"`
+	Date       string `json:"date"`
+}
+
+func reimbursementPath(id string) string {
+	return reimbursementsPrefix + id + ".json"
+}
+
+// loadReimbursements reads every claim, newest first
+func loadReimbursements(ctx context.Context, deps Dependencies) ([]Reimbursement, error) {
+	files, err := deps.Data.List(ctx, strings.TrimSuffix(reimbursementsPrefix, "/"))
+	if err != nil {
+		return nil, err
+	}
+	claims := []Reimbursement{}
+	for _, file := range files {
+		if file.IsDir || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		content, err := deps.Data.Get(ctx, file.Path)
+		if err != nil {
+			return nil, err
+		}
+		var claim Reimbursement
+		if err := json.Unmarshal(content, &claim); err != nil {
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+	sort.SliceStable(claims, func(i, j int) bool {
+		return claims[i].SubmittedAt > claims[j].SubmittedAt
+	})
+	return claims, nil
+}
+
+func loadReimbursement(ctx context.Context, deps Dependencies, id string) (Reimbursement, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, reimbursementPath(id))
+	if err != nil {
+		return Reimbursement{}, "", err
+	}
+	var claim Reimbursement
+	if err := json.Unmarshal(content, &claim); err != nil {
+		return Reimbursement{}, "", err
+	}
+	return claim, etag, nil
+}
+
+func saveReimbursement(ctx context.Context, deps Dependencies, claim Reimbursement, etag string) error {
+	claim.Version++
+	content, _ := json.Marshal(claim)
+	return deps.Data.SaveIfMatch(ctx, reimbursementPath(claim.ID), content, etag)
+}
+
+// validateReimbursement checks a new claim and gives its category the name used in the chart
+func validateReimbursement(claim *Reimbursement, categories []Category) []ValidationViolation {
+	violations := []ValidationViolation{}
+	if claim.Claimant == "" {
+		violations = append(violations, ValidationViolation{Field: "claimant", Message: "Claimant is required"})
+	}
+	if claim.Amount <= 0 {
+		violations = append(violations, ValidationViolation{Field: "amount", Message: "Amount must be positive"})
+	}
+	if claim.Description == "" {
+		violations = append(violations, ValidationViolation{Field: "description", Message: "Description is required"})
+	}
+	if category, ok := newCategoryIndex(categories).lookup(claim.Category); !ok || !category.Active {
+		violations = append(violations, ValidationViolation{Field: "category", Message: fmt.Sprintf("Category %q is not an active category", claim.Category)})
+	} else {
+		claim.Category = category.Name
+	}
+	if claim.ReceiptPath != "" && !validAttachmentPath(claim.ReceiptPath) {
+		violations = append(violations, ValidationViolation{Field: "receiptPath", Message: "Receipt path must be within the documents store"})
+	}
+	return violations
+}
+
+// reimbursementFromRequest reads the id parameter and loads that claim
+func reimbursementFromRequest(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (Reimbursement, string, *events.APIGatewayProxyResponse) {
+	id := request.QueryStringParameters["id"]
+	if id == "" {
+		return Reimbursement{}, "", &events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}
+	}
+	claim, etag, err := loadReimbursement(ctx, deps, id)
+	if err != nil {
+		response := storageErrorResponse(err, deps.Headers)
+		return Reimbursement{}, "", &response
+	}
+	return claim, etag, nil
+}
+
+// reimbursementPaymentResponse is a paid claim, flagged when its payment was saved but the journal
+// could not be updated
+type reimbursementPaymentResponse struct {
+	Reimbursement
+	JournalStale bool `json:"journalStale,omitempty"`
+}
+
+func reimbursementResponse(claim Reimbursement, headers map[string]string) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(claim)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: headers}
+}
+
+// ReimbursementsGet lists claims, newest first, optionally only those with the status parameter
+func ReimbursementsGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	claims, err := loadReimbursements(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if status := request.QueryStringParameters["status"]; status != "" {
+		filtered := []Reimbursement{}
+		for _, claim := range claims {
+			if claim.Status == status {
+				filtered = append(filtered, claim)
+			}
+		}
+		claims = filtered
+	}
+	body, _ := json.Marshal(claims)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// ReimbursementsPost submits a claim for the treasurer to review. A receipt path must name a
+// document that has already been uploaded.
+func ReimbursementsPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var body Reimbursement
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		fmt.Printf("Invalid reimbursement body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	claim := Reimbursement{
+		Claimant:    strings.TrimSpace(body.Claimant),
+		Amount:      body.Amount,
+		Category:    body.Category,
+		Description: strings.TrimSpace(body.Description),
+		ReceiptPath: strings.TrimPrefix(strings.TrimSpace(body.ReceiptPath), "/"),
+		Status:      reimbursementSubmitted,
+		SubmittedAt: time.Now().UTC().Format(time.RFC3339),
+		SubmittedBy: auth.UserFromAuthorizer(request.RequestContext.Authorizer),
+	}
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	violations := validateReimbursement(&claim, categories)
+	if claim.ReceiptPath != "" && len(violations) == 0 {
+		receipt, err := deps.Storage.GetReader(ctx, claim.ReceiptPath)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			violations = append(violations, ValidationViolation{Field: "receiptPath", Message: fmt.Sprintf("Receipt %s has not been uploaded", claim.ReceiptPath)})
+		case err != nil:
+			return storageErrorResponse(err, deps.Headers), nil
+		default:
+			receipt.Close()
+		}
+	}
+	if len(violations) > 0 {
+		return newValidationErrorResponse("Reimbursement validation failed", violations, deps.Headers), nil
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return errorResponse(err, deps.Headers), nil
+	}
+	claim.ID = id
+	if err := saveReimbursement(ctx, deps, claim, ""); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	claim.Version++
+	fmt.Printf("Submitted reimbursement %s for %s by %s\n", claim.ID, claim.Amount.Format(), claim.Claimant)
+	return reimbursementResponse(claim, deps.Headers), nil
+}
+
+// ReimbursementsReview approves or rejects a submitted claim. Rejections need a comment saying why.
+func ReimbursementsReview(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var body reimbursementReviewRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		fmt.Printf("Invalid reimbursement review body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	body.Comment = strings.TrimSpace(body.Comment)
+	var status string
+	switch strings.ToLower(body.Decision) {
+	case "approve":
+		status = reimbursementApproved
+	case "reject":
+		if body.Comment == "" {
+			return events.APIGatewayProxyResponse{Body: `{"error": "A comment is required to reject a claim"}`, StatusCode: 400, Headers: deps.Headers}, nil
+		}
+		status = reimbursementRejected
+	default:
+		return events.APIGatewayProxyResponse{Body: `{"error": "Decision must be approve or reject"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+
+	claim, etag, failed := reimbursementFromRequest(ctx, request, deps)
+	if failed != nil {
+		return *failed, nil
+	}
+	if claim.Status != reimbursementSubmitted {
+		body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Claim is already %s", claim.Status)})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+	}
+	claim.Status = status
+	claim.Comment = body.Comment
+	claim.ReviewedAt = time.Now().UTC().Format(time.RFC3339)
+	claim.ReviewedBy = auth.UserFromAuthorizer(request.RequestContext.Authorizer)
+	if err := saveReimbursement(ctx, deps, claim, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	claim.Version++
+	fmt.Printf("Reimbursement %s %s\n", claim.ID, claim.Status)
+	return reimbursementResponse(claim, deps.Headers), nil
+}
+
+// ReimbursementsPay pays an approved claim from the chosen ledger by adding a negative
+// Reimbursement transaction linked to the claim. If the claim cannot then be marked paid, the
+// transaction is taken out again so the claim is not paid twice.
+func ReimbursementsPay(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var body reimbursementPayRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		fmt.Printf("Invalid reimbursement payment body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	ledgerType := strings.ToUpper(strings.TrimSpace(body.LedgerType))
+	if ledgerType == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Ledger type is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	if body.Date == "" {
+		body.Date = time.Now().UTC().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", body.Date); err != nil || len(body.Date) != len("2006-01-02") {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Date must be YYYY-MM-DD"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+
+	claim, etag, failed := reimbursementFromRequest(ctx, request, deps)
+	if failed != nil {
+		return *failed, nil
+	}
+	if claim.Status != reimbursementApproved {
+		body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Only approved claims can be paid; this claim is %s", claim.Status)})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+	}
+
+	transactionID, err := newUUID()
+	if err != nil {
+		return errorResponse(err, deps.Headers), nil
+	}
+	month := body.Date[:len("2006-01")]
+	stored, err := loadLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	ledger := ledgerMonthForUpdate(stored, ledgerType, month)
+	ledger.Transactions = append(ledger.Transactions, Transaction{
+		ID:              transactionID,
+		Date:            body.Date,
+		Category:        reimbursementCategory,
+		Description:     fmt.Sprintf("Reimbursement to %s: %s", claim.Claimant, claim.Description),
+		Amount:          -claim.Amount,
+		ReimbursementID: claim.ID,
+	})
+	if claim.ReceiptPath != "" {
+		ledger.Transactions[len(ledger.Transactions)-1].Attachments = []Attachment{{Path: claim.ReceiptPath}}
+	}
+	recalculateLedger(&ledger)
+	if violations := validateLedgers(ledgerType, []MonthlyLedger{ledger}); len(violations) > 0 {
+		return validationErrorResponse(violations, deps.Headers), nil
+	}
+	result, err := saveLedgers(ctx, deps, ledgerType, []MonthlyLedger{ledger}, true)
+	if err != nil {
+		return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
+	}
+
+	claim.Status = reimbursementPaid
+	claim.Payment = &ReimbursementPayment{LedgerType: ledgerType, Month: month, TransactionID: transactionID, PaidAt: time.Now().UTC().Format(time.RFC3339)}
+	if err := saveReimbursement(ctx, deps, claim, etag); err != nil {
+		if undoErr := removeReimbursementTransaction(ctx, deps, ledgerType, month, transactionID); undoErr != nil {
+			fmt.Printf("Reimbursement %s paid in %s %s but not marked paid: %v\n", claim.ID, ledgerType, month, undoErr)
+		}
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	claim.Version++
+	fmt.Printf("Paid reimbursement %s of %s from %s\n", claim.ID, claim.Amount.Format(), ledgerType)
+	responseBody, _ := json.Marshal(reimbursementPaymentResponse{Reimbursement: claim, JournalStale: result.JournalStale})
+	return events.APIGatewayProxyResponse{Body: string(responseBody), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// reopenReimbursement sets a paid claim back to approved once its payment has been deleted from
+// the ledger, so that it can be paid again
+func reopenReimbursement(ctx context.Context, deps Dependencies, id string) error {
+	claim, etag, err := loadReimbursement(ctx, deps, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if claim.Status != reimbursementPaid {
+		return nil
+	}
+	claim.Status = reimbursementApproved
+	claim.Payment = nil
+	return saveReimbursement(ctx, deps, claim, etag)
+}
+
+// removeReimbursementTransaction takes a payment back out of its ledger month
+func removeReimbursementTransaction(ctx context.Context, deps Dependencies, ledgerType, month, transactionID string) error {
+	stored, err := loadLedgerMonths(ctx, deps, ledgerType)
+	if err != nil {
+		return err
+	}
+	ledger := ledgerMonthForUpdate(stored, ledgerType, month)
+	kept := make([]Transaction, 0, len(ledger.Transactions))
+	for _, tx := range ledger.Transactions {
+		if tx.ID != transactionID {
+			kept = append(kept, tx)
+		}
+	}
+	ledger.Transactions = kept
+	recalculateLedger(&ledger)
+	_, err = saveLedgers(ctx, deps, ledgerType, []MonthlyLedger{ledger}, true)
+	return err
+}
@@ -0,0 +1,143 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+func TestValidateReimbursement(t *testing.T) {
+	tests := []struct {
+		name         string
+		claim        Reimbursement
+		want         []ValidationViolation
+		wantCategory string
+	}{
+		{
+			name:         "Valid",
+			claim:        Reimbursement{Claimant: "Sam", Amount: 4550, Category: "equipment", Description: "Cones"},
+			want:         []ValidationViolation{},
+			wantCategory: "Equipment",
+		},
+		{
+			name:  "Missing details",
+			claim: Reimbursement{Amount: -100, Category: "Snacks", ReceiptPath: "../data/categories.json"},
+			want: []ValidationViolation{
+				{Field: "claimant", Message: "Claimant is required"},
+				{Field: "amount", Message: "Amount must be positive"},
+				{Field: "description", Message: "Description is required"},
+				{Field: "category", Message: `Category "Snacks" is not an active category`},
+				{Field: "receiptPath", Message: "Receipt path must be within the documents store"},
+			},
+			wantCategory: "Snacks",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := tt.claim
+			if got := validateReimbursement(&claim, defaultCategories); !reflect.DeepEqual(got, tt.want) || claim.Category != tt.wantCategory {
+				t.Errorf("validateReimbursement() = %+v with category %q, want %+v with %q", got, claim.Category, tt.want, tt.wantCategory)
+			}
+		})
+	}
+}
+
+func TestReimbursementWorkflow(t *testing.T) {
+	ctx := context.Background()
+	documents, err := storage.NewLocalStorageProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := documents.Save(ctx, "receipts/cones.pdf", []byte("%PDF")); err != nil {
+		t.Fatal(err)
+	}
+	deps := Dependencies{Storage: documents, Data: newTestDataProvider(t), Headers: DefaultHeaders()}
+	committee := events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{"claims": map[string]interface{}{"email": "sam@example.com"}}}
+
+	decode := func(got events.APIGatewayProxyResponse, err error, wantStatus int) Reimbursement {
+		t.Helper()
+		if err != nil || got.StatusCode != wantStatus {
+			t.Fatalf("got %d %s, %v, want %d", got.StatusCode, got.Body, err, wantStatus)
+		}
+		var claim Reimbursement
+		if wantStatus == 200 {
+			if err := json.Unmarshal([]byte(got.Body), &claim); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return claim
+	}
+
+	body := `{"claimant": "Sam", "amount": "45.50", "category": "Equipment", "description": "Cones", "receiptPath": "receipts/missing.pdf"}`
+	got, err := ReimbursementsPost(ctx, events.APIGatewayProxyRequest{Body: body, RequestContext: committee}, deps)
+	decode(got, err, 422)
+	body = `{"claimant": "Sam", "amount": "45.50", "category": "Equipment", "description": "Cones", "receiptPath": "/receipts/cones.pdf"}`
+	got, err = ReimbursementsPost(ctx, events.APIGatewayProxyRequest{Body: body, RequestContext: committee}, deps)
+	claim := decode(got, err, 200)
+	if claim.Status != reimbursementSubmitted || claim.SubmittedBy != "sam@example.com" || claim.ReceiptPath != "receipts/cones.pdf" || claim.Version != 1 {
+		t.Errorf("submitted = %+v", claim)
+	}
+	id := map[string]string{"id": claim.ID}
+
+	got, err = ReimbursementsPay(ctx, events.APIGatewayProxyRequest{QueryStringParameters: id, Body: `{"ledgerType": "BANK", "date": "2025-04-10"}`}, deps)
+	decode(got, err, 409)
+	got, err = ReimbursementsReview(ctx, events.APIGatewayProxyRequest{QueryStringParameters: id, Body: `{"decision": "reject"}`}, deps)
+	decode(got, err, 400)
+	got, err = ReimbursementsReview(ctx, events.APIGatewayProxyRequest{QueryStringParameters: id, Body: `{"decision": "approve", "comment": "Thanks"}`}, deps)
+	claim = decode(got, err, 200)
+	if claim.Status != reimbursementApproved || claim.Comment != "Thanks" {
+		t.Errorf("approved = %+v", claim)
+	}
+	got, err = ReimbursementsReview(ctx, events.APIGatewayProxyRequest{QueryStringParameters: id, Body: `{"decision": "reject", "comment": "Too late"}`}, deps)
+	decode(got, err, 409)
+
+	got, err = ReimbursementsPay(ctx, events.APIGatewayProxyRequest{QueryStringParameters: id, Body: `{"ledgerType": "bank", "date": "2025-04-10"}`}, deps)
+	claim = decode(got, err, 200)
+	if claim.Status != reimbursementPaid || claim.Payment == nil || claim.Payment.LedgerType != "BANK" || claim.Payment.Month != "2025-04" {
+		t.Fatalf("paid = %+v", claim)
+	}
+	bank, err := loadLedgerMonths(ctx, deps, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := bank[0].Transactions[0]
+	if tx.ID != claim.Payment.TransactionID || tx.ReimbursementID != claim.ID || tx.Amount != -4550 || tx.Category != reimbursementCategory || bank[0].ClosingBalance != -4550 {
+		t.Errorf("ledger = %+v", bank[0])
+	}
+	got, err = ReimbursementsPay(ctx, events.APIGatewayProxyRequest{QueryStringParameters: id, Body: `{"ledgerType": "BANK", "date": "2025-04-10"}`}, deps)
+	decode(got, err, 409)
+
+	got, err = ReimbursementsGet(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"status": "paid"}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("ReimbursementsGet() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var claims []Reimbursement
+	if err := json.Unmarshal([]byte(got.Body), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 1 || claims[0].ID != claim.ID {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// Deleting the payment from the ledger leaves the claim approved, ready to be paid again
+	bank[0].Transactions = []Transaction{}
+	recalculateLedger(&bank[0])
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, bank)}, deps)
+	if err != nil || got.StatusCode != 200 || !strings.Contains(got.Body, `"reopenedReimbursements":["`+claim.ID+`"]`) {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	reopened, _, err := loadReimbursement(ctx, deps, claim.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Status != reimbursementApproved || reopened.Payment != nil {
+		t.Errorf("reopened = %+v", reopened)
+	}
+	got, err = ReimbursementsPay(ctx, events.APIGatewayProxyRequest{QueryStringParameters: id, Body: `{"ledgerType": "BANK", "date": "2025-04-12"}`}, deps)
+	decode(got, err, 200)
+}
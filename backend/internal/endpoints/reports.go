@@ -9,11 +9,12 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
 )
 
 type ReportLineItem struct {
-	Label  string  `json:"label"`
-	Amount float64 `json:"amount"`
+	Label  string      `json:"label"`
+	Amount money.Cents `json:"amount"`
 }
 
 type ReportNote struct {
@@ -24,17 +25,17 @@ type ReportNote struct {
 type StatementSection struct {
 	Income           []ReportLineItem `json:"income"`
 	Expenditure      []ReportLineItem `json:"expenditure"`
-	TotalIncome      float64          `json:"totalIncome"`
-	TotalExpenditure float64          `json:"totalExpenditure"`
-	NetResult        float64          `json:"netResult"`
+	TotalIncome      money.Cents      `json:"totalIncome"`
+	TotalExpenditure money.Cents      `json:"totalExpenditure"`
+	NetResult        money.Cents      `json:"netResult"`
 }
 
 type BalanceSheetSection struct {
 	Assets           []ReportLineItem `json:"assets"`
 	Liabilities      []ReportLineItem `json:"liabilities"`
-	TotalAssets      float64          `json:"totalAssets"`
-	TotalLiabilities float64          `json:"totalLiabilities"`
-	Equity           float64          `json:"equity"`
+	TotalAssets      money.Cents      `json:"totalAssets"`
+	TotalLiabilities money.Cents      `json:"totalLiabilities"`
+	Equity           money.Cents      `json:"equity"`
 	EquityLabel      string           `json:"equityLabel"`
 }
 
@@ -53,9 +54,14 @@ type periodSpec struct {
 	Label string
 	Start time.Time
 	End   time.Time
+	// FinancialYear is the year a whole financial year ends in, and zero for year to date
+	FinancialYear int
 }
 
-func FinancialReportGet(_ context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+// FinancialReportGet reports from the journal once the ledgers have been migrated into it, and
+// straight from the ledgers before then. A financial year that has been closed and is still locked
+// is reported from the snapshot taken when it was closed.
+func FinancialReportGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
 	periodKey := request.QueryStringParameters["period"]
 	if periodKey == "" {
 		periodKey = "ytd"
@@ -66,22 +72,100 @@ func FinancialReportGet(_ context.Context, request events.APIGatewayProxyRequest
 		return events.APIGatewayProxyResponse{Body: fmt.Sprintf(`{"error": "%s"}`, err.Error()), StatusCode: 400, Headers: deps.Headers}, nil
 	}
 
-	ledgersByType, err := loadLedgerData(deps)
-	if err != nil {
-		return errorResponse(err, deps.Headers), nil
+	var response FinancialReportResponse
+	closed, err := loadClosedYear(ctx, deps, spec.FinancialYear)
+	switch {
+	case err != nil:
+		return storageErrorResponse(err, deps.Headers), nil
+	case closed != nil:
+		response = closed.Report
+		response.Period = spec.Key
+	default:
+		response, err = buildFinancialReport(ctx, deps, spec)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
 	}
 
-	incomeItems, expenseItems, totalIncome, totalExpense := buildStatement(spec.Start, spec.End, ledgersByType)
-	netResult := roundCurrency(totalIncome - totalExpense)
+	body, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
 
-	assets, totalAssets := buildAssets(spec.End, ledgersByType)
-	liabilities := []ReportLineItem{}
-	totalLiabilities := 0.0
-	equity := roundCurrency(totalAssets - totalLiabilities)
+// buildFinancialReport reports the period from the current journal or ledgers
+func buildFinancialReport(ctx context.Context, deps Dependencies, spec periodSpec) (FinancialReportResponse, error) {
+	categories, _, err := loadCategories(ctx, deps)
+	if err != nil {
+		return FinancialReportResponse{}, err
+	}
 
-	notes := buildNotes(assets)
+	enabled, err := journalEnabled(ctx, deps)
+	if err != nil {
+		return FinancialReportResponse{}, err
+	}
+	// The ledgers are read either way since trust money is reported by fund from its transactions
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return FinancialReportResponse{}, err
+	}
+	grants, _, err := loadGrants(ctx, deps)
+	if err != nil {
+		return FinancialReportResponse{}, err
+	}
+	transactions := ledgerTransactionIndex(ledgersByType)
+	grants, unresolved := resolveGrantLinks(grants, transactions)
+	loans, _, err := loadLoans(ctx, deps)
+	if err != nil {
+		return FinancialReportResponse{}, err
+	}
+	loans, unresolvedLoans := resolveLoanLinks(loans, transactions)
+	unresolved = append(unresolved, unresolvedLoans...)
+	// Transactions linked to the grant register or the loan schedule are reported from them
+	linked := grantReceiptLinks(grants)
+	for key := range loanLinks(loans) {
+		linked[key] = true
+	}
+	var incomeItems, expenseItems, assets, liabilities []ReportLineItem
+	var totalIncome, totalExpense money.Cents
+	if enabled {
+		months, _, err := loadJournalMonthsWithETags(ctx, deps)
+		if err != nil {
+			return FinancialReportResponse{}, err
+		}
+		months = withoutLinkedJournalLines(months, linked)
+		chart := newChartOfAccounts(categories, journalLedgerTypes(months))
+		incomeItems, expenseItems, totalIncome, totalExpense = buildJournalStatement(spec.Start, spec.End, months, chart)
+		assets, liabilities = buildJournalBalanceSheet(spec.End, months, chart)
+	} else {
+		incomeItems, expenseItems, totalIncome, totalExpense = buildStatement(spec.Start, spec.End, withoutLinkedTransactions(ledgersByType, linked), newCategoryIndex(categories))
+		assets, _ = buildAssets(spec.End, ledgersByType)
+		liabilities = []ReportLineItem{}
+	}
+	if earned := grantIncome(grants, spec.Start, spec.End); earned != 0 {
+		incomeItems = addLineItem(incomeItems, "Grant income", earned)
+		totalIncome += earned
+	}
+	if unexpended := unexpendedGrants(grants, spec.End); unexpended != 0 {
+		liabilities = append(liabilities, ReportLineItem{Label: "Unexpended grants", Amount: unexpended})
+	}
+	if interest := loanInterest(loans, spec.Start, spec.End); interest != 0 {
+		expenseItems = addLineItem(expenseItems, "Loan interest", interest)
+		totalExpense += interest
+	}
+	liabilities = append(liabilities, loanLiabilities(loans, spec.End)...)
+	funds, _, err := loadTrustFunds(ctx, deps)
+	if err != nil {
+		return FinancialReportResponse{}, err
+	}
+	trustMoney := trustFundMovements(funds, ledgersByType, spec.Start, spec.End)
+	liabilities = append(liabilities, trustMoneyLiabilities(trustMoney)...)
+	netResult := totalIncome - totalExpense
+	totalAssets := sumTotals(assets)
+	totalLiabilities := sumTotals(liabilities)
+	equity := totalAssets - totalLiabilities
+
+	notes := buildNotes(assets, grants, loans, trustMoney, unresolved, spec.End)
 
-	response := FinancialReportResponse{
+	return FinancialReportResponse{
 		Period: spec.Key,
 		Label:  spec.Label,
 		Range:  fmt.Sprintf("%s - %s", formatDate(spec.Start), formatDate(spec.End)),
@@ -102,10 +186,7 @@ func FinancialReportGet(_ context.Context, request events.APIGatewayProxyRequest
 			EquityLabel:      "Accumulated funds",
 		},
 		Notes: notes,
-	}
-
-	body, _ := json.Marshal(response)
-	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+	}, nil
 }
 
 func resolvePeriod(key string, now time.Time) (periodSpec, error) {
@@ -121,15 +202,19 @@ func resolvePeriod(key string, now time.Time) (periodSpec, error) {
 		if key == "fy-2" {
 			offset = 2
 		}
-		endYear := currentFYEnd - offset
-		start := time.Date(endYear-1, time.July, 1, 0, 0, 0, 0, time.UTC)
-		end := time.Date(endYear, time.June, 30, 23, 59, 59, 0, time.UTC)
-		return periodSpec{Key: key, Label: fmt.Sprintf("FY %d", endYear), Start: start, End: end}, nil
+		return financialYearPeriod(key, currentFYEnd-offset), nil
 	default:
 		return periodSpec{}, fmt.Errorf("Invalid period")
 	}
 }
 
+// financialYearPeriod is the July to June financial year ending in endYear
+func financialYearPeriod(key string, endYear int) periodSpec {
+	start := time.Date(endYear-1, time.July, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(endYear, time.June, 30, 23, 59, 59, 0, time.UTC)
+	return periodSpec{Key: key, Label: fmt.Sprintf("FY %d", endYear), Start: start, End: end, FinancialYear: endYear}
+}
+
 func currentFinancialYearEnd(now time.Time) int {
 	if now.Month() >= time.July {
 		return now.Year() + 1
@@ -137,8 +222,8 @@ func currentFinancialYearEnd(now time.Time) int {
 	return now.Year()
 }
 
-func loadLedgerData(deps Dependencies) (map[string][]MonthlyLedger, error) {
-	ledgerRootItems, err := deps.Data.List("ledger")
+func loadLedgerData(ctx context.Context, deps Dependencies) (map[string][]MonthlyLedger, error) {
+	ledgerRootItems, err := deps.Data.List(ctx, "ledger")
 	if err != nil {
 		return nil, err
 	}
@@ -153,47 +238,88 @@ func loadLedgerData(deps Dependencies) (map[string][]MonthlyLedger, error) {
 			continue
 		}
 
-		files, err := deps.Data.List(fmt.Sprintf("ledger/%s", ledgerType))
+		ledgers, err := loadLedgerMonths(ctx, deps, ledgerType)
 		if err != nil {
 			return nil, err
 		}
-		for _, file := range files {
-			if file.IsDir || !strings.HasSuffix(file.Name, ".json") {
-				continue
-			}
-			content, err := deps.Data.Get(file.Path)
-			if err != nil {
-				return nil, err
-			}
-			var ledger MonthlyLedger
-			if err := json.Unmarshal(content, &ledger); err != nil {
-				return nil, err
-			}
-			ledgersByType[ledgerType] = append(ledgersByType[ledgerType], ledger)
-		}
+		ledgersByType[ledgerType] = ledgers
 	}
 	return ledgersByType, nil
 }
 
-func buildStatement(start, end time.Time, ledgersByType map[string][]MonthlyLedger) ([]ReportLineItem, []ReportLineItem, float64, float64) {
-	incomeTotals := map[string]float64{}
-	expenseTotals := map[string]float64{}
+// loadLedgerMonths reads every stored month of one ledger type, ordered by month
+func loadLedgerMonths(ctx context.Context, deps Dependencies, ledgerType string) ([]MonthlyLedger, error) {
+	ledgers, _, err := loadLedgerMonthsWithETags(ctx, deps, ledgerType)
+	return ledgers, err
+}
+
+// loadLedgerMonthsWithETags is loadLedgerMonths plus each month's storage ETag, for conditional writes
+func loadLedgerMonthsWithETags(ctx context.Context, deps Dependencies, ledgerType string) ([]MonthlyLedger, map[string]string, error) {
+	files, err := deps.Data.List(ctx, ledgerPrefix+ledgerType)
+	if err != nil {
+		return nil, nil, err
+	}
+	ledgers := []MonthlyLedger{}
+	etags := map[string]string{}
+	for _, file := range files {
+		if file.IsDir || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		content, etag, err := deps.Data.GetWithETag(ctx, file.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		var ledger MonthlyLedger
+		if err := json.Unmarshal(content, &ledger); err != nil {
+			return nil, nil, err
+		}
+		if ledger.Month == "" {
+			ledger.Month = strings.TrimSuffix(file.Name, ".json")
+		}
+		ledgers = append(ledgers, ledger)
+		etags[ledger.Month] = etag
+	}
+	sort.Slice(ledgers, func(i, j int) bool {
+		return ledgers[i].Month < ledgers[j].Month
+	})
+	return ledgers, etags, nil
+}
+
+// buildStatement totals income and expenditure by category. A category's kind decides which side
+// its transactions fall on, so refunds reduce the category they belong to; asset, liability,
+// equity and transfer categories are balance sheet movements and are left out, as are either side
+// of a transfer between ledgers and trust money. Transactions whose category is unknown or has no
+// kind fall back to the sign of the amount.
+func buildStatement(start, end time.Time, ledgersByType map[string][]MonthlyLedger, categories categoryIndex) ([]ReportLineItem, []ReportLineItem, money.Cents, money.Cents) {
+	incomeTotals := map[string]money.Cents{}
+	expenseTotals := map[string]money.Cents{}
 
 	for _, ledgers := range ledgersByType {
 		for _, ledger := range ledgers {
 			for _, tx := range ledger.Transactions {
 				txDate, ok := parseTransactionDate(tx.Date)
-				if !ok || txDate.Before(start) || txDate.After(end) {
+				if !ok || txDate.Before(start) || txDate.After(end) || tx.TransferID != "" || tx.TrustFundID != "" {
 					continue
 				}
 				category := strings.TrimSpace(tx.Category)
 				if category == "" {
 					category = "Uncategorised"
 				}
-				if tx.Amount >= 0 {
-					incomeTotals[category] = roundCurrency(incomeTotals[category] + tx.Amount)
-				} else {
-					expenseTotals[category] = roundCurrency(expenseTotals[category] + -tx.Amount)
+				known, ok := categories.lookup(category)
+				if ok {
+					category = known.Name
+				}
+				switch {
+				case ok && known.Kind == categoryIncome:
+					incomeTotals[category] += tx.Amount
+				case ok && known.Kind == categoryExpense:
+					expenseTotals[category] += -tx.Amount
+				case ok && known.Kind != "":
+					continue
+				case tx.Amount >= 0:
+					incomeTotals[category] += tx.Amount
+				default:
+					expenseTotals[category] += -tx.Amount
 				}
 			}
 		}
@@ -207,12 +333,75 @@ func buildStatement(start, end time.Time, ledgersByType map[string][]MonthlyLedg
 	return incomeItems, expenseItems, totalIncome, totalExpense
 }
 
-func buildAssets(end time.Time, ledgersByType map[string][]MonthlyLedger) ([]ReportLineItem, float64) {
-	assetLabels := map[string]string{
-		"BANK": "Bank account",
-		"CASH": "Cash on hand",
-		"CARD": "Card balance",
+// ledgerTransactionIndex keys every ledger transaction as TYPE/ID
+func ledgerTransactionIndex(ledgersByType map[string][]MonthlyLedger) map[string]Transaction {
+	transactions := map[string]Transaction{}
+	for ledgerType, ledgers := range ledgersByType {
+		for _, ledger := range ledgers {
+			for _, tx := range ledger.Transactions {
+				transactions[ledgerType+"/"+tx.ID] = tx
+			}
+		}
+	}
+	return transactions
+}
+
+// withoutLinkedTransactions copies ledgersByType leaving out the transactions in linked, keyed as
+// TYPE/ID, which a register reports in their place
+func withoutLinkedTransactions(ledgersByType map[string][]MonthlyLedger, linked map[string]bool) map[string][]MonthlyLedger {
+	filtered := make(map[string][]MonthlyLedger, len(ledgersByType))
+	for ledgerType, ledgers := range ledgersByType {
+		filtered[ledgerType] = make([]MonthlyLedger, len(ledgers))
+		for i, ledger := range ledgers {
+			kept := make([]Transaction, 0, len(ledger.Transactions))
+			for _, tx := range ledger.Transactions {
+				if !linked[ledgerType+"/"+tx.ID] {
+					kept = append(kept, tx)
+				}
+			}
+			ledger.Transactions = kept
+			filtered[ledgerType][i] = ledger
+		}
+	}
+	return filtered
+}
+
+// withoutLinkedJournalLines copies months leaving out the category lines of entries generated
+// from the ledger transactions in linked. Their cash ledger lines stay, so the ledger accounts
+// still balance while a register reports the other side.
+func withoutLinkedJournalLines(months []JournalMonth, linked map[string]bool) []JournalMonth {
+	filtered := make([]JournalMonth, len(months))
+	for i, month := range months {
+		entries := make([]JournalEntry, len(month.Entries))
+		for j, entry := range month.Entries {
+			if entry.Source != nil && linked[entry.Source.LedgerType+"/"+entry.Source.TransactionID] {
+				lines := []JournalLine{}
+				for _, line := range entry.Lines {
+					if strings.HasPrefix(line.Account, ledgerAccountPrefix) {
+						lines = append(lines, line)
+					}
+				}
+				entry.Lines = lines
+			}
+			entries[j] = entry
+		}
+		month.Entries = entries
+		filtered[i] = month
+	}
+	return filtered
+}
+
+// addLineItem adds amount to the item labelled label, keeping items ordered by label
+func addLineItem(items []ReportLineItem, label string, amount money.Cents) []ReportLineItem {
+	totals := make(map[string]money.Cents, len(items)+1)
+	for _, item := range items {
+		totals[item.Label] += item.Amount
 	}
+	totals[label] += amount
+	return mapTotalsToItems(totals)
+}
+
+func buildAssets(end time.Time, ledgersByType map[string][]MonthlyLedger) ([]ReportLineItem, money.Cents) {
 	assets := []ReportLineItem{}
 
 	for ledgerType, ledgers := range ledgersByType {
@@ -220,11 +409,7 @@ func buildAssets(end time.Time, ledgersByType map[string][]MonthlyLedger) ([]Rep
 		if !ok {
 			continue
 		}
-		label := assetLabels[ledgerType]
-		if label == "" {
-			label = fmt.Sprintf("%s ledger", ledgerType)
-		}
-		assets = append(assets, ReportLineItem{Label: label, Amount: balance})
+		assets = append(assets, ReportLineItem{Label: ledgerAccountName(ledgerType), Amount: balance})
 	}
 
 	sort.Slice(assets, func(i, j int) bool {
@@ -235,7 +420,7 @@ func buildAssets(end time.Time, ledgersByType map[string][]MonthlyLedger) ([]Rep
 	return assets, totalAssets
 }
 
-func ledgerBalanceAsAt(ledgers []MonthlyLedger, end time.Time) (float64, bool) {
+func ledgerBalanceAsAt(ledgers []MonthlyLedger, end time.Time) (money.Cents, bool) {
 	if len(ledgers) == 0 {
 		return 0, false
 	}
@@ -263,7 +448,7 @@ func ledgerBalanceAsAt(ledgers []MonthlyLedger, end time.Time) (float64, bool) {
 			if !ok || txDate.After(end) {
 				continue
 			}
-			balance = roundCurrency(balance + tx.Amount)
+			balance += tx.Amount
 		}
 	}
 	return balance, true
@@ -285,10 +470,10 @@ func parseTransactionDate(date string) (time.Time, bool) {
 	return parsed, true
 }
 
-func mapTotalsToItems(totals map[string]float64) []ReportLineItem {
+func mapTotalsToItems(totals map[string]money.Cents) []ReportLineItem {
 	items := make([]ReportLineItem, 0, len(totals))
 	for label, amount := range totals {
-		items = append(items, ReportLineItem{Label: label, Amount: roundCurrency(amount)})
+		items = append(items, ReportLineItem{Label: label, Amount: amount})
 	}
 	sort.Slice(items, func(i, j int) bool {
 		return items[i].Label < items[j].Label
@@ -296,15 +481,17 @@ func mapTotalsToItems(totals map[string]float64) []ReportLineItem {
 	return items
 }
 
-func sumTotals(items []ReportLineItem) float64 {
-	total := 0.0
+func sumTotals(items []ReportLineItem) money.Cents {
+	var total money.Cents
 	for _, item := range items {
-		total = roundCurrency(total + item.Amount)
+		total += item.Amount
 	}
 	return total
 }
 
-func buildNotes(assets []ReportLineItem) []ReportNote {
+// buildNotes describes the balances and registers behind the report. Register links whose ledger
+// transactions are gone are listed in a last note, when there are any.
+func buildNotes(assets []ReportLineItem, grants []Grant, loans []Loan, trustMoney []trustFundMovement, unresolved []string, end time.Time) []ReportNote {
 	details := []string{}
 	if len(assets) == 0 {
 		details = append(details, "No ledger balances available for the period.")
@@ -315,30 +502,25 @@ func buildNotes(assets []ReportLineItem) []ReportNote {
 		details = append(details, "Balances derived from ledger transactions.")
 	}
 
-	return []ReportNote{
+	notes := []ReportNote{
 		{
 			Title:   "Bank accounts",
 			Details: details,
 		},
-		{
-			Title:   "Grants",
-			Details: []string{"Not available from ledgers; requires separate grant register."},
-		},
-		{
-			Title:   "Loans",
-			Details: []string{"Not available from ledgers; requires loan schedule data."},
-		},
-		{
-			Title:   "Trust money",
-			Details: []string{"Not available from ledgers; requires trust money ledger."},
-		},
+		buildGrantsNote(grants, end),
+		buildLoansNote(loans, end),
+		buildTrustMoneyNote(trustMoney),
+	}
+	if len(unresolved) > 0 {
+		notes = append(notes, ReportNote{Title: "Unresolved register links", Details: unresolved})
 	}
+	return notes
 }
 
 func formatDate(value time.Time) string {
 	return value.Format("2 Jan 2006")
 }
 
-func formatCurrency(value float64) string {
-	return fmt.Sprintf("$%.2f", value)
+func formatCurrency(value money.Cents) string {
+	return value.Format()
 }
@@ -0,0 +1,312 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const (
+	categoryRulesPath = "category-rules.json"
+	// fallbackCategory is given to imported transactions that no rule matches
+	fallbackCategory = "Misc"
+
+	ruleSignCredit = "credit"
+	ruleSignDebit  = "debit"
+)
+
+// CategoryRule assigns Category to transactions matching every condition it sets. Rules are tried
+// in ascending Priority and the first match wins.
+type CategoryRule struct {
+	ID       string `json:"id"`
+	Priority int    `json:"priority"`
+	Category string `json:"category"`
+	// Contains and Regex match the description, ignoring case
+	Contains string `json:"contains,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+	// MinAmount and MaxAmount bound the amount ignoring its sign; use Sign to pick a direction
+	MinAmount  *money.Cents `json:"minAmount,omitempty"`
+	MaxAmount  *money.Cents `json:"maxAmount,omitempty"`
+	Sign       string       `json:"sign,omitempty"`
+	LedgerType string       `json:"ledgerType,omitempty"`
+}
+
+// defaultCategoryRules apply until the treasurer saves a rule set of their own
+var defaultCategoryRules = []CategoryRule{
+	{ID: "default-membership", Priority: 10, Category: "Membership", Regex: `tidyhq|auscycling|life membership|membership fee|affiliation`},
+	{ID: "default-reimbursement", Priority: 20, Category: "Reimbursement", Contains: "reimburse"},
+	{ID: "default-sponsorship", Priority: 30, Category: "Sponsorship", Regex: `lake health group|spons`},
+	{ID: "default-equipment", Priority: 40, Category: "Equipment", Regex: `troph|engraving|weed killer|star outdoor|electrical services|asr electrical|flowers`},
+	{ID: "default-event-fee", Priority: 50, Category: "Event Fee", Regex: `entryboss|square|entry|permits|raffle`},
+}
+
+type compiledCategoryRule struct {
+	CategoryRule
+	contains string
+	pattern  *regexp.Regexp
+}
+
+// categoryRuleSet is a validated rule list in the order rules are tried
+type categoryRuleSet struct {
+	rules []compiledCategoryRule
+}
+
+type categoryRulesResponse struct {
+	Rules []CategoryRule `json:"rules"`
+}
+
+// recategorisedTransaction reports one category a rule changed
+type recategorisedTransaction struct {
+	TransactionID string `json:"transactionId"`
+	Date          string `json:"date"`
+	Description   string `json:"description"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	RuleID        string `json:"ruleId"`
+}
+
+type recategoriseResponse struct {
+	Status  string                     `json:"status"`
+	Type    string                     `json:"type"`
+	Month   string                     `json:"month"`
+	DryRun  bool                       `json:"dryRun"`
+	Changed []recategorisedTransaction `json:"changed"`
+	// JournalStale is set when the month was saved but the journal could not be updated
+	JournalStale bool `json:"journalStale,omitempty"`
+}
+
+// compileCategoryRules validates rules and orders them by priority. Types and signs are normalised
+// in the returned rules, which are what should be stored.
+func compileCategoryRules(rules []CategoryRule) (*categoryRuleSet, []CategoryRule, []ValidationViolation) {
+	violations := []ValidationViolation{}
+	normalised := make([]CategoryRule, 0, len(rules))
+	compiled := make([]compiledCategoryRule, 0, len(rules))
+	seenIDs := map[string]bool{}
+	for i, rule := range rules {
+		field := func(name string) string {
+			return fmt.Sprintf("rules[%d].%s", i, name)
+		}
+		rule.Category = strings.TrimSpace(rule.Category)
+		rule.Sign = strings.ToLower(strings.TrimSpace(rule.Sign))
+		rule.LedgerType = strings.ToUpper(strings.TrimSpace(rule.LedgerType))
+		if rule.ID == "" {
+			id, err := newUUID()
+			if err != nil {
+				return nil, nil, []ValidationViolation{{Field: field("id"), Message: err.Error()}}
+			}
+			rule.ID = id
+		}
+		if seenIDs[rule.ID] {
+			violations = append(violations, ValidationViolation{Field: field("id"), Message: fmt.Sprintf("Rule ID %s is used more than once", rule.ID)})
+		}
+		seenIDs[rule.ID] = true
+		if rule.Category == "" {
+			violations = append(violations, ValidationViolation{Field: field("category"), Message: "Category is required"})
+		}
+		if rule.Sign != "" && rule.Sign != ruleSignCredit && rule.Sign != ruleSignDebit {
+			violations = append(violations, ValidationViolation{Field: field("sign"), Message: "Sign must be credit or debit"})
+		}
+		if rule.MinAmount != nil && *rule.MinAmount < 0 {
+			violations = append(violations, ValidationViolation{Field: field("minAmount"), Message: "Minimum amount cannot be negative"})
+		}
+		if rule.MaxAmount != nil && *rule.MaxAmount < 0 {
+			violations = append(violations, ValidationViolation{Field: field("maxAmount"), Message: "Maximum amount cannot be negative"})
+		}
+		if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+			violations = append(violations, ValidationViolation{Field: field("maxAmount"), Message: "Maximum amount is less than minimum amount"})
+		}
+		entry := compiledCategoryRule{CategoryRule: rule, contains: strings.ToLower(rule.Contains)}
+		if rule.Regex != "" {
+			pattern, err := regexp.Compile("(?i)" + rule.Regex)
+			if err != nil {
+				violations = append(violations, ValidationViolation{Field: field("regex"), Message: fmt.Sprintf("Invalid regex: %v", err)})
+			}
+			entry.pattern = pattern
+		}
+		normalised = append(normalised, rule)
+		compiled = append(compiled, entry)
+	}
+	if len(violations) > 0 {
+		return nil, nil, violations
+	}
+	sort.SliceStable(normalised, func(i, j int) bool {
+		return normalised[i].Priority < normalised[j].Priority
+	})
+	sort.SliceStable(compiled, func(i, j int) bool {
+		return compiled[i].Priority < compiled[j].Priority
+	})
+	return &categoryRuleSet{rules: compiled}, normalised, violations
+}
+
+func (r compiledCategoryRule) matches(ledgerType, description string, amount money.Cents) bool {
+	if r.LedgerType != "" && r.LedgerType != ledgerType {
+		return false
+	}
+	if r.Sign == ruleSignCredit && amount <= 0 || r.Sign == ruleSignDebit && amount >= 0 {
+		return false
+	}
+	if r.MinAmount != nil && amount.Abs() < *r.MinAmount || r.MaxAmount != nil && amount.Abs() > *r.MaxAmount {
+		return false
+	}
+	if r.contains != "" && !strings.Contains(strings.ToLower(description), r.contains) {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(description)
+}
+
+// categorize returns the category of the first matching rule and that rule's ID
+func (s *categoryRuleSet) categorize(ledgerType, description string, amount money.Cents) (string, string, bool) {
+	for _, rule := range s.rules {
+		if rule.matches(ledgerType, description, amount) {
+			return rule.Category, rule.ID, true
+		}
+	}
+	return "", "", false
+}
+
+// loadCategoryRules reads the stored rules, falling back to defaultCategoryRules when none are saved
+func loadCategoryRules(ctx context.Context, deps Dependencies) ([]CategoryRule, error) {
+	content, err := deps.Data.Get(ctx, categoryRulesPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return defaultCategoryRules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []CategoryRule
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", categoryRulesPath, err)
+	}
+	return rules, nil
+}
+
+func loadCategoryRuleSet(ctx context.Context, deps Dependencies) (*categoryRuleSet, error) {
+	rules, err := loadCategoryRules(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	set, _, violations := compileCategoryRules(rules)
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("invalid %s: %s %s", categoryRulesPath, violations[0].Field, violations[0].Message)
+	}
+	return set, nil
+}
+
+func LedgerRulesGet(ctx context.Context, _ events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	rules, err := loadCategoryRules(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	body, _ := json.Marshal(categoryRulesResponse{Rules: rules})
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// LedgerRulesPost replaces the whole rule set. Rules without an ID are given one.
+func LedgerRulesPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var body categoryRulesResponse
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		fmt.Printf("Invalid rules body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	if body.Rules == nil {
+		body.Rules = []CategoryRule{}
+	}
+	_, rules, violations := compileCategoryRules(body.Rules)
+	if len(violations) > 0 {
+		return newValidationErrorResponse("Rule validation failed", violations, deps.Headers), nil
+	}
+
+	content, _ := json.Marshal(rules)
+	if err := deps.Data.Save(ctx, categoryRulesPath, content); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Saved %d category rule(s)\n", len(rules))
+	response, _ := json.Marshal(categoryRulesResponse{Rules: rules})
+	return events.APIGatewayProxyResponse{Body: string(response), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// LedgerRecategorise re-applies the category rules to one stored month. Transactions no rule
+// matches keep their category, as do transfers, reimbursement payments and trust money, whose
+// category comes from what they are linked to. With dryRun=true the changes are reported but not
+// saved.
+func LedgerRecategorise(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	ledgerType := strings.ToUpper(strings.TrimSpace(request.QueryStringParameters["type"]))
+	if ledgerType == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Type is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	month := request.QueryStringParameters["month"]
+	if _, err := time.Parse("2006-01", month); err != nil {
+		fmt.Printf("Invalid month: %s - Error: %v\n", month, err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Month must be YYYY-MM"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	dryRun := false
+	if raw := request.QueryStringParameters["dryRun"]; raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return events.APIGatewayProxyResponse{Body: `{"error": "dryRun must be true or false"}`, StatusCode: 400, Headers: deps.Headers}, nil
+		}
+		dryRun = parsed
+	}
+
+	rules, err := loadCategoryRuleSet(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	path := fmt.Sprintf("%s%s/%s.json", ledgerPrefix, ledgerType, month)
+	content, err := deps.Data.Get(ctx, path)
+	if err != nil {
+		fmt.Printf("Ledger not found: %s - %v\n", path, err)
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	var ledger MonthlyLedger
+	if err := json.Unmarshal(content, &ledger); err != nil {
+		return errorResponse(err, deps.Headers), nil
+	}
+
+	changed := []recategorisedTransaction{}
+	for i, tx := range ledger.Transactions {
+		if tx.TransferID != "" || tx.ReimbursementID != "" || tx.TrustFundID != "" {
+			continue
+		}
+		category, ruleID, ok := rules.categorize(ledgerType, tx.Description, tx.Amount)
+		if !ok || category == tx.Category {
+			continue
+		}
+		changed = append(changed, recategorisedTransaction{
+			TransactionID: tx.ID,
+			Date:          tx.Date,
+			Description:   tx.Description,
+			From:          tx.Category,
+			To:            category,
+			RuleID:        ruleID,
+		})
+		ledger.Transactions[i].Category = category
+	}
+
+	journalStale := false
+	if !dryRun && len(changed) > 0 {
+		result, err := saveLedgers(ctx, deps, ledgerType, []MonthlyLedger{ledger}, true)
+		if err != nil {
+			return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
+		}
+		journalStale = result.JournalStale
+	}
+	fmt.Printf("Recategorised %d transaction(s) in %s %s\n", len(changed), ledgerType, month)
+
+	status := "ok"
+	if dryRun {
+		status = "preview"
+	}
+	body, _ := json.Marshal(recategoriseResponse{Status: status, Type: ledgerType, Month: month, DryRun: dryRun, Changed: changed, JournalStale: journalStale})
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
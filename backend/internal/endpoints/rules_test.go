@@ -0,0 +1,124 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+func TestCategoryRuleSet_Categorize(t *testing.T) {
+	cents := func(c money.Cents) *money.Cents { return &c }
+	rules := []CategoryRule{
+		{ID: "catch-all-card", Priority: 100, Category: "Equipment", LedgerType: "card"},
+		{ID: "big-sponsor", Priority: 5, Category: "Sponsorship", Sign: "credit", MinAmount: cents(100000)},
+		{ID: "fees", Priority: 10, Category: "Event Fee", Regex: `^entry ?boss`},
+		{ID: "refunds", Priority: 10, Category: "Reimbursement", Contains: "REFUND", Sign: "debit", MaxAmount: cents(5000)},
+	}
+	set, _, violations := compileCategoryRules(rules)
+	if len(violations) > 0 {
+		t.Fatalf("violations = %+v", violations)
+	}
+
+	tests := []struct {
+		name        string
+		ledgerType  string
+		description string
+		amount      money.Cents
+		want        string
+		wantRule    string
+		wantOK      bool
+	}{
+		{name: "Regex ignores case", ledgerType: "BANK", description: "EntryBoss payout", amount: 2500, want: "Event Fee", wantRule: "fees", wantOK: true},
+		{name: "Priority wins", ledgerType: "BANK", description: "EntryBoss payout", amount: 150000, want: "Sponsorship", wantRule: "big-sponsor", wantOK: true},
+		{name: "Sign and range", ledgerType: "BANK", description: "Refund of entry", amount: -4000, want: "Reimbursement", wantRule: "refunds", wantOK: true},
+		{name: "Outside range", ledgerType: "BANK", description: "Refund of entry", amount: -6000, wantOK: false},
+		{name: "Wrong sign", ledgerType: "BANK", description: "Refund of entry", amount: 4000, wantOK: false},
+		{name: "Ledger type", ledgerType: "CARD", description: "Anything", amount: -100, want: "Equipment", wantRule: "catch-all-card", wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rule, ok := set.categorize(tt.ledgerType, tt.description, tt.amount)
+			if got != tt.want || rule != tt.wantRule || ok != tt.wantOK {
+				t.Errorf("categorize() = %q, %q, %v, want %q, %q, %v", got, rule, ok, tt.want, tt.wantRule, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCompileCategoryRules_Violations(t *testing.T) {
+	min, max := money.Cents(500), money.Cents(100)
+	rules := []CategoryRule{
+		{ID: "a", Category: "", Regex: "("},
+		{ID: "a", Category: "Misc", Sign: "both", MinAmount: &min, MaxAmount: &max},
+	}
+	_, _, got := compileCategoryRules(rules)
+	want := []ValidationViolation{
+		{Field: "rules[0].category", Message: "Category is required"},
+		{Field: "rules[0].regex", Message: "Invalid regex: error parsing regexp: missing closing ): `(?i)(`"},
+		{Field: "rules[1].id", Message: "Rule ID a is used more than once"},
+		{Field: "rules[1].sign", Message: "Sign must be credit or debit"},
+		{Field: "rules[1].maxAmount", Message: "Maximum amount is less than minimum amount"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("compileCategoryRules() violations = %+v, want %+v", got, want)
+	}
+}
+
+func TestLedgerRecategorise(t *testing.T) {
+	march := MonthlyLedger{
+		PK:             "LEDGER#BANK#2025-03",
+		Month:          "2025-03",
+		Type:           "BANK",
+		OpeningBalance: 0,
+		ClosingBalance: 600,
+		Version:        1,
+		Transactions: []Transaction{
+			{ID: "tx-1", Date: "2025-03-01", Category: "Misc", Description: "Ride shop cones", Amount: -500, RunningBalance: -500},
+			{ID: "tx-2", Date: "2025-03-02", Category: "Sponsorship", Description: "Ride shop sponsorship", Amount: 1500, RunningBalance: 1000},
+			{ID: "tx-3", Date: "2025-03-03", Category: "Transfer", Description: "Ride shop float", Amount: -200, RunningBalance: 800, TransferID: "float"},
+			{ID: "tx-4", Date: "2025-03-04", Category: "Reimbursement", Description: "Ride shop tubes", Amount: -200, RunningBalance: 600, ReimbursementID: "claim-1"},
+		},
+	}
+	prov := newTestDataProvider(t, march)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+
+	rulesBody := mustJSON(t, categoryRulesResponse{Rules: []CategoryRule{{Priority: 1, Category: "Equipment", Contains: "ride shop", Sign: "debit"}}})
+	got, err := LedgerRulesPost(context.Background(), events.APIGatewayProxyRequest{Body: rulesBody}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerRulesPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var saved categoryRulesResponse
+	if err := json.Unmarshal([]byte(got.Body), &saved); err != nil || len(saved.Rules) != 1 || saved.Rules[0].ID == "" {
+		t.Fatalf("saved rules = %+v, %v", saved, err)
+	}
+
+	for _, dryRun := range []string{"true", "false"} {
+		request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "bank", "month": "2025-03", "dryRun": dryRun}}
+		got, err := LedgerRecategorise(context.Background(), request, deps)
+		if err != nil || got.StatusCode != 200 {
+			t.Fatalf("LedgerRecategorise() = %d %s, %v", got.StatusCode, got.Body, err)
+		}
+		var response recategoriseResponse
+		if err := json.Unmarshal([]byte(got.Body), &response); err != nil {
+			t.Fatal(err)
+		}
+		want := []recategorisedTransaction{{TransactionID: "tx-1", Date: "2025-03-01", Description: "Ride shop cones", From: "Misc", To: "Equipment", RuleID: saved.Rules[0].ID}}
+		if !reflect.DeepEqual(response.Changed, want) {
+			t.Errorf("dryRun=%s changed = %+v, want %+v", dryRun, response.Changed, want)
+		}
+	}
+
+	content, _ := prov.Get(context.Background(), "ledger/BANK/2025-03.json")
+	var ledger MonthlyLedger
+	if err := json.Unmarshal(content, &ledger); err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Transactions[0].Category != "Equipment" || ledger.Transactions[1].Category != "Sponsorship" ||
+		ledger.Transactions[2].Category != "Transfer" || ledger.Transactions[3].Category != "Reimbursement" || ledger.Version != 2 {
+		t.Errorf("saved ledger = %+v", ledger)
+	}
+}
@@ -0,0 +1,257 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+// transferCategory is the category given to both sides of a transfer between ledgers
+const transferCategory = "Transfer"
+
+type ledgerTransferRequest struct {
+	From        string      `json:"from"`
+	To          string      `json:"to"`
+	Date        string      `json:"date"`
+	Amount      money.Cents `json:"amount"`
+	Description string      `json:"description"`
+}
+
+// ledgerTransferSide is the transaction a transfer created or removed in one ledger
+type ledgerTransferSide struct {
+	Type          string `json:"type"`
+	Month         string `json:"month"`
+	TransactionID string `json:"transactionId"`
+}
+
+type ledgerTransferResponse struct {
+	Status     string               `json:"status"`
+	TransferID string               `json:"transferId"`
+	Sides      []ledgerTransferSide `json:"sides"`
+	// JournalStale is set when the sides were saved but the journal could not be updated
+	JournalStale bool `json:"journalStale,omitempty"`
+}
+
+// ledgerMonthForUpdate returns the stored month of ledgers, which are sorted by month, or a new
+// empty month opening at the closing balance of the month before it. A month earlier than any
+// stored one opens at the earliest stored opening balance so that later months keep theirs.
+func ledgerMonthForUpdate(ledgers []MonthlyLedger, ledgerType, month string) MonthlyLedger {
+	ledger := MonthlyLedger{
+		PK:           fmt.Sprintf("LEDGER#%s#%s", ledgerType, month),
+		Month:        month,
+		Type:         ledgerType,
+		Transactions: []Transaction{},
+	}
+	for _, stored := range ledgers {
+		if stored.Month == month {
+			return stored
+		}
+	}
+	switch next := sort.Search(len(ledgers), func(i int) bool { return ledgers[i].Month > month }); {
+	case next > 0:
+		ledger.OpeningBalance = ledgers[next-1].ClosingBalance
+	case len(ledgers) > 0:
+		ledger.OpeningBalance = ledgers[0].OpeningBalance
+	}
+	ledger.ClosingBalance = ledger.OpeningBalance
+	return ledger
+}
+
+// removeTransferTransactions deletes every transaction belonging to transferID from every ledger
+// type except skipType, and returns the sides it removed and whether the journal was left behind
+// them
+func removeTransferTransactions(ctx context.Context, deps Dependencies, transferID, skipType string) ([]ledgerTransferSide, bool, error) {
+	removed := []ledgerTransferSide{}
+	journalStale := false
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return removed, journalStale, err
+	}
+	types := make([]string, 0, len(ledgersByType))
+	for ledgerType := range ledgersByType {
+		types = append(types, ledgerType)
+	}
+	sort.Strings(types)
+	for _, ledgerType := range types {
+		if ledgerType == skipType {
+			continue
+		}
+		changed := []MonthlyLedger{}
+		sides := []ledgerTransferSide{}
+		for _, ledger := range ledgersByType[ledgerType] {
+			kept := make([]Transaction, 0, len(ledger.Transactions))
+			for _, tx := range ledger.Transactions {
+				if tx.TransferID == transferID {
+					sides = append(sides, ledgerTransferSide{Type: ledgerType, Month: ledger.Month, TransactionID: tx.ID})
+					continue
+				}
+				kept = append(kept, tx)
+			}
+			if len(kept) == len(ledger.Transactions) {
+				continue
+			}
+			ledger.Transactions = kept
+			recalculateLedger(&ledger)
+			changed = append(changed, ledger)
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		result, err := saveLedgers(ctx, deps, ledgerType, changed, true)
+		if err != nil {
+			return removed, journalStale, err
+		}
+		removed = append(removed, sides...)
+		journalStale = journalStale || result.JournalStale
+	}
+	return removed, journalStale, nil
+}
+
+// removedTransferIDs lists the transfers with a transaction in stored that the submitted months
+// no longer carry
+func removedTransferIDs(stored, submitted []MonthlyLedger) []string {
+	return removedLinkIDs(stored, submitted, func(tx Transaction) string { return tx.TransferID })
+}
+
+// removedLinkIDs lists the links, as returned by link, of transactions in the stored months that
+// were submitted when no submitted transaction carries the same link
+func removedLinkIDs(stored, submitted []MonthlyLedger, link func(Transaction) string) []string {
+	submittedMonths := map[string]bool{}
+	kept := map[string]bool{}
+	for _, ledger := range submitted {
+		submittedMonths[ledger.Month] = true
+		for _, tx := range ledger.Transactions {
+			if id := link(tx); id != "" {
+				kept[id] = true
+			}
+		}
+	}
+	removed := []string{}
+	seen := map[string]bool{}
+	for _, ledger := range stored {
+		if !submittedMonths[ledger.Month] {
+			continue
+		}
+		for _, tx := range ledger.Transactions {
+			if id := link(tx); id != "" && !kept[id] && !seen[id] {
+				seen[id] = true
+				removed = append(removed, id)
+			}
+		}
+	}
+	return removed
+}
+
+// LedgerTransferPost moves money between two ledgers, adding a Transfer transaction to each that
+// share a transfer ID. Storage has no multi-object transactions, so if the second ledger cannot be
+// written the first side is taken out again before the error is returned.
+func LedgerTransferPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var body ledgerTransferRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		fmt.Printf("Invalid transfer body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	body.From = strings.ToUpper(strings.TrimSpace(body.From))
+	body.To = strings.ToUpper(strings.TrimSpace(body.To))
+	switch {
+	case body.From == "" || body.To == "":
+		return events.APIGatewayProxyResponse{Body: `{"error": "From and to ledger types are required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	case body.From == body.To:
+		return events.APIGatewayProxyResponse{Body: `{"error": "From and to must be different ledgers"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	case body.Amount <= 0:
+		return events.APIGatewayProxyResponse{Body: `{"error": "Amount must be positive"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	if _, err := time.Parse("2006-01-02", body.Date); err != nil || len(body.Date) != len("2006-01-02") {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Date must be YYYY-MM-DD"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	description := strings.TrimSpace(body.Description)
+	if description == "" {
+		description = fmt.Sprintf("Transfer from %s to %s", body.From, body.To)
+	}
+
+	transferID, err := newUUID()
+	if err != nil {
+		return errorResponse(err, deps.Headers), nil
+	}
+	month := body.Date[:len("2006-01")]
+	type transferSide struct {
+		ledgerType string
+		ledger     MonthlyLedger
+		id         string
+	}
+	sides := []transferSide{}
+	for _, side := range []struct {
+		ledgerType string
+		amount     money.Cents
+	}{{body.From, -body.Amount}, {body.To, body.Amount}} {
+		id, err := newUUID()
+		if err != nil {
+			return errorResponse(err, deps.Headers), nil
+		}
+		stored, err := loadLedgerMonths(ctx, deps, side.ledgerType)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		ledger := ledgerMonthForUpdate(stored, side.ledgerType, month)
+		ledger.Transactions = append(ledger.Transactions, Transaction{
+			ID:          id,
+			Date:        body.Date,
+			Category:    transferCategory,
+			Description: description,
+			Amount:      side.amount,
+			TransferID:  transferID,
+		})
+		recalculateLedger(&ledger)
+		if violations := validateLedgers(side.ledgerType, []MonthlyLedger{ledger}); len(violations) > 0 {
+			return validationErrorResponse(violations, deps.Headers), nil
+		}
+		sides = append(sides, transferSide{ledgerType: side.ledgerType, ledger: ledger, id: id})
+	}
+
+	response := ledgerTransferResponse{Status: "ok", TransferID: transferID, Sides: []ledgerTransferSide{}}
+	for _, side := range sides {
+		result, err := saveLedgers(ctx, deps, side.ledgerType, []MonthlyLedger{side.ledger}, true)
+		if err != nil {
+			if len(response.Sides) > 0 {
+				if _, _, undoErr := removeTransferTransactions(ctx, deps, transferID, side.ledgerType); undoErr != nil {
+					fmt.Printf("Transfer %s left one-sided in %s: %v\n", transferID, body.From, undoErr)
+				}
+			}
+			return ledgerSaveErrorResponse(err, result.Written, deps.Headers), nil
+		}
+		response.Sides = append(response.Sides, ledgerTransferSide{Type: side.ledgerType, Month: month, TransactionID: side.id})
+		response.JournalStale = response.JournalStale || result.JournalStale
+	}
+
+	fmt.Printf("Transferred %s from %s to %s (%s)\n", body.Amount.Format(), body.From, body.To, transferID)
+	responseBody, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{Body: string(responseBody), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// LedgerTransferDelete removes both sides of the transfer named by the id parameter
+func LedgerTransferDelete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	transferID := request.QueryStringParameters["id"]
+	if transferID == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	removed, journalStale, err := removeTransferTransactions(ctx, deps, transferID, "")
+	if err != nil {
+		saved := []string{}
+		for _, side := range removed {
+			saved = append(saved, side.Type+"/"+side.Month)
+		}
+		return ledgerSaveErrorResponse(err, saved, deps.Headers), nil
+	}
+	if len(removed) == 0 {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Transfer not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	fmt.Printf("Deleted transfer %s from %d ledger(s)\n", transferID, len(removed))
+	body, _ := json.Marshal(ledgerTransferResponse{Status: "deleted", TransferID: transferID, Sides: removed, JournalStale: journalStale})
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
@@ -0,0 +1,148 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+func TestLedgerMonthForUpdate(t *testing.T) {
+	stored := []MonthlyLedger{
+		{Month: "2025-03", OpeningBalance: 1000, ClosingBalance: 1500},
+		{Month: "2025-05", OpeningBalance: 1500, ClosingBalance: 900},
+	}
+	tests := []struct {
+		name        string
+		ledgers     []MonthlyLedger
+		month       string
+		wantOpening money.Cents
+	}{
+		{name: "Stored month", ledgers: stored, month: "2025-05", wantOpening: 1500},
+		{name: "Gap month", ledgers: stored, month: "2025-04", wantOpening: 1500},
+		{name: "After the last", ledgers: stored, month: "2025-07", wantOpening: 900},
+		{name: "Before the first", ledgers: stored, month: "2025-01", wantOpening: 1000},
+		{name: "Nothing stored", ledgers: nil, month: "2025-01", wantOpening: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ledgerMonthForUpdate(tt.ledgers, "CASH", tt.month)
+			if got.Month != tt.month || got.OpeningBalance != tt.wantOpening {
+				t.Errorf("ledgerMonthForUpdate() = %+v, want opening %s", got, tt.wantOpening)
+			}
+		})
+	}
+}
+
+func TestLedgerTransfer(t *testing.T) {
+	cash := MonthlyLedger{
+		PK:             "LEDGER#CASH#2025-03",
+		Month:          "2025-03",
+		Type:           "CASH",
+		OpeningBalance: 50000,
+		ClosingBalance: 50000,
+		Transactions:   []Transaction{},
+	}
+	prov := newTestDataProvider(t, cash)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+
+	body := `{"from": "cash", "to": "BANK", "date": "2025-03-14", "amount": "200.00"}`
+	got, err := LedgerTransferPost(ctx, events.APIGatewayProxyRequest{Body: body}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerTransferPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var transfer ledgerTransferResponse
+	if err := json.Unmarshal([]byte(got.Body), &transfer); err != nil {
+		t.Fatal(err)
+	}
+	if len(transfer.Sides) != 2 || transfer.Sides[0].Type != "CASH" || transfer.Sides[1].Type != "BANK" {
+		t.Fatalf("sides = %+v", transfer.Sides)
+	}
+
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cashSide, bankSide := ledgersByType["CASH"][0], ledgersByType["BANK"][0]
+	if cashSide.ClosingBalance != 30000 || cashSide.Transactions[0].TransferID != transfer.TransferID || cashSide.Transactions[0].Category != transferCategory {
+		t.Errorf("cash = %+v", cashSide)
+	}
+	if bankSide.ClosingBalance != 20000 || bankSide.Transactions[0].TransferID != transfer.TransferID || bankSide.Transactions[0].Description != "Transfer from CASH to BANK" {
+		t.Errorf("bank = %+v", bankSide)
+	}
+
+	// Neither side is income or expenditure, even without a Transfer category
+	income, expense, _, _ := buildStatement(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), ledgersByType, newCategoryIndex(nil))
+	if len(income) != 0 || len(expense) != 0 {
+		t.Errorf("statement = %+v, %+v", income, expense)
+	}
+
+	// Deleting the cash side through the ledger deletes the bank side too
+	cashSide.Transactions = []Transaction{}
+	recalculateLedger(&cashSide)
+	got, err = LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "CASH"}, Body: mustJSON(t, []MonthlyLedger{cashSide})}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var posted ledgerPostResponse
+	if err := json.Unmarshal([]byte(got.Body), &posted); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted.RemovedTransfers) != 1 || posted.RemovedTransfers[0] != transfer.Sides[1] {
+		t.Errorf("removed = %+v, want %+v", posted.RemovedTransfers, transfer.Sides[1])
+	}
+	bank, err := loadLedgerMonths(ctx, deps, "BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bank[0].Transactions) != 0 || bank[0].ClosingBalance != 0 {
+		t.Errorf("bank after delete = %+v", bank[0])
+	}
+
+	got, err = LedgerTransferDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": transfer.TransferID}}, deps)
+	if err != nil || got.StatusCode != 404 {
+		t.Errorf("LedgerTransferDelete() of removed transfer = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+}
+
+func TestLedgerTransferDelete(t *testing.T) {
+	prov := newTestDataProvider(t)
+	deps := Dependencies{Data: prov, Headers: DefaultHeaders()}
+	ctx := context.Background()
+
+	got, err := LedgerTransferPost(ctx, events.APIGatewayProxyRequest{Body: `{"from": "BANK", "to": "CARD", "date": "2025-04-02", "amount": "75.50", "description": "Card repayment"}`}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerTransferPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var transfer ledgerTransferResponse
+	if err := json.Unmarshal([]byte(got.Body), &transfer); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = LedgerTransferDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": transfer.TransferID}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("LedgerTransferDelete() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var deleted ledgerTransferResponse
+	if err := json.Unmarshal([]byte(got.Body), &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted.Sides) != 2 || deleted.Sides[0].Type != "BANK" || deleted.Sides[1].Type != "CARD" {
+		t.Errorf("deleted = %+v", deleted)
+	}
+
+	for _, body := range []string{
+		`{"from": "BANK", "to": "BANK", "date": "2025-04-02", "amount": "1.00"}`,
+		`{"from": "BANK", "to": "CASH", "date": "2025-04-02", "amount": "-1.00"}`,
+		`{"from": "BANK", "to": "CASH", "date": "02/04/2025", "amount": "1.00"}`,
+	} {
+		got, err := LedgerTransferPost(ctx, events.APIGatewayProxyRequest{Body: body}, deps)
+		if err != nil || got.StatusCode != 400 {
+			t.Errorf("LedgerTransferPost(%s) = %d %s, %v", body, got.StatusCode, got.Body, err)
+		}
+	}
+}
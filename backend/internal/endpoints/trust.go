@@ -0,0 +1,358 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+	"github.com/eureka-cycling/committee-apps/backend/internal/storage"
+)
+
+const trustFundsPath = "trust-funds.json"
+
+// TrustFund is money the club holds on behalf of someone else, such as race entries collected
+// for another club. Transactions are tagged to a fund by its ID and the fund's balance is owed to
+// HeldFor, so it is a liability rather than income.
+type TrustFund struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	HeldFor     string `json:"heldFor"`
+	Description string `json:"description,omitempty"`
+}
+
+// trustFundView is a fund with its balance to date
+type trustFundView struct {
+	TrustFund
+	Balance money.Cents `json:"balance"`
+}
+
+// TrustFundEntry is a transaction tagged to a fund, with the fund's running balance
+type TrustFundEntry struct {
+	LedgerType  string      `json:"ledgerType"`
+	Transaction Transaction `json:"transaction"`
+	Balance     money.Cents `json:"balance"`
+}
+
+type trustFundLedgerResponse struct {
+	Fund    trustFundView    `json:"fund"`
+	Entries []TrustFundEntry `json:"entries"`
+}
+
+// trustFundMovement is what a fund received and paid out over a period
+type trustFundMovement struct {
+	Fund     TrustFund
+	Opening  money.Cents
+	Received money.Cents
+	PaidOut  money.Cents
+	Closing  money.Cents
+	// Active is set when the fund has any transaction up to the period end
+	Active bool
+}
+
+func loadTrustFunds(ctx context.Context, deps Dependencies) ([]TrustFund, string, error) {
+	content, etag, err := deps.Data.GetWithETag(ctx, trustFundsPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return []TrustFund{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var funds []TrustFund
+	if err := json.Unmarshal(content, &funds); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %w", trustFundsPath, err)
+	}
+	return funds, etag, nil
+}
+
+func saveTrustFunds(ctx context.Context, deps Dependencies, funds []TrustFund, etag string) error {
+	sort.SliceStable(funds, func(i, j int) bool { return funds[i].Name < funds[j].Name })
+	content, _ := json.Marshal(funds)
+	return deps.Data.SaveIfMatch(ctx, trustFundsPath, content, etag)
+}
+
+// trustFundEntries lists the transactions tagged to fundID in date order with the fund's running balance
+func trustFundEntries(fundID string, ledgersByType map[string][]MonthlyLedger) []TrustFundEntry {
+	entries := []TrustFundEntry{}
+	for ledgerType, ledgers := range ledgersByType {
+		for _, ledger := range ledgers {
+			for _, tx := range ledger.Transactions {
+				if tx.TrustFundID == fundID {
+					entries = append(entries, TrustFundEntry{LedgerType: ledgerType, Transaction: tx})
+				}
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Transaction.Date != entries[j].Transaction.Date {
+			return entries[i].Transaction.Date < entries[j].Transaction.Date
+		}
+		return entries[i].LedgerType < entries[j].LedgerType
+	})
+	var balance money.Cents
+	for i := range entries {
+		balance += entries[i].Transaction.Amount
+		entries[i].Balance = balance
+	}
+	return entries
+}
+
+// trustFundMovements totals each fund's transactions before, during and to the end of the
+// period. Tags naming a fund that is no longer in the register are reported under their ID.
+func trustFundMovements(funds []TrustFund, ledgersByType map[string][]MonthlyLedger, start, end time.Time) []trustFundMovement {
+	movements := make([]trustFundMovement, 0, len(funds))
+	index := map[string]int{}
+	for _, fund := range funds {
+		index[fund.ID] = len(movements)
+		movements = append(movements, trustFundMovement{Fund: fund})
+	}
+	for _, ledgers := range ledgersByType {
+		for _, ledger := range ledgers {
+			for _, tx := range ledger.Transactions {
+				txDate, ok := parseTransactionDate(tx.Date)
+				if tx.TrustFundID == "" || !ok || txDate.After(end) {
+					continue
+				}
+				i, known := index[tx.TrustFundID]
+				if !known {
+					i = len(movements)
+					index[tx.TrustFundID] = i
+					movements = append(movements, trustFundMovement{Fund: TrustFund{ID: tx.TrustFundID, Name: tx.TrustFundID}})
+				}
+				movement := &movements[i]
+				movement.Active = true
+				movement.Closing += tx.Amount
+				switch {
+				case txDate.Before(start):
+					movement.Opening += tx.Amount
+				case tx.Amount > 0:
+					movement.Received += tx.Amount
+				default:
+					movement.PaidOut += -tx.Amount
+				}
+			}
+		}
+	}
+	return movements
+}
+
+// trustMoneyLiabilities reports each fund's balance at the period end as a liability
+func trustMoneyLiabilities(movements []trustFundMovement) []ReportLineItem {
+	items := []ReportLineItem{}
+	for _, movement := range movements {
+		if movement.Closing != 0 {
+			items = append(items, ReportLineItem{Label: fmt.Sprintf("Trust money - %s", movement.Fund.Name), Amount: movement.Closing})
+		}
+	}
+	return items
+}
+
+// buildTrustMoneyNote describes every fund with transactions by the period end
+func buildTrustMoneyNote(movements []trustFundMovement) ReportNote {
+	details := []string{}
+	for _, movement := range movements {
+		if !movement.Active {
+			continue
+		}
+		detail := movement.Fund.Name
+		if movement.Fund.HeldFor != "" {
+			detail += fmt.Sprintf(" (held for %s)", movement.Fund.HeldFor)
+		}
+		details = append(details, fmt.Sprintf("%s: opening %s, received %s, paid out %s, balance held %s.", detail,
+			formatCurrency(movement.Opening), formatCurrency(movement.Received), formatCurrency(movement.PaidOut), formatCurrency(movement.Closing)))
+	}
+	if len(details) == 0 {
+		details = append(details, "No trust money held for the period.")
+	}
+	return ReportNote{Title: "Trust money", Details: details}
+}
+
+// validateTrustFundTags checks that every tagged transaction names a fund in the register and is
+// not one side of a transfer between ledgers
+func validateTrustFundTags(ledgers []MonthlyLedger, funds []TrustFund) []ValidationViolation {
+	known := map[string]bool{}
+	for _, fund := range funds {
+		known[fund.ID] = true
+	}
+	violations := []ValidationViolation{}
+	for _, ledger := range ledgers {
+		for i, tx := range ledger.Transactions {
+			if tx.TrustFundID == "" {
+				continue
+			}
+			field := fmt.Sprintf("transactions[%d].trustFundId", i)
+			switch {
+			case !known[tx.TrustFundID]:
+				violations = append(violations, ValidationViolation{Month: ledger.Month, TransactionID: tx.ID, Field: field, Message: fmt.Sprintf("Trust fund %s is not in the trust fund register", tx.TrustFundID)})
+			case tx.TransferID != "":
+				violations = append(violations, ValidationViolation{Month: ledger.Month, TransactionID: tx.ID, Field: field, Message: "Transfers between ledgers cannot be tagged to a trust fund"})
+			}
+		}
+	}
+	return violations
+}
+
+// TrustFundsGet lists the trust fund register with each fund's balance today
+func TrustFundsGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	funds, _, err := loadTrustFunds(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	now := time.Now()
+	movements := trustFundMovements(funds, ledgersByType, now, now)
+	views := make([]trustFundView, 0, len(funds))
+	for _, movement := range movements[:len(funds)] {
+		views = append(views, trustFundView{TrustFund: movement.Fund, Balance: movement.Closing})
+	}
+	body, _ := json.Marshal(views)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// TrustFundLedgerGet returns every transaction tagged to the fund named by the id parameter
+func TrustFundLedgerGet(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	id := request.QueryStringParameters["id"]
+	if id == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	funds, _, err := loadTrustFunds(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	for _, fund := range funds {
+		if fund.ID != id {
+			continue
+		}
+		ledgersByType, err := loadLedgerData(ctx, deps)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		response := trustFundLedgerResponse{Fund: trustFundView{TrustFund: fund}, Entries: trustFundEntries(id, ledgersByType)}
+		if len(response.Entries) > 0 {
+			response.Fund.Balance = response.Entries[len(response.Entries)-1].Balance
+		}
+		body, _ := json.Marshal(response)
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: `{"error": "Trust fund not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+}
+
+// trustFundEntryDates keys each of entries by the fund's name and the transaction, mapped to its
+// date, since the name labels the fund's liability in the reports
+func trustFundEntryDates(fund TrustFund, entries []TrustFundEntry) map[string]string {
+	dates := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		dates[fund.Name+"|"+fund.HeldFor+"|"+entry.LedgerType+"/"+entry.Transaction.ID] = entry.Transaction.Date
+	}
+	return dates
+}
+
+// TrustFundsPost adds a fund to the register, or replaces the fund with the same ID
+func TrustFundsPost(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	var fund TrustFund
+	if err := json.Unmarshal([]byte(request.Body), &fund); err != nil {
+		fmt.Printf("Invalid trust fund body: %v\n", err)
+		return events.APIGatewayProxyResponse{Body: `{"error": "Invalid JSON"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	fund.Name = strings.TrimSpace(fund.Name)
+	fund.HeldFor = strings.TrimSpace(fund.HeldFor)
+	fund.Description = strings.TrimSpace(fund.Description)
+
+	funds, etag, err := loadTrustFunds(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	others := make([]TrustFund, 0, len(funds))
+	var previous *TrustFund
+	for i, existing := range funds {
+		if existing.ID != fund.ID || fund.ID == "" {
+			others = append(others, existing)
+		} else {
+			previous = &funds[i]
+		}
+	}
+	if fund.ID != "" && previous == nil {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Trust fund not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	violations := []ValidationViolation{}
+	if fund.Name == "" {
+		violations = append(violations, ValidationViolation{Field: "name", Message: "Name is required"})
+	}
+	for _, other := range others {
+		if strings.EqualFold(other.Name, fund.Name) {
+			violations = append(violations, ValidationViolation{Field: "name", Message: fmt.Sprintf("Trust fund %q already exists", other.Name)})
+		}
+	}
+	if fund.HeldFor == "" {
+		violations = append(violations, ValidationViolation{Field: "heldFor", Message: "Held for is required"})
+	}
+	if len(violations) > 0 {
+		return newValidationErrorResponse("Trust fund validation failed", violations, deps.Headers), nil
+	}
+	if previous != nil && (previous.Name != fund.Name || previous.HeldFor != fund.HeldFor) {
+		ledgersByType, err := loadLedgerData(ctx, deps)
+		if err != nil {
+			return storageErrorResponse(err, deps.Headers), nil
+		}
+		entries := trustFundEntries(fund.ID, ledgersByType)
+		if locked := registerPeriodLocked(ctx, deps, trustFundEntryDates(*previous, entries), trustFundEntryDates(fund, entries)); locked != nil {
+			return *locked, nil
+		}
+	}
+	if fund.ID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return errorResponse(err, deps.Headers), nil
+		}
+		fund.ID = id
+	}
+	if err := saveTrustFunds(ctx, deps, append(others, fund), etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Saved trust fund %s (%s)\n", fund.ID, fund.Name)
+	body, _ := json.Marshal(fund)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: deps.Headers}, nil
+}
+
+// TrustFundsDelete removes the fund named by the id parameter from the register. A fund that
+// still has tagged transactions cannot be removed.
+func TrustFundsDelete(ctx context.Context, request events.APIGatewayProxyRequest, deps Dependencies) (events.APIGatewayProxyResponse, error) {
+	id := request.QueryStringParameters["id"]
+	if id == "" {
+		return events.APIGatewayProxyResponse{Body: `{"error": "ID is required"}`, StatusCode: 400, Headers: deps.Headers}, nil
+	}
+	funds, etag, err := loadTrustFunds(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	kept := make([]TrustFund, 0, len(funds))
+	for _, fund := range funds {
+		if fund.ID != id {
+			kept = append(kept, fund)
+		}
+	}
+	if len(kept) == len(funds) {
+		return events.APIGatewayProxyResponse{Body: `{"error": "Trust fund not found"}`, StatusCode: 404, Headers: deps.Headers}, nil
+	}
+	ledgersByType, err := loadLedgerData(ctx, deps)
+	if err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	if entries := trustFundEntries(id, ledgersByType); len(entries) > 0 {
+		body, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Trust fund has %d tagged transaction(s); untag them first", len(entries))})
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 409, Headers: deps.Headers}, nil
+	}
+	if err := saveTrustFunds(ctx, deps, kept, etag); err != nil {
+		return storageErrorResponse(err, deps.Headers), nil
+	}
+	fmt.Printf("Deleted trust fund %s\n", id)
+	return events.APIGatewayProxyResponse{Body: `{"status": "deleted"}`, StatusCode: 200, Headers: deps.Headers}, nil
+}
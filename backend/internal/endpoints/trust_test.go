@@ -0,0 +1,93 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestTrustFundsReport(t *testing.T) {
+	ctx := context.Background()
+	deps := Dependencies{Data: newTestDataProvider(t), Headers: DefaultHeaders()}
+
+	got, err := TrustFundsPost(ctx, events.APIGatewayProxyRequest{Body: `{"name": "Ballarat Open entries", "heldFor": "Ballarat Cycling Club"}`}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("TrustFundsPost() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var fund TrustFund
+	if err := json.Unmarshal([]byte(got.Body), &fund); err != nil {
+		t.Fatal(err)
+	}
+	got, err = TrustFundsPost(ctx, events.APIGatewayProxyRequest{Body: `{"name": "ballarat open entries", "heldFor": "Someone"}`}, deps)
+	if err != nil || got.StatusCode != 422 {
+		t.Errorf("TrustFundsPost() with duplicate name = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+
+	post := func(transactions ...Transaction) events.APIGatewayProxyResponse {
+		t.Helper()
+		ledger := MonthlyLedger{PK: "LEDGER#BANK#2025-03", Month: "2025-03", Type: "BANK", Transactions: transactions}
+		recalculateLedger(&ledger)
+		got, err := LedgerPost(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"type": "BANK"}, Body: mustJSON(t, []MonthlyLedger{ledger})}, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	fees := Transaction{ID: "fees", Date: "2025-03-01", Category: "Membership", Description: "Fees", Amount: 40000}
+	entries := Transaction{ID: "entries", Date: "2025-03-08", Category: "Race entries", Description: "Ballarat Open entries", Amount: 30000, TrustFundID: fund.ID}
+	paid := Transaction{ID: "paid", Date: "2025-03-20", Category: "Race entries", Description: "Entries to Ballarat CC", Amount: -20000, TrustFundID: fund.ID}
+	unknown := Transaction{ID: "kit", Date: "2025-03-21", Category: "Kit", Description: "Kit deposit", Amount: 5000, TrustFundID: "kit-deposits"}
+	if got := post(fees, entries, paid, unknown); got.StatusCode != 422 {
+		t.Errorf("LedgerPost() with unknown trust fund = %d %s", got.StatusCode, got.Body)
+	}
+	if got := post(fees, entries, paid); got.StatusCode != 200 {
+		t.Fatalf("LedgerPost() = %d %s", got.StatusCode, got.Body)
+	}
+
+	wantLiabilities := []ReportLineItem{{Label: "Trust money - Ballarat Open entries", Amount: 10000}}
+	wantNote := ReportNote{Title: "Trust money", Details: []string{
+		"Ballarat Open entries (held for Ballarat Cycling Club): opening $0.00, received $300.00, paid out $200.00, balance held $100.00.",
+	}}
+	check := func(name string) {
+		t.Helper()
+		report, err := buildFinancialReport(ctx, deps, financialYearPeriod("fy-1", 2025))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(report.Statement.Income, []ReportLineItem{{Label: "Membership", Amount: 40000}}) || len(report.Statement.Expenditure) != 0 {
+			t.Errorf("%s: statement = %+v", name, report.Statement)
+		}
+		if !reflect.DeepEqual(report.BalanceSheet.Liabilities, wantLiabilities) || report.BalanceSheet.Equity != 40000 {
+			t.Errorf("%s: balance sheet = %+v", name, report.BalanceSheet)
+		}
+		if !reflect.DeepEqual(report.Notes[3], wantNote) {
+			t.Errorf("%s: trust money note = %+v, want %+v", name, report.Notes[3], wantNote)
+		}
+	}
+	check("ledgers")
+	got, err = JournalMigrate(ctx, events.APIGatewayProxyRequest{}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("JournalMigrate() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	check("journal")
+
+	got, err = TrustFundLedgerGet(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": fund.ID}}, deps)
+	if err != nil || got.StatusCode != 200 {
+		t.Fatalf("TrustFundLedgerGet() = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+	var ledger trustFundLedgerResponse
+	if err := json.Unmarshal([]byte(got.Body), &ledger); err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Fund.Balance != 10000 || len(ledger.Entries) != 2 || ledger.Entries[0].Balance != 30000 || ledger.Entries[1].Transaction.ID != "paid" {
+		t.Errorf("trust fund ledger = %+v", ledger)
+	}
+
+	got, err = TrustFundsDelete(ctx, events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": fund.ID}}, deps)
+	if err != nil || got.StatusCode != 409 {
+		t.Errorf("TrustFundsDelete() with tagged transactions = %d %s, %v", got.StatusCode, got.Body, err)
+	}
+}
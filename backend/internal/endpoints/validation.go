@@ -0,0 +1,108 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eureka-cycling/committee-apps/backend/internal/money"
+)
+
+// ValidationViolation describes a single problem found in a submitted ledger
+type ValidationViolation struct {
+	Month         string `json:"month,omitempty"`
+	TransactionID string `json:"transactionId,omitempty"`
+	Field         string `json:"field"`
+	Message       string `json:"message"`
+}
+
+type validationErrorBody struct {
+	Error      string                `json:"error"`
+	Violations []ValidationViolation `json:"violations"`
+}
+
+func validationErrorResponse(violations []ValidationViolation, headers map[string]string) events.APIGatewayProxyResponse {
+	return newValidationErrorResponse("Ledger validation failed", violations, headers)
+}
+
+func newValidationErrorResponse(message string, violations []ValidationViolation, headers map[string]string) events.APIGatewayProxyResponse {
+	fmt.Printf("%s with %d violation(s)\n", message, len(violations))
+	body, _ := json.Marshal(validationErrorBody{Error: message, Violations: violations})
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 422, Headers: headers}
+}
+
+// validateLedgers checks that each ledger is internally consistent and belongs to ledgerType
+func validateLedgers(ledgerType string, ledgers []MonthlyLedger) []ValidationViolation {
+	violations := []ValidationViolation{}
+	seenMonths := map[string]bool{}
+	seenIDs := map[string]string{}
+
+	for _, ledger := range ledgers {
+		month := ledger.Month
+		monthStart, err := time.Parse("2006-01", month)
+		monthValid := err == nil && len(month) == len("2006-01")
+		if !monthValid {
+			violations = append(violations, ValidationViolation{Month: month, Field: "month", Message: "Month must be YYYY-MM"})
+		} else if seenMonths[month] {
+			violations = append(violations, ValidationViolation{Month: month, Field: "month", Message: "Month appears more than once"})
+		}
+		seenMonths[month] = true
+
+		if ledger.Type != ledgerType {
+			violations = append(violations, ValidationViolation{
+				Month:   month,
+				Field:   "type",
+				Message: fmt.Sprintf("Type %q does not match ledger type %q", ledger.Type, ledgerType),
+			})
+		}
+
+		var total money.Cents
+		for i, tx := range ledger.Transactions {
+			total += tx.Amount
+			field := func(name string) string {
+				return fmt.Sprintf("transactions[%d].%s", i, name)
+			}
+
+			if tx.ID == "" {
+				violations = append(violations, ValidationViolation{Month: month, Field: field("id"), Message: "Transaction ID is required"})
+			} else if previous, ok := seenIDs[tx.ID]; ok {
+				violations = append(violations, ValidationViolation{
+					Month:         month,
+					TransactionID: tx.ID,
+					Field:         field("id"),
+					Message:       fmt.Sprintf("Transaction ID is already used in %s", previous),
+				})
+			} else {
+				seenIDs[tx.ID] = month
+			}
+
+			txDate, err := time.Parse("2006-01-02", tx.Date)
+			if err != nil {
+				violations = append(violations, ValidationViolation{Month: month, TransactionID: tx.ID, Field: field("date"), Message: "Date must be YYYY-MM-DD"})
+			} else if monthValid && (txDate.Year() != monthStart.Year() || txDate.Month() != monthStart.Month()) {
+				violations = append(violations, ValidationViolation{
+					Month:         month,
+					TransactionID: tx.ID,
+					Field:         field("date"),
+					Message:       fmt.Sprintf("Date %s is outside %s", tx.Date, month),
+				})
+			}
+
+			for j, attachment := range tx.Attachments {
+				if !validAttachmentPath(attachment.Path) {
+					violations = append(violations, ValidationViolation{Month: month, TransactionID: tx.ID, Field: field(fmt.Sprintf("attachments[%d].path", j)), Message: "Attachment path must name a document in the documents store"})
+				}
+			}
+		}
+
+		if expected := ledger.OpeningBalance + total; expected != ledger.ClosingBalance {
+			violations = append(violations, ValidationViolation{
+				Month:   month,
+				Field:   "closingBalance",
+				Message: fmt.Sprintf("Closing balance %s does not equal opening balance %s plus transactions %s (%s)", ledger.ClosingBalance, ledger.OpeningBalance, total, expected),
+			})
+		}
+	}
+	return violations
+}
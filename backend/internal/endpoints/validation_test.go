@@ -0,0 +1,124 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestValidateLedgers(t *testing.T) {
+	valid := MonthlyLedger{
+		Month:          "2025-01",
+		Type:           "BANK",
+		OpeningBalance: 1000,
+		ClosingBalance: 1500,
+		Transactions: []Transaction{
+			{ID: "tx-1", Date: "2025-01-05", Amount: 700},
+			{ID: "tx-2", Date: "2025-01-31", Amount: -200},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ledgers []MonthlyLedger
+		want    []ValidationViolation
+	}{
+		{
+			name:    "Valid",
+			ledgers: []MonthlyLedger{valid},
+			want:    []ValidationViolation{},
+		},
+		{
+			name:    "Bad month",
+			ledgers: []MonthlyLedger{{Month: "2025-1", Type: "BANK"}},
+			want:    []ValidationViolation{{Month: "2025-1", Field: "month", Message: "Month must be YYYY-MM"}},
+		},
+		{
+			name:    "Type mismatch",
+			ledgers: []MonthlyLedger{{Month: "2025-01", Type: "CASH"}},
+			want:    []ValidationViolation{{Month: "2025-01", Field: "type", Message: `Type "CASH" does not match ledger type "BANK"`}},
+		},
+		{
+			name: "Transaction outside month and duplicate ID",
+			ledgers: []MonthlyLedger{{
+				Month:          "2025-01",
+				Type:           "BANK",
+				ClosingBalance: 300,
+				Transactions: []Transaction{
+					{ID: "tx-1", Date: "2025-02-01", Amount: 100},
+					{ID: "tx-1", Date: "2025-01-02", Amount: 200},
+				},
+			}},
+			want: []ValidationViolation{
+				{Month: "2025-01", TransactionID: "tx-1", Field: "transactions[0].date", Message: "Date 2025-02-01 is outside 2025-01"},
+				{Month: "2025-01", TransactionID: "tx-1", Field: "transactions[1].id", Message: "Transaction ID is already used in 2025-01"},
+			},
+		},
+		{
+			name: "Missing ID and bad date",
+			ledgers: []MonthlyLedger{{
+				Month:        "2025-01",
+				Type:         "BANK",
+				Transactions: []Transaction{{Date: "05/01/2025"}},
+			}},
+			want: []ValidationViolation{
+				{Month: "2025-01", Field: "transactions[0].id", Message: "Transaction ID is required"},
+				{Month: "2025-01", Field: "transactions[0].date", Message: "Date must be YYYY-MM-DD"},
+			},
+		},
+		{
+			name: "Closing balance mismatch",
+			ledgers: []MonthlyLedger{{
+				Month:          "2025-01",
+				Type:           "BANK",
+				OpeningBalance: 1000,
+				ClosingBalance: 1001,
+				Transactions:   []Transaction{{ID: "tx-1", Date: "2025-01-05", Amount: 0}},
+			}},
+			want: []ValidationViolation{
+				{Month: "2025-01", Field: "closingBalance", Message: "Closing balance 10.01 does not equal opening balance 10.00 plus transactions 0.00 (10.00)"},
+			},
+		},
+		{
+			name:    "Duplicate month",
+			ledgers: []MonthlyLedger{valid, {Month: "2025-01", Type: "BANK"}},
+			want:    []ValidationViolation{{Month: "2025-01", Field: "month", Message: "Month appears more than once"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateLedgers("BANK", tt.ledgers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateLedgers() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedgerPost_RejectsInvalidLedger(t *testing.T) {
+	prov := newTestDataProvider(t)
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"type": "BANK"},
+		Body:                  `[{"month":"2025-01","type":"BANK","openingBalance":"1.00","closingBalance":"5.00","transactions":[]}]`,
+	}
+	got, err := LedgerPost(context.Background(), request, Dependencies{Data: prov})
+	if err != nil {
+		t.Fatalf("LedgerPost() error = %v", err)
+	}
+	if got.StatusCode != 422 {
+		t.Fatalf("LedgerPost() status = %d, want 422", got.StatusCode)
+	}
+	var body validationErrorBody
+	if err := json.Unmarshal([]byte(got.Body), &body); err != nil {
+		t.Fatalf("LedgerPost() body = %s, error = %v", got.Body, err)
+	}
+	if len(body.Violations) != 1 || body.Violations[0].Field != "closingBalance" {
+		t.Errorf("LedgerPost() violations = %+v", body.Violations)
+	}
+	if _, err := prov.Get(context.Background(), "ledger/BANK/2025-01.json"); err == nil {
+		t.Error("LedgerPost() saved an invalid ledger")
+	}
+}
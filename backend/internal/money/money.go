@@ -0,0 +1,137 @@
+package money
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Cents is an exact currency amount held as a whole number of cents.
+// It encodes to JSON as a decimal string such as "12.34" and decodes from either
+// that form or a plain JSON number, so ledgers stored with float amounts still read back.
+type Cents int64
+
+// FromFloat converts a float amount in dollars, rounding half away from zero to the nearest cent
+func FromFloat(value float64) Cents {
+	return Cents(math.Round(value * 100))
+}
+
+// Parse reads a decimal dollar amount such as "12.34", "-5" or "+0.5".
+// Digits beyond the second decimal place are rounded half away from zero.
+func Parse(value string) (Cents, error) {
+	raw := strings.TrimSpace(value)
+	text := raw
+	negative := false
+	if strings.HasPrefix(text, "-") {
+		negative = true
+		text = text[1:]
+	} else if strings.HasPrefix(text, "+") {
+		text = text[1:]
+	}
+
+	whole, frac, _ := strings.Cut(text, ".")
+	if (whole == "" && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	dollars, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || dollars > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("amount %q out of range", raw)
+	}
+
+	frac += "000"
+	cents := dollars*100 + int64(frac[0]-'0')*10 + int64(frac[1]-'0')
+	if frac[2] >= '5' {
+		cents++
+	}
+	if negative {
+		cents = -cents
+	}
+	return Cents(cents), nil
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float64 returns the amount in dollars for display or charting only; never do arithmetic on it
+func (c Cents) Float64() float64 {
+	return float64(c) / 100
+}
+
+// Abs returns the absolute value of c
+func (c Cents) Abs() Cents {
+	if c < 0 {
+		return -c
+	}
+	return c
+}
+
+// String formats c as a plain decimal with two places, e.g. "-12.30"
+func (c Cents) String() string {
+	sign := ""
+	abs := uint64(c)
+	if c < 0 {
+		sign = "-"
+		abs = uint64(-c)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// Format renders c as a dollar amount with thousands separators, e.g. "-$1,234.50"
+func (c Cents) Format() string {
+	plain := c.Abs().String()
+	whole, frac, _ := strings.Cut(plain, ".")
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	sign := ""
+	if c < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s$%s.%s", sign, grouped.String(), frac)
+}
+
+func (c Cents) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + c.String() + `"`), nil
+}
+
+func (c *Cents) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		parsed, err := Parse(string(data[1 : len(data)-1]))
+		if err != nil {
+			return err
+		}
+		*c = parsed
+		return nil
+	}
+
+	// Legacy float amounts: parse the literal digits exactly, falling back to floats for exponent forms
+	if parsed, err := Parse(string(data)); err == nil {
+		*c = parsed
+		return nil
+	}
+	value, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	*c = FromFloat(value)
+	return nil
+}
@@ -0,0 +1,112 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Cents
+		wantErr bool
+	}{
+		{input: "12.34", want: 1234},
+		{input: "-12.3", want: -1230},
+		{input: "+5", want: 500},
+		{input: ".5", want: 50},
+		{input: "0.005", want: 1},
+		{input: "-0.004", want: 0},
+		{input: " 1000.00 ", want: 100000},
+		{input: "", wantErr: true},
+		{input: "12.3.4", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "1e3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() got = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCents_Format(t *testing.T) {
+	tests := []struct {
+		value      Cents
+		wantString string
+		wantFormat string
+	}{
+		{value: 0, wantString: "0.00", wantFormat: "$0.00"},
+		{value: 5, wantString: "0.05", wantFormat: "$0.05"},
+		{value: -5, wantString: "-0.05", wantFormat: "-$0.05"},
+		{value: 123456789, wantString: "1234567.89", wantFormat: "$1,234,567.89"},
+		{value: -100000, wantString: "-1000.00", wantFormat: "-$1,000.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantString, func(t *testing.T) {
+			if got := tt.value.String(); got != tt.wantString {
+				t.Errorf("String() got = %s, want %s", got, tt.wantString)
+			}
+			if got := tt.value.Format(); got != tt.wantFormat {
+				t.Errorf("Format() got = %s, want %s", got, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestCents_JSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Cents
+	}{
+		{name: "String", input: `"12.34"`, want: 1234},
+		{name: "Legacy float", input: `150.5`, want: 15050},
+		{name: "Legacy negative float", input: `-0.1`, want: -10},
+		{name: "Legacy float with rounding error", input: `0.30000000000000004`, want: 30},
+		{name: "Exponent", input: `1e2`, want: 10000},
+		{name: "Null", input: `null`, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Cents
+			if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal() got = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	encoded, err := json.Marshal(struct {
+		Amount Cents `json:"amount"`
+	}{Amount: -1234})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"amount":"-12.34"}` {
+		t.Errorf("Marshal() got = %s", encoded)
+	}
+
+	var invalid Cents
+	if err := json.Unmarshal([]byte(`"twelve"`), &invalid); err == nil {
+		t.Error("Unmarshal() of invalid string error = nil")
+	}
+}
+
+func TestCents_NoDrift(t *testing.T) {
+	var total Cents
+	for i := 0; i < 1000; i++ {
+		total += FromFloat(0.1)
+	}
+	if total != 10000 {
+		t.Errorf("sum of 1000 x 0.10 = %s, want 100.00", total)
+	}
+}
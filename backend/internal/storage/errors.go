@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when the requested object does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrPreconditionFailed is returned by SaveIfMatch when the object changed since it was read
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// AccessDeniedError is returned when the provider refuses access to an object
+type AccessDeniedError struct {
+	Path string
+	Err  error
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("access denied: %s: %v", e.Path, e.Err)
+}
+
+func (e *AccessDeniedError) Unwrap() error {
+	return e.Err
+}
+
+// ThrottledError is returned when the provider is rate limiting or temporarily unavailable
+type ThrottledError struct {
+	Path string
+	Err  error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("throttled: %s: %v", e.Path, e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// notFoundError wraps a provider error so that it matches ErrNotFound while keeping the original detail
+func notFoundError(path string, err error) error {
+	return fmt.Errorf("%w: %s: %w", ErrNotFound, path, err)
+}
+
+func preconditionFailedError(path string, err error) error {
+	return fmt.Errorf("%w: %s: %w", ErrPreconditionFailed, path, err)
+}
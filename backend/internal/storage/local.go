@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LocalStorageProvider stores objects as files beneath a root directory.
+// Keys use forward slashes, matching S3 object keys.
+type LocalStorageProvider struct {
+	Root string
+
+	// mu serialises conditional writes so SaveIfMatch's check and write are atomic
+	mu sync.Mutex
+}
+
+func NewLocalStorageProvider(root string) (*LocalStorageProvider, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorageProvider{Root: root}, nil
+}
+
+// resolve maps a storage key onto the filesystem, keeping it inside Root
+func (l *LocalStorageProvider) resolve(key string) string {
+	cleaned := path.Clean("/" + key)
+	return filepath.Join(l.Root, filepath.FromSlash(cleaned))
+}
+
+func (l *LocalStorageProvider) List(ctx context.Context, dir string) ([]FileItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if dir != "" && !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	entries, err := os.ReadDir(l.resolve(dir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, mapLocalError(dir, err)
+	}
+
+	var dirs []FileItem
+	var files []FileItem
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, FileItem{
+				Name:  entry.Name(),
+				Path:  dir + entry.Name() + "/",
+				IsDir: true,
+			})
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, mapLocalError(dir+entry.Name(), err)
+		}
+		files = append(files, FileItem{
+			Name:    entry.Name(),
+			Path:    dir + entry.Name(),
+			IsDir:   false,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	// Match S3 ordering: directories (CommonPrefixes) first, then files, each by key
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Path < dirs[j].Path })
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return append(dirs, files...), nil
+}
+
+func (l *LocalStorageProvider) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(l.resolve(key))
+	if err != nil {
+		if isDirError(err) {
+			return nil, notFoundError(key, err)
+		}
+		return nil, mapLocalError(key, err)
+	}
+	return content, nil
+}
+
+// GetReader opens a file for streaming; the caller must close the returned reader
+func (l *LocalStorageProvider) GetReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	target := l.resolve(key)
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, mapLocalError(key, err)
+	}
+	if info.IsDir() {
+		return nil, notFoundError(key, fs.ErrNotExist)
+	}
+	file, err := os.Open(target)
+	if err != nil {
+		return nil, mapLocalError(key, err)
+	}
+	return file, nil
+}
+
+func (l *LocalStorageProvider) Save(ctx context.Context, key string, content []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	target := l.resolve(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return mapLocalError(key, err)
+	}
+	return mapLocalError(key, os.WriteFile(target, content, 0o644))
+}
+
+// SaveReader writes content from r to a file without buffering it in memory
+func (l *LocalStorageProvider) SaveReader(ctx context.Context, key string, r io.Reader, _ int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	target := l.resolve(key)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return mapLocalError(key, err)
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return mapLocalError(key, err)
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		// Don't leave a truncated file behind, matching S3's all-or-nothing PutObject
+		file.Close()
+		os.Remove(target)
+		return mapLocalError(key, err)
+	}
+	return mapLocalError(key, file.Close())
+}
+
+func (l *LocalStorageProvider) GetWithETag(ctx context.Context, key string) ([]byte, string, error) {
+	content, err := l.Get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, localETag(content), nil
+}
+
+func (l *LocalStorageProvider) SaveIfMatch(ctx context.Context, key string, content []byte, etag string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current, err := l.Get(ctx, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if etag != "" {
+			return preconditionFailedError(key, err)
+		}
+	case err != nil:
+		return err
+	case etag == "" || localETag(current) != etag:
+		return preconditionFailedError(key, fmt.Errorf("etag mismatch"))
+	}
+	return l.Save(ctx, key, content)
+}
+
+// localETag mirrors S3's quoted MD5 ETag for single-part uploads
+func localETag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (l *LocalStorageProvider) Mkdir(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return mapLocalError(key, os.MkdirAll(l.resolve(key), 0o755))
+}
+
+func (l *LocalStorageProvider) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := os.Remove(l.resolve(key))
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		// S3 deletes of missing keys succeed, so mirror that here
+		return nil
+	}
+	return mapLocalError(key, err)
+}
+
+// mapLocalError converts filesystem errors into the storage package error types
+func mapLocalError(key string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return notFoundError(key, err)
+	case errors.Is(err, fs.ErrPermission):
+		return &AccessDeniedError{Path: key, Err: err}
+	}
+	return err
+}
+
+func isDirError(err error) bool {
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		return false
+	}
+	info, statErr := os.Stat(pathErr.Path)
+	return statErr == nil && info.IsDir()
+}
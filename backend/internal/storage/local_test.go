@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func newTestLocalProvider(t *testing.T) *LocalStorageProvider {
+	t.Helper()
+	prov, err := NewLocalStorageProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		"ledger/BANK/2025-01.json": `{"month":"2025-01"}`,
+		"ledger/BANK/2025-02.json": `{"month":"2025-02"}`,
+		"ledger/CASH/2025-01.json": `{"month":"2025-01"}`,
+		"categories.json":          `["Misc"]`,
+	} {
+		if err := prov.Save(context.Background(), path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return prov
+}
+
+func TestLocalStorageProvider_List(t *testing.T) {
+	prov := newTestLocalProvider(t)
+
+	tests := []struct {
+		name      string
+		path      string
+		wantPaths []string
+		wantDirs  []bool
+	}{
+		{
+			name:      "Root lists directories before files",
+			path:      "",
+			wantPaths: []string{"ledger/", "categories.json"},
+			wantDirs:  []bool{true, false},
+		},
+		{
+			name:      "Directory without trailing slash",
+			path:      "ledger",
+			wantPaths: []string{"ledger/BANK/", "ledger/CASH/"},
+			wantDirs:  []bool{true, true},
+		},
+		{
+			name:      "Files",
+			path:      "ledger/BANK/",
+			wantPaths: []string{"ledger/BANK/2025-01.json", "ledger/BANK/2025-02.json"},
+			wantDirs:  []bool{false, false},
+		},
+		{
+			name:      "Missing directory",
+			path:      "missing",
+			wantPaths: nil,
+			wantDirs:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prov.List(context.Background(), tt.path)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var gotPaths []string
+			var gotDirs []bool
+			for _, item := range got {
+				gotPaths = append(gotPaths, item.Path)
+				gotDirs = append(gotDirs, item.IsDir)
+			}
+			if !reflect.DeepEqual(gotPaths, tt.wantPaths) {
+				t.Errorf("List() paths = %v, want %v", gotPaths, tt.wantPaths)
+			}
+			if !reflect.DeepEqual(gotDirs, tt.wantDirs) {
+				t.Errorf("List() dirs = %v, want %v", gotDirs, tt.wantDirs)
+			}
+		})
+	}
+}
+
+func TestLocalStorageProvider_Get(t *testing.T) {
+	prov := newTestLocalProvider(t)
+
+	tests := []struct {
+		name         string
+		path         string
+		want         []byte
+		wantNotFound bool
+	}{
+		{name: "Existing file", path: "ledger/BANK/2025-01.json", want: []byte(`{"month":"2025-01"}`)},
+		{name: "Missing file", path: "ledger/BANK/2024-12.json", wantNotFound: true},
+		{name: "Directory", path: "ledger/BANK", wantNotFound: true},
+		{name: "Escaping root stays inside root", path: "../../ledger/CASH/2025-01.json", want: []byte(`{"month":"2025-01"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prov.Get(context.Background(), tt.path)
+			if tt.wantNotFound {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get() error = %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalStorageProvider_MkdirDelete(t *testing.T) {
+	prov := newTestLocalProvider(t)
+
+	if err := prov.Mkdir(context.Background(), "receipts/2025"); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	items, err := prov.List(context.Background(), "receipts")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(items) != 1 || !items[0].IsDir || items[0].Path != "receipts/2025/" {
+		t.Errorf("List() after Mkdir = %+v", items)
+	}
+
+	if err := prov.Delete(context.Background(), "ledger/BANK/2025-01.json"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := prov.Get(context.Background(), "ledger/BANK/2025-01.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := prov.Delete(context.Background(), "ledger/BANK/2025-01.json"); err != nil {
+		t.Errorf("Delete() of missing file error = %v, want nil", err)
+	}
+}
+
+func TestLocalStorageProvider_Readers(t *testing.T) {
+	prov := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	content := "scanned receipt"
+	if err := prov.SaveReader(ctx, "receipts/2025/receipt.txt", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("SaveReader() error = %v", err)
+	}
+	reader, err := prov.GetReader(ctx, "receipts/2025/receipt.txt")
+	if err != nil {
+		t.Fatalf("GetReader() error = %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("GetReader() got = %q, want %q", got, content)
+	}
+
+	if _, err := prov.GetReader(ctx, "receipts/2025"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetReader() of directory error = %v, want ErrNotFound", err)
+	}
+
+	failing := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("read failed")))
+	if err := prov.SaveReader(ctx, "receipts/2025/partial.txt", failing, -1); err == nil {
+		t.Fatal("SaveReader() with failing reader error = nil")
+	}
+	if _, err := prov.Get(ctx, "receipts/2025/partial.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after failed SaveReader error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStorageProvider_SaveIfMatch(t *testing.T) {
+	prov := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	_, etag, err := prov.GetWithETag(ctx, "categories.json")
+	if err != nil {
+		t.Fatalf("GetWithETag() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		etag    string
+		wantErr error
+	}{
+		{name: "Create when absent", path: "new.json", etag: ""},
+		{name: "Create when present", path: "categories.json", etag: "", wantErr: ErrPreconditionFailed},
+		{name: "Update with stale etag", path: "categories.json", etag: `"stale"`, wantErr: ErrPreconditionFailed},
+		{name: "Update missing object", path: "missing.json", etag: etag, wantErr: ErrPreconditionFailed},
+		{name: "Update with current etag", path: "categories.json", etag: etag},
+		{name: "Update again with consumed etag", path: "categories.json", etag: etag, wantErr: ErrPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := prov.SaveIfMatch(ctx, tt.path, []byte(`["`+tt.name+`"]`), tt.etag)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("SaveIfMatch() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("SaveIfMatch() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
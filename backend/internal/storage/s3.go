@@ -1,13 +1,16 @@
 package storage
 
 import (
+	"bytes"
 	"context"
-	"io/ioutil"
+	"errors"
+	"io"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 )
 
 type S3StorageProvider struct {
@@ -24,7 +27,7 @@ func NewS3StorageProvider(ctx context.Context, bucket string) (*S3StorageProvide
 	return &S3StorageProvider{Client: client, Bucket: bucket}, nil
 }
 
-func (s *S3StorageProvider) List(path string) ([]FileItem, error) {
+func (s *S3StorageProvider) List(ctx context.Context, path string) ([]FileItem, error) {
 	if path != "" && !strings.HasSuffix(path, "/") {
 		path += "/"
 	}
@@ -35,81 +38,178 @@ func (s *S3StorageProvider) List(path string) ([]FileItem, error) {
 		Delimiter: aws.String("/"),
 	}
 
-	result, err := s.Client.ListObjectsV2(context.TODO(), params)
-	if err != nil {
-		return nil, err
+	var dirs []FileItem
+	var files []FileItem
+
+	// ListObjectsV2 returns at most 1000 keys per call, so follow ContinuationToken until exhausted
+	paginator := s3.NewListObjectsV2Paginator(s.Client, params)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, mapS3Error(path, err)
+		}
+
+		// Directories (CommonPrefixes)
+		for _, prefix := range result.CommonPrefixes {
+			name := strings.TrimPrefix(*prefix.Prefix, path)
+			name = strings.TrimSuffix(name, "/")
+			dirs = append(dirs, FileItem{
+				Name:  name,
+				Path:  *prefix.Prefix,
+				IsDir: true,
+			})
+		}
+
+		// Files (Contents)
+		for _, obj := range result.Contents {
+			if *obj.Key == path {
+				continue // Skip the directory itself if it shows up
+			}
+			name := strings.TrimPrefix(*obj.Key, path)
+			if name == "" {
+				continue
+			}
+			files = append(files, FileItem{
+				Name:    name,
+				Path:    *obj.Key,
+				IsDir:   false,
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
 	}
 
-	var items []FileItem
+	return append(dirs, files...), nil
+}
 
-	// Directories (CommonPrefixes)
-	for _, prefix := range result.CommonPrefixes {
-		name := strings.TrimPrefix(*prefix.Prefix, path)
-		name = strings.TrimSuffix(name, "/")
-		items = append(items, FileItem{
-			Name:  name,
-			Path:  *prefix.Prefix,
-			IsDir: true,
-		})
+func (s *S3StorageProvider) Get(ctx context.Context, path string) ([]byte, error) {
+	body, err := s.GetReader(ctx, path)
+	if err != nil {
+		return nil, err
 	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
 
-	// Files (Contents)
-	for _, obj := range result.Contents {
-		if *obj.Key == path {
-			continue // Skip the directory itself if it shows up
-		}
-		name := strings.TrimPrefix(*obj.Key, path)
-		if name == "" {
-			continue
-		}
-		items = append(items, FileItem{
-			Name:    name,
-			Path:    *obj.Key,
-			IsDir:   false,
-			Size:    *obj.Size,
-			ModTime: *obj.LastModified,
-		})
+// GetReader streams an object's content; the caller must close the returned reader
+func (s *S3StorageProvider) GetReader(ctx context.Context, path string) (io.ReadCloser, error) {
+	result, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		return nil, mapS3Error(path, err)
 	}
+	return result.Body, nil
+}
 
-	return items, nil
+func (s *S3StorageProvider) Save(ctx context.Context, path string, content []byte) error {
+	_, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path),
+		Body:   bytes.NewReader(content),
+	})
+	return mapS3Error(path, err)
 }
 
-func (s *S3StorageProvider) Get(path string) ([]byte, error) {
-	result, err := s.Client.GetObject(context.TODO(), &s3.GetObjectInput{
+func (s *S3StorageProvider) GetWithETag(ctx context.Context, path string) ([]byte, string, error) {
+	result, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(path),
 	})
 	if err != nil {
-		return nil, err
+		return nil, "", mapS3Error(path, err)
 	}
 	defer result.Body.Close()
-	return ioutil.ReadAll(result.Body)
+	content, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, aws.ToString(result.ETag), nil
 }
 
-func (s *S3StorageProvider) Save(path string, content []byte) error {
-	_, err := s.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+// SaveIfMatch uses S3 conditional writes: If-Match for existing objects, If-None-Match: * for new ones
+func (s *S3StorageProvider) SaveIfMatch(ctx context.Context, path string, content []byte, etag string) error {
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(path),
-		Body:   strings.NewReader(string(content)),
-	})
-	return err
+		Body:   bytes.NewReader(content),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+	_, err := s.Client.PutObject(ctx, input)
+	return mapS3Error(path, err)
+}
+
+// SaveReader uploads content from r without buffering it in memory.
+// S3 needs the content length for unseekable bodies, so pass size whenever it is known.
+func (s *S3StorageProvider) SaveReader(ctx context.Context, path string, r io.Reader, size int64) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path),
+		Body:   r,
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	_, err := s.Client.PutObject(ctx, input)
+	return mapS3Error(path, err)
 }
 
-func (s *S3StorageProvider) Mkdir(path string) error {
+func (s *S3StorageProvider) Mkdir(ctx context.Context, path string) error {
 	if !strings.HasSuffix(path, "/") {
 		path += "/"
 	}
-	_, err := s.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+	_, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(path),
 	})
-	return err
+	return mapS3Error(path, err)
 }
 
-func (s *S3StorageProvider) Delete(path string) error {
-	_, err := s.Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+func (s *S3StorageProvider) Delete(ctx context.Context, path string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(path),
 	})
+	return mapS3Error(path, err)
+}
+
+// mapS3Error converts AWS SDK errors into the storage package error types
+func mapS3Error(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return notFoundError(path, err)
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return preconditionFailedError(path, err)
+		case "AccessDenied", "Forbidden", "AllAccessDisabled", "InvalidAccessKeyId", "ExpiredToken":
+			return &AccessDeniedError{Path: path, Err: err}
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "ServiceUnavailable", "RequestTimeout":
+			return &ThrottledError{Path: path, Err: err}
+		}
+	}
+
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		switch statusErr.HTTPStatusCode() {
+		case 404:
+			return notFoundError(path, err)
+		case 409, 412:
+			return preconditionFailedError(path, err)
+		case 403:
+			return &AccessDeniedError{Path: path, Err: err}
+		case 429, 503:
+			return &ThrottledError{Path: path, Err: err}
+		}
+	}
 	return err
 }
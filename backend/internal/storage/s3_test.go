@@ -1,11 +1,20 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 func TestS3StorageProvider_List(t *testing.T) {
@@ -46,7 +55,7 @@ func TestS3StorageProvider_List(t *testing.T) {
 				Client: tt.fields.Client,
 				Bucket: tt.fields.Bucket,
 			}
-			got, err := s.List(tt.args.path)
+			got, err := s.List(context.Background(), tt.args.path)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("List() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -57,3 +66,99 @@ func TestS3StorageProvider_List(t *testing.T) {
 		})
 	}
 }
+
+func TestMapS3Error(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		wantNotFound     bool
+		wantAccessDenied bool
+		wantThrottled    bool
+	}{
+		{name: "NoSuchKey", err: &types.NoSuchKey{}, wantNotFound: true},
+		{name: "NotFound", err: &smithy.GenericAPIError{Code: "NotFound"}, wantNotFound: true},
+		{name: "AccessDenied", err: &smithy.GenericAPIError{Code: "AccessDenied"}, wantAccessDenied: true},
+		{name: "SlowDown", err: &smithy.GenericAPIError{Code: "SlowDown"}, wantThrottled: true},
+		{name: "Other", err: &smithy.GenericAPIError{Code: "InternalError"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mapS3Error("ledger/BANK/2025-01.json", tt.err)
+			var accessDenied *AccessDeniedError
+			var throttled *ThrottledError
+			if errors.Is(got, ErrNotFound) != tt.wantNotFound {
+				t.Errorf("mapS3Error() = %v, wantNotFound %v", got, tt.wantNotFound)
+			}
+			if errors.As(got, &accessDenied) != tt.wantAccessDenied {
+				t.Errorf("mapS3Error() = %v, wantAccessDenied %v", got, tt.wantAccessDenied)
+			}
+			if errors.As(got, &throttled) != tt.wantThrottled {
+				t.Errorf("mapS3Error() = %v, wantThrottled %v", got, tt.wantThrottled)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("mapS3Error() = %v, does not wrap %v", got, tt.err)
+			}
+		})
+	}
+}
+
+// pagedListClient serves canned ListObjectsV2 pages keyed by continuation token
+type pagedListClient struct {
+	pages    map[string]string
+	requests int
+}
+
+func (c *pagedListClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests++
+	body, ok := c.pages[req.URL.Query().Get("continuation-token")]
+	if !ok {
+		return nil, fmt.Errorf("unexpected request %s", req.URL)
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/xml"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestS3StorageProvider_ListPaginates(t *testing.T) {
+	httpClient := &pagedListClient{pages: map[string]string{
+		"": `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult>
+  <IsTruncated>true</IsTruncated>
+  <NextContinuationToken>page-2</NextContinuationToken>
+  <Contents><Key>ledger/BANK/2024-01.json</Key><Size>10</Size><LastModified>2025-01-01T00:00:00.000Z</LastModified></Contents>
+  <CommonPrefixes><Prefix>ledger/BANK/archive/</Prefix></CommonPrefixes>
+</ListBucketResult>`,
+		"page-2": `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult>
+  <IsTruncated>false</IsTruncated>
+  <Contents><Key>ledger/BANK/2024-02.json</Key><Size>20</Size><LastModified>2025-01-01T00:00:00.000Z</LastModified></Contents>
+</ListBucketResult>`,
+	}}
+	client := s3.New(s3.Options{
+		Region:       "ap-southeast-2",
+		BaseEndpoint: aws.String("https://s3.test"),
+		UsePathStyle: true,
+		Credentials:  aws.AnonymousCredentials{},
+		HTTPClient:   httpClient,
+	})
+	s := &S3StorageProvider{Client: client, Bucket: "data"}
+
+	got, err := s.List(context.Background(), "ledger/BANK")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var gotPaths []string
+	for _, item := range got {
+		gotPaths = append(gotPaths, item.Path)
+	}
+	wantPaths := []string{"ledger/BANK/archive/", "ledger/BANK/2024-01.json", "ledger/BANK/2024-02.json"}
+	if !reflect.DeepEqual(gotPaths, wantPaths) {
+		t.Errorf("List() paths = %v, want %v", gotPaths, wantPaths)
+	}
+	if httpClient.requests != 2 {
+		t.Errorf("List() made %d requests, want 2", httpClient.requests)
+	}
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"context"
+	"io"
 	"time"
 )
 
@@ -15,9 +17,17 @@ type FileItem struct {
 
 // StorageProvider defines the interface for backend file operations
 type StorageProvider interface {
-	List(path string) ([]FileItem, error)
-	Get(path string) ([]byte, error)
-	Save(path string, content []byte) error
-	Mkdir(path string) error
-	Delete(path string) error
+	List(ctx context.Context, path string) ([]FileItem, error)
+	Get(ctx context.Context, path string) ([]byte, error)
+	GetReader(ctx context.Context, path string) (io.ReadCloser, error)
+	Save(ctx context.Context, path string, content []byte) error
+	// GetWithETag returns an object's content together with its entity tag for use with SaveIfMatch
+	GetWithETag(ctx context.Context, path string) ([]byte, string, error)
+	// SaveIfMatch writes content only if the stored object still has etag, or does not exist when etag is empty.
+	// It returns ErrPreconditionFailed when the object changed in the meantime.
+	SaveIfMatch(ctx context.Context, path string, content []byte, etag string) error
+	// SaveReader streams r to path; size is the content length in bytes, or -1 if unknown
+	SaveReader(ctx context.Context, path string, r io.Reader, size int64) error
+	Mkdir(ctx context.Context, path string) error
+	Delete(ctx context.Context, path string) error
 }
@@ -1,7 +1,7 @@
 import { fetchAuthSession } from 'aws-amplify/auth';
 import { fetchAppConfig } from './config';
 import { generateMockLedger, CATEGORIES } from './mocks/ledgerData';
-import type { MonthlyLedger, TransactionType } from './mocks/ledgerData';
+import type { MonthlyLedger, Transaction, TransactionType } from './mocks/ledgerData';
 
 async function resolveApiBaseUrl() {
     const config = await fetchAppConfig();
@@ -44,6 +44,24 @@ export async function apiFetch(path: string, options: RequestInit = {}) {
     return response;
 }
 
+// The API encodes money as exact decimal strings ("12.34"); convert them for display and editing
+function toAmount(value: unknown): number {
+    return typeof value === 'string' ? Number(value) : (value as number) ?? 0;
+}
+
+export function parseLedger(raw: MonthlyLedger): MonthlyLedger {
+    return {
+        ...raw,
+        openingBalance: toAmount(raw.openingBalance),
+        closingBalance: toAmount(raw.closingBalance),
+        transactions: (raw.transactions ?? []).map(tx => ({
+            ...tx,
+            amount: toAmount(tx.amount),
+            runningBalance: toAmount(tx.runningBalance),
+        })),
+    };
+}
+
 export async function fetchLedger(type: TransactionType): Promise<MonthlyLedger[]> {
     if (import.meta.env.VITE_NO_AUTH === 'true') {
         console.log(`Mocking Ledger Fetch for ${type}`);
@@ -59,7 +77,35 @@ export async function fetchLedger(type: TransactionType): Promise<MonthlyLedger[
     if (!data) {
         throw new Error('Ledger response was empty');
     }
-    return data as MonthlyLedger[];
+    const baseUrl = await resolveApiBaseUrl();
+    return (data as MonthlyLedger[]).map(parseLedger).map(ledger => withAttachmentLinks(ledger, baseUrl));
+}
+
+export async function fetchLedgerMonth(type: TransactionType, month: string): Promise<MonthlyLedger> {
+    const res = await apiFetch(`/ledger?type=${type}&month=${month}`);
+    const baseUrl = await resolveApiBaseUrl();
+    return withAttachmentLinks(parseLedger((await res.json()) as MonthlyLedger), baseUrl);
+}
+
+// Attachment links from the API are relative to it
+function withAttachmentLinks(ledger: MonthlyLedger, baseUrl: string): MonthlyLedger {
+    return {
+        ...ledger,
+        transactions: ledger.transactions.map(tx => ({
+            ...tx,
+            attachments: tx.attachments?.map(a => ({ ...a, url: a.url ? `${baseUrl}${a.url}` : undefined })),
+        })),
+    };
+}
+
+// Uploads a receipt and attaches it to the transaction in one step; content is base64 encoded
+export async function uploadReceipt(type: TransactionType, month: string, transactionId: string, name: string, content: string): Promise<void> {
+    const params = new URLSearchParams({ type, month, transactionId, name });
+    const res = await apiFetch(`/ledger/attachments?${params}`, {
+        method: 'POST',
+        body: content,
+    });
+    await res.text();
 }
 
 export async function saveLedger(type: TransactionType, ledger: MonthlyLedger[]): Promise<void> {
@@ -76,6 +122,351 @@ export async function saveLedger(type: TransactionType, ledger: MonthlyLedger[])
     await res.text(); // consume body
 }
 
+export async function createTransfer(from: TransactionType, to: TransactionType, date: string, amount: number, description: string): Promise<void> {
+    const res = await apiFetch('/ledger/transfer', {
+        method: 'POST',
+        body: JSON.stringify({ from, to, date, amount: amount.toFixed(2), description }),
+    });
+    await res.text();
+}
+
+export async function deleteTransfer(transferId: string): Promise<void> {
+    const res = await apiFetch(`/ledger/transfer?id=${encodeURIComponent(transferId)}`, { method: 'DELETE' });
+    await res.text();
+}
+
+export type Reconciliation = {
+    type: TransactionType;
+    month: string;
+    statementDate: string;
+    statementBalance: number;
+    cleared: string[];
+    status: 'in-progress' | 'reconciled';
+    reconciledAt?: string;
+    version: number;
+    ledgerBalance: number;
+    outstanding: Transaction[];
+    outstandingTotal: number;
+    clearedBalance: number;
+    difference: number;
+};
+
+function parseReconciliation(raw: Reconciliation): Reconciliation {
+    return {
+        ...raw,
+        statementBalance: toAmount(raw.statementBalance),
+        ledgerBalance: toAmount(raw.ledgerBalance),
+        outstanding: (raw.outstanding ?? []).map(tx => ({
+            ...tx,
+            amount: toAmount(tx.amount),
+            runningBalance: toAmount(tx.runningBalance),
+        })),
+        outstandingTotal: toAmount(raw.outstandingTotal),
+        clearedBalance: toAmount(raw.clearedBalance),
+        difference: toAmount(raw.difference),
+    };
+}
+
+export async function fetchReconciliation(type: TransactionType, month: string): Promise<Reconciliation> {
+    const res = await apiFetch(`/ledger/reconciliation?type=${type}&month=${month}`);
+    return parseReconciliation(await res.json());
+}
+
+export async function saveReconciliation(type: TransactionType, month: string, statementDate: string, statementBalance: number, cleared: string[]): Promise<Reconciliation> {
+    const res = await apiFetch(`/ledger/reconciliation?type=${type}&month=${month}`, {
+        method: 'POST',
+        body: JSON.stringify({ statementDate, statementBalance: statementBalance.toFixed(2), cleared }),
+    });
+    return parseReconciliation(await res.json());
+}
+
+// Marks the month reconciled, which locks its ledger until unlockReconciliation is called
+export async function completeReconciliation(type: TransactionType, month: string): Promise<Reconciliation> {
+    const res = await apiFetch(`/ledger/reconciliation/complete?type=${type}&month=${month}`, { method: 'POST' });
+    return parseReconciliation(await res.json());
+}
+
+export async function unlockReconciliation(type: TransactionType, month: string): Promise<Reconciliation> {
+    const res = await apiFetch(`/ledger/reconciliation/unlock?type=${type}&month=${month}`, { method: 'POST' });
+    return parseReconciliation(await res.json());
+}
+
+export type ReimbursementStatus = 'submitted' | 'approved' | 'rejected' | 'paid';
+
+export type Reimbursement = {
+    id: string;
+    claimant: string;
+    amount: number;
+    category: string;
+    description: string;
+    receiptPath?: string;
+    status: ReimbursementStatus;
+    submittedAt: string;
+    submittedBy?: string;
+    reviewedAt?: string;
+    reviewedBy?: string;
+    comment?: string;
+    payment?: { ledgerType: TransactionType; month: string; transactionId: string; paidAt: string };
+    version: number;
+};
+
+function parseReimbursement(raw: Reimbursement): Reimbursement {
+    return { ...raw, amount: toAmount(raw.amount) };
+}
+
+export async function fetchReimbursements(status?: ReimbursementStatus): Promise<Reimbursement[]> {
+    const res = await apiFetch(status ? `/reimbursements?status=${status}` : '/reimbursements');
+    return ((await res.json()) as Reimbursement[]).map(parseReimbursement);
+}
+
+export async function submitReimbursement(claim: { claimant: string; amount: number; category: string; description: string; receiptPath?: string }): Promise<Reimbursement> {
+    const res = await apiFetch('/reimbursements', {
+        method: 'POST',
+        body: JSON.stringify({ ...claim, amount: claim.amount.toFixed(2) }),
+    });
+    return parseReimbursement(await res.json());
+}
+
+export async function reviewReimbursement(id: string, decision: 'approve' | 'reject', comment: string): Promise<Reimbursement> {
+    const res = await apiFetch(`/reimbursements/review?id=${encodeURIComponent(id)}`, {
+        method: 'POST',
+        body: JSON.stringify({ decision, comment }),
+    });
+    return parseReimbursement(await res.json());
+}
+
+// Pays an approved claim by adding a Reimbursement transaction to the ledger
+export async function payReimbursement(id: string, ledgerType: TransactionType, date: string): Promise<Reimbursement> {
+    const res = await apiFetch(`/reimbursements/pay?id=${encodeURIComponent(id)}`, {
+        method: 'POST',
+        body: JSON.stringify({ ledgerType, date }),
+    });
+    return parseReimbursement(await res.json());
+}
+
+export type GrantStatus = 'applied' | 'approved' | 'acquitted' | 'declined';
+
+// Received and linked expenditure take their date and amount from the ledger transaction they name
+export type Grant = {
+    id: string;
+    grantor: string;
+    purpose: string;
+    amountApproved: number;
+    approvedDate?: string;
+    received: { ledgerType: TransactionType; transactionId: string; date: string; amount: number }[];
+    expenditure: { date: string; amount: number; description: string; ledgerType?: TransactionType; transactionId?: string }[];
+    acquittalDue?: string;
+    status: GrantStatus;
+    totalReceived: number;
+    totalExpended: number;
+    unexpended: number;
+    acquittalOverdue: boolean;
+};
+
+function parseGrant(raw: Grant): Grant {
+    return {
+        ...raw,
+        amountApproved: toAmount(raw.amountApproved),
+        received: raw.received.map((receipt) => ({ ...receipt, amount: toAmount(receipt.amount) })),
+        expenditure: raw.expenditure.map((spend) => ({ ...spend, amount: toAmount(spend.amount) })),
+        totalReceived: toAmount(raw.totalReceived),
+        totalExpended: toAmount(raw.totalExpended),
+        unexpended: toAmount(raw.unexpended),
+    };
+}
+
+export async function fetchGrants(): Promise<Grant[]> {
+    const res = await apiFetch('/grants');
+    return ((await res.json()) as Grant[]).map(parseGrant);
+}
+
+// Saves a new grant, or replaces the grant with the same id
+export async function saveGrant(grant: Omit<Grant, 'id' | 'totalReceived' | 'totalExpended' | 'unexpended' | 'acquittalOverdue'> & { id?: string }): Promise<Grant> {
+    const res = await apiFetch('/grants', {
+        method: 'POST',
+        body: JSON.stringify({
+            ...grant,
+            amountApproved: grant.amountApproved.toFixed(2),
+            received: grant.received.map((receipt) => ({ ...receipt, amount: receipt.amount.toFixed(2) })),
+            expenditure: grant.expenditure.map((spend) => ({ ...spend, amount: spend.amount.toFixed(2) })),
+        }),
+    });
+    return parseGrant(await res.json());
+}
+
+export async function deleteGrant(id: string): Promise<void> {
+    const res = await apiFetch(`/grants?id=${encodeURIComponent(id)}`, { method: 'DELETE' });
+    await res.text();
+}
+
+// The drawdown and repayments take their date and amount from the ledger transaction they name
+export type Loan = {
+    id: string;
+    lender: string;
+    description: string;
+    principal: number;
+    annualRate: number;
+    termMonths: number;
+    startDate: string;
+    drawdown?: { ledgerType: TransactionType; transactionId: string; date: string; amount: number };
+    repayments: { ledgerType: TransactionType; transactionId: string; date: string; amount: number }[];
+    repaid: number;
+    outstanding: number;
+    current: number;
+    nonCurrent: number;
+};
+
+export type LoanInstalment = {
+    number: number;
+    date: string;
+    payment: number;
+    interest: number;
+    principal: number;
+    balance: number;
+};
+
+function parseLoan(raw: Loan): Loan {
+    return {
+        ...raw,
+        principal: toAmount(raw.principal),
+        drawdown: raw.drawdown && { ...raw.drawdown, amount: toAmount(raw.drawdown.amount) },
+        repayments: raw.repayments.map((repayment) => ({ ...repayment, amount: toAmount(repayment.amount) })),
+        repaid: toAmount(raw.repaid),
+        outstanding: toAmount(raw.outstanding),
+        current: toAmount(raw.current),
+        nonCurrent: toAmount(raw.nonCurrent),
+    };
+}
+
+export async function fetchLoans(): Promise<Loan[]> {
+    const res = await apiFetch('/loans');
+    return ((await res.json()) as Loan[]).map(parseLoan);
+}
+
+export async function fetchLoanSchedule(id: string): Promise<{ loan: Loan; schedule: LoanInstalment[] }> {
+    const res = await apiFetch(`/loans/schedule?id=${encodeURIComponent(id)}`);
+    const raw = (await res.json()) as { loan: Loan; schedule: LoanInstalment[] };
+    return {
+        loan: parseLoan(raw.loan),
+        schedule: raw.schedule.map((instalment) => ({
+            ...instalment,
+            payment: toAmount(instalment.payment),
+            interest: toAmount(instalment.interest),
+            principal: toAmount(instalment.principal),
+            balance: toAmount(instalment.balance),
+        })),
+    };
+}
+
+// Saves a new loan, or replaces the loan with the same id
+export async function saveLoan(loan: Pick<Loan, 'lender' | 'description' | 'principal' | 'annualRate' | 'termMonths' | 'startDate'> & { id?: string; drawdown?: { ledgerType: TransactionType; transactionId: string }; repayments: { ledgerType: TransactionType; transactionId: string }[] }): Promise<Loan> {
+    const res = await apiFetch('/loans', {
+        method: 'POST',
+        body: JSON.stringify({ ...loan, principal: loan.principal.toFixed(2) }),
+    });
+    return parseLoan(await res.json());
+}
+
+export async function deleteLoan(id: string): Promise<void> {
+    const res = await apiFetch(`/loans?id=${encodeURIComponent(id)}`, { method: 'DELETE' });
+    await res.text();
+}
+
+export type TrustFund = {
+    id: string;
+    name: string;
+    heldFor: string;
+    description?: string;
+};
+
+export type TrustFundEntry = {
+    ledgerType: TransactionType;
+    transaction: Transaction;
+    balance: number;
+};
+
+export async function fetchTrustFunds(): Promise<(TrustFund & { balance: number })[]> {
+    const res = await apiFetch('/trust-funds');
+    return ((await res.json()) as (TrustFund & { balance: number })[]).map((fund) => ({ ...fund, balance: toAmount(fund.balance) }));
+}
+
+// Lists the transactions tagged to a fund with the fund's running balance
+export async function fetchTrustFundLedger(id: string): Promise<{ fund: TrustFund & { balance: number }; entries: TrustFundEntry[] }> {
+    const res = await apiFetch(`/trust-funds/ledger?id=${encodeURIComponent(id)}`);
+    const raw = (await res.json()) as { fund: TrustFund & { balance: number }; entries: TrustFundEntry[] };
+    return {
+        fund: { ...raw.fund, balance: toAmount(raw.fund.balance) },
+        entries: raw.entries.map((entry) => ({
+            ...entry,
+            transaction: { ...entry.transaction, amount: toAmount(entry.transaction.amount), runningBalance: toAmount(entry.transaction.runningBalance) },
+            balance: toAmount(entry.balance),
+        })),
+    };
+}
+
+// Saves a new fund, or replaces the fund with the same id
+export async function saveTrustFund(fund: Omit<TrustFund, 'id'> & { id?: string }): Promise<TrustFund> {
+    const res = await apiFetch('/trust-funds', {
+        method: 'POST',
+        body: JSON.stringify(fund),
+    });
+    return res.json();
+}
+
+export async function deleteTrustFund(id: string): Promise<void> {
+    const res = await apiFetch(`/trust-funds?id=${encodeURIComponent(id)}`, { method: 'DELETE' });
+    await res.text();
+}
+
+// A lock names either a financial year, by the year it ends in, or a single month
+export type PeriodLock = {
+    financialYear?: number;
+    month?: string;
+    note?: string;
+    lockedAt: string;
+    closed?: boolean;
+};
+
+export async function fetchPeriodLocks(): Promise<PeriodLock[]> {
+    const res = await apiFetch('/periods/locks');
+    return res.json();
+}
+
+export async function lockPeriod(period: { financialYear: number } | { month: string }, note: string): Promise<void> {
+    const params = new URLSearchParams('month' in period ? { month: period.month } : { financialYear: String(period.financialYear) });
+    if (note) {
+        params.set('note', note);
+    }
+    const res = await apiFetch(`/periods/locks?${params}`, { method: 'POST' });
+    await res.text();
+}
+
+export async function unlockPeriod(period: { financialYear: number } | { month: string }): Promise<void> {
+    const query = 'month' in period ? `month=${period.month}` : `financialYear=${period.financialYear}`;
+    const res = await apiFetch(`/periods/locks?${query}`, { method: 'DELETE' });
+    await res.text();
+}
+
+// Locks the financial year and snapshots the figures its report shows from then on
+export async function closeFinancialYear(financialYear: number): Promise<void> {
+    const res = await apiFetch(`/periods/close?financialYear=${financialYear}`, { method: 'POST' });
+    await res.text();
+}
+
+export type CategoryKind = 'income' | 'expense' | 'asset' | 'liability' | 'equity' | 'transfer';
+
+export type Category = {
+    id: string;
+    name: string;
+    code?: string;
+    kind: CategoryKind;
+    parentId?: string;
+    active: boolean;
+    gst?: string;
+    aliases?: string[];
+};
+
+// Returns the names of active categories, which is what transactions store
 export async function fetchCategories(): Promise<string[]> {
     if (import.meta.env.VITE_NO_AUTH === 'true') {
         return CATEGORIES;
@@ -86,18 +477,18 @@ export async function fetchCategories(): Promise<string[]> {
     if (!data) {
         throw new Error('Categories response was empty');
     }
-    return data as string[];
+    return (data as Category[]).filter(category => category.active).map(category => category.name);
 }
 
-export async function saveCategories(categories: string[]): Promise<void> {
+export async function createCategory(name: string, kind: CategoryKind): Promise<void> {
     if (import.meta.env.VITE_NO_AUTH === 'true') {
-        console.log('Mocking Categories Save', categories);
+        console.log('Mocking Category Create', name, kind);
         return;
     }
 
     const res = await apiFetch(`/ledger/categories`, {
         method: 'POST',
-        body: JSON.stringify(categories),
+        body: JSON.stringify({ name, kind }),
     });
     // apiFetch will throw on error; consume response
     await res.text();
@@ -142,5 +533,25 @@ export async function fetchFinancialReport(period: string): Promise<FinancialRep
     if (!data) {
         throw new Error('Financial report response was empty');
     }
-    return data as FinancialReportResponse;
+    const report = data as FinancialReportResponse;
+    const toItems = (items: FinancialReportLineItem[]) => (items ?? []).map(item => ({ ...item, amount: toAmount(item.amount) }));
+    return {
+        ...report,
+        statement: {
+            ...report.statement,
+            income: toItems(report.statement.income),
+            expenditure: toItems(report.statement.expenditure),
+            totalIncome: toAmount(report.statement.totalIncome),
+            totalExpenditure: toAmount(report.statement.totalExpenditure),
+            netResult: toAmount(report.statement.netResult),
+        },
+        balanceSheet: {
+            ...report.balanceSheet,
+            assets: toItems(report.balanceSheet.assets),
+            liabilities: toItems(report.balanceSheet.liabilities),
+            totalAssets: toAmount(report.balanceSheet.totalAssets),
+            totalLiabilities: toAmount(report.balanceSheet.totalLiabilities),
+            equity: toAmount(report.balanceSheet.equity),
+        },
+    };
 }
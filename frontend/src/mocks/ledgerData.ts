@@ -7,6 +7,19 @@ export interface Transaction {
     description: string;
     amount: number;
     runningBalance: number;
+    transferId?: string; // links the two sides of a transfer between ledgers
+    reimbursementId?: string; // the reimbursement claim this transaction paid
+    trustFundId?: string; // the trust fund this money is held in for someone else
+    attachments?: Attachment[];
+}
+
+// A receipt or other document supporting a transaction. url is a signed link that the API adds
+// when the ledger is read.
+export interface Attachment {
+    path: string;
+    url?: string;
+    token?: string;
+    expires?: number;
 }
 
 export interface MonthlyLedger {
@@ -16,6 +29,7 @@ export interface MonthlyLedger {
     openingBalance: number;
     closingBalance: number;
     transactions: Transaction[];
+    version?: number;
     isOpen?: boolean;
 }
 
@@ -1,6 +1,6 @@
 import { useState, useEffect, useMemo, useRef } from 'react';
 import { fetchAuthSession } from 'aws-amplify/auth';
-import { apiFetch, saveLedger, fetchCategories, saveCategories } from '../api';
+import { apiFetch, fetchLedgerMonth, saveLedger, fetchCategories, createCategory } from '../api';
 import type { MonthlyLedger, TransactionType, Transaction } from '../mocks/ledgerData';
 import { CATEGORIES } from '../mocks/ledgerData';
 import { FaMoneyBillWave, FaUniversity, FaCreditCard, FaPlus, FaUnlock, FaLock, FaPrint } from 'react-icons/fa';
@@ -30,6 +30,7 @@ export function Ledger() {
     const [type, setType] = useState<TransactionType>('CASH');
     const [data, setData] = useState<MonthlyLedger[]>([]);
     const [loading, setLoading] = useState(false);
+    const [reloadKey, setReloadKey] = useState(0);
     const [openMonths, setOpenMonths] = useState<Set<string>>(new Set());
     const [categories, setCategories] = useState<string[]>(CATEGORIES);
     const [alert, setAlert] = useState<{ message: string; tone: 'error' | 'info' | 'success' } | null>(null);
@@ -77,8 +78,7 @@ export function Ledger() {
                 const results = await Promise.all(
                     monthsToFetch.map(async month => {
                         try {
-                            const res = await apiFetch(`/ledger?type=${type}&month=${month}`);
-                            return (await res.json()) as MonthlyLedger;
+                            return await fetchLedgerMonth(type, month);
                         } catch (err) {
                             console.error(err);
                         }
@@ -100,7 +100,7 @@ export function Ledger() {
             }
         }
         load();
-    }, [type]);
+    }, [type, reloadKey]);
 
     const toggleOpenMonth = (month: string) => {
         setOpenMonths(prev => {
@@ -144,6 +144,8 @@ export function Ledger() {
             if (m.month !== monthStr) return m;
             return {
                 ...m,
+                // The server rejects ledgers whose closing balance doesn't match; round to cents here
+                closingBalance: Math.round((m.closingBalance + newTx.amount) * 100) / 100,
                 transactions: [...m.transactions, newTx]
             };
         });
@@ -151,14 +153,17 @@ export function Ledger() {
         // Optimistically update local state
         setData(updatedData);
 
-        // Save to backend
+        // Save only the edited month; the server rolls later balances forward, so reload afterwards
         try {
-            await saveLedger(type, updatedData);
+            await saveLedger(type, updatedData.filter(m => m.month === monthStr));
         } catch (err) {
             console.error(err);
-            showAlert('Failed to save ledger to server. Please try again.');
-            // Revert on failure might be complex, but at least user knows
+            const conflict = err instanceof Error && err.message.includes('409');
+            showAlert(conflict
+                ? 'This month was changed by someone else. Reloading the latest ledger.'
+                : 'Failed to save ledger to server. Please try again.');
         }
+        setReloadKey(key => key + 1);
 
         // Reset draft
         setNewTxDrafts(prev => ({
@@ -202,7 +207,9 @@ export function Ledger() {
                 handleDraftUpdate(month, 'category', newCat);
 
                 try {
-                    await saveCategories(updatedCategories);
+                    // Treat the category as income if the drafted amount is money in
+                    const amount = Number(newTxDrafts[month]?.amount ?? 0);
+                    await createCategory(newCat, amount > 0 ? 'income' : 'expense');
                 } catch (err) {
                     console.error('Failed to save categories', err);
                     showAlert('Failed to save new category to server.');
@@ -366,7 +373,14 @@ export function Ledger() {
                                                 <tr key={tx.id}>
                                                     <td>{tx.date}</td>
                                                     <td>{tx.category}</td>
-                                                    <td>{tx.description}</td>
+                                                    <td>
+                                                        {tx.description}
+                                                        {tx.attachments?.map(attachment => attachment.url && (
+                                                            <a key={attachment.path} className="receipt-link" href={attachment.url} target="_blank" rel="noreferrer">
+                                                                {attachment.path.split('/').pop()}
+                                                            </a>
+                                                        ))}
+                                                    </td>
                                                     <td className={`right ${tx.amount < 0 ? 'neg' : 'pos'}`}>
                                                         {tx.amount.toFixed(2)}
                                                     </td>